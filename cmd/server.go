@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"context"
 	"log"
+	"net/http"
 
-	"github.com/dhax/go-base/api"
+	"github.com/JECSand/eventit-server/domains/identity/src/oidc"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	identityRouters "github.com/JECSand/eventit-server/domains/identity/src/routers"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/mailers"
+	"github.com/JECSand/eventit-server/domains/shared/servers"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -14,11 +23,111 @@ var serveCmd = &cobra.Command{
 	Short: "start http server with configured api",
 	Long:  `Starts a http server and serves the configured api`,
 	Run: func(cmd *cobra.Command, args []string) {
-		server, err := api.NewServer()
+		keyring, err := auth.LoadKeyring()
 		if err != nil {
 			log.Fatal(err)
 		}
-		server.Start()
+		if keyring != nil {
+			auth.SetKeyring(keyring)
+		}
+		hasher, err := auth.LoadPasswordHasher()
+		if err != nil {
+			log.Fatal(err)
+		}
+		auth.SetPasswordHasher(hasher)
+		permissionPolicy, err := auth.LoadPermissionPolicy()
+		if err != nil {
+			log.Fatal(err)
+		}
+		auth.SetPermissionPolicy(permissionPolicy)
+		mailer, err := mailers.NewMailer()
+		if err != nil {
+			log.Fatal(err)
+		}
+		oidcClients, err := oidc.LoadClients()
+		if err != nil {
+			log.Fatal(err)
+		}
+		passwordPolicy, err := services.LoadPasswordPolicy()
+		if err != nil {
+			log.Fatal(err)
+		}
+		db, err := databases.InitializeNewClient()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = db.Connect(); err != nil {
+			log.Fatal(err)
+		}
+		// identity domain
+		userRepo := repos.NewUserRepo(db)
+		blacklistRepo := repos.NewBlacklistRepo(db)
+		refreshRepo := repos.NewRefreshTokenRepo(db)
+		apiKeyRepo := repos.NewAPIKeyRepo(db)
+		tokenRepo := repos.NewOneTimeTokenRepo(db)
+		twoFactorRepo := repos.NewTwoFactorRepo(db)
+		identityRepo := repos.NewLinkedIdentityRepo(db)
+		attemptRepo := repos.NewLoginAttemptRepo(db)
+		sessionRepo := repos.NewSessionRepo(db)
+		orgRepo := repos.NewOrganizationRepo(db)
+		membershipRepo := repos.NewMembershipRepo(db)
+		auditRepo := repos.NewAuditRepo(db)
+		userService := services.NewUserService(userRepo, tokenRepo, mailer, passwordPolicy)
+		twoFactorService := services.NewTwoFactorService(userService, twoFactorRepo)
+		sessionService := services.NewSessionService(sessionRepo, refreshRepo)
+		orgService := services.NewOrganizationService(orgRepo, membershipRepo, userService, tokenRepo, mailer)
+		auditService := services.NewAuditService(auditRepo)
+		authService := services.NewAuthService(userService, twoFactorService, sessionService, orgService, auditService, blacklistRepo, refreshRepo, tokenRepo, attemptRepo, mailer)
+		apiKeyService := services.NewAPIKeyService(apiKeyRepo, userService)
+		oidcService := services.NewOIDCService(oidcClients, userService, authService, identityRepo, tokenRepo)
+		passwordService := services.NewPasswordService(userService, authService, tokenRepo, mailer)
+		if err = blacklistRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		if err = apiKeyRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		if err = tokenRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		if err = twoFactorRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		if err = identityRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		if err = attemptRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		if err = sessionRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		if err = membershipRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		if err = auditRepo.EnsureIndexes(); err != nil {
+			log.Fatal(err)
+		}
+		auth.RegisterTokenCheck(authService.CheckBlacklist)
+		auth.RegisterTokenCheck(sessionService.CheckSession)
+		auth.RegisterAPIKeyResolver(apiKeyService.Resolve)
+		mux := http.NewServeMux()
+		identityRouters.NewRouter(userService, authService, apiKeyService, passwordService, twoFactorService, oidcService, sessionService, orgService, auditService).Register(mux)
+		server := servers.NewServer(viper.GetString("port"), mux, db)
+		server.AddJob(servers.Job{
+			Name:     "purge deleted users",
+			Interval: viper.GetDuration("user_purge_interval"),
+			Run: func(ctx context.Context) error {
+				purged, err := userService.PurgeDeleted()
+				if purged > 0 {
+					log.Printf("purged %d deleted users", purged)
+				}
+				return err
+			},
+		})
+		if err = server.Start(); err != nil {
+			log.Fatal(err)
+		}
 	},
 }
 
@@ -28,13 +137,46 @@ func init() {
 	// Here you will define your flags and configuration settings.
 	viper.SetDefault("port", "3000")
 	viper.SetDefault("log_level", "debug")
+	viper.SetDefault("database", "eventit")
+	viper.SetDefault("pagination_cursor_secret", "")
+	viper.SetDefault("trust_proxy_headers", false)
+	viper.SetDefault("trust_proxy_hops", 1)
+	viper.SetDefault("user_deleted_retention", "720h")
+	viper.SetDefault("user_purge_interval", "1h")
+	viper.SetDefault("org_invitation_url", "http://localhost:3000/accept-invitation")
+	viper.SetDefault("org_invitation_expiry", "168h")
 
 	viper.SetDefault("auth_login_url", "http://localhost:3000/login")
 	viper.SetDefault("auth_login_token_length", 8)
 	viper.SetDefault("auth_login_token_expiry", "11m")
+	viper.SetDefault("auth_login_link_signup", true)
+	viper.SetDefault("auth_login_max_failures", 5)
+	viper.SetDefault("auth_login_ip_max_failures", 20)
+	viper.SetDefault("auth_login_lockout_duration", "15m")
+	viper.SetDefault("auth_login_backoff_base", "1s")
 	viper.SetDefault("auth_jwt_secret", "random")
+	viper.SetDefault("auth_jwt_signing_kid", "")
 	viper.SetDefault("auth_jwt_expiry", "15m")
 	viper.SetDefault("auth_jwt_refresh_expiry", "1h")
+	viper.SetDefault("auth_impersonation_expiry", "15m")
+	viper.SetDefault("auth_two_factor_issuer", "eventit")
+	viper.SetDefault("auth_two_factor_required_role", "")
+	viper.SetDefault("auth_password_reset_url", "http://localhost:3000/reset-password")
+	viper.SetDefault("auth_password_min_length", 10)
+	viper.SetDefault("auth_password_min_classes", 3)
+	viper.SetDefault("auth_password_breached_list", "")
+	viper.SetDefault("auth_password_hasher", "argon2id")
+	viper.SetDefault("auth_argon2_memory", 19456)
+	viper.SetDefault("auth_argon2_iterations", 2)
+	viper.SetDefault("auth_argon2_parallelism", 1)
+	viper.SetDefault("auth_bcrypt_cost", 10)
+	viper.SetDefault("auth_email_verification_policy", "purchase")
+	viper.SetDefault("auth_verify_email_url", "http://localhost:3000/verify-email")
+	viper.SetDefault("auth_verify_email_expiry", "24h")
+	viper.SetDefault("auth_verify_email_resend_interval", "1m")
+
+	viper.SetDefault("mailer", "log")
+	viper.SetDefault("mailer_log_file", "")
 
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"time"
+)
+
+// APIKey is a root struct that is used to store the json encoded data for/from a mongodb api key doc.
+type APIKey struct {
+	Id         string     `json:"id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	Prefix     string     `json:"prefix,omitempty"`
+	Key        string     `json:"key,omitempty"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	Role       enums.Role `json:"role,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at,omitempty"`
+	RevokedAt  time.Time  `json:"revoked_at,omitempty"`
+	LastUsedAt time.Time  `json:"last_used_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at,omitempty"`
+}
+
+// Active returns whether the APIKey is neither revoked nor expired
+func (k *APIKey) Active() bool {
+	if !k.RevokedAt.IsZero() {
+		return false
+	}
+	return k.ExpiresAt.IsZero() || time.Now().UTC().Before(k.ExpiresAt)
+}
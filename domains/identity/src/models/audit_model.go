@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditAction enumerates the actions recorded by the audit log
+type AuditAction string
+
+const (
+	ImpersonationStarted AuditAction = "impersonation.start"
+	ImpersonationStopped AuditAction = "impersonation.stop"
+)
+
+// AuditEvent is a root struct that is used to store the json encoded data for/from a mongodb audit log doc, it
+// records an action performed by an actor on a subject user
+type AuditEvent struct {
+	Id        string            `json:"id,omitempty"`
+	Action    AuditAction       `json:"action,omitempty"`
+	ActorId   string            `json:"actor_id,omitempty"`
+	SubjectId string            `json:"subject_id,omitempty"`
+	SessionId string            `json:"session_id,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitempty"`
+}
+
+// AuditEventsPage Multiple AuditEvents in a paginated response
+type AuditEventsPage struct {
+	TotalCount int64         `json:"total_count"`
+	TotalPages int64         `json:"total_pages"`
+	Page       int64         `json:"page"`
+	Size       int64         `json:"size"`
+	HasMore    bool          `json:"has_more"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	PrevCursor string        `json:"prev_cursor,omitempty"`
+	Events     []*AuditEvent `json:"events"`
+}
+
+// Impersonation stores the input of the start impersonation requests
+type Impersonation struct {
+	Reason string `json:"reason,omitempty"`
+}
@@ -3,7 +3,6 @@ package models
 import (
 	"errors"
 	"github.com/JECSand/eventit-server/domains/shared/auth"
-	"golang.org/x/crypto/bcrypt"
 	"time"
 )
 
@@ -11,6 +10,7 @@ import (
 type Blacklist struct {
 	Id        string    `json:"id,omitempty"`
 	AuthToken string    `json:"auth_token,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 }
@@ -21,28 +21,39 @@ type Credentials struct {
 	CurrentPassword string `json:"current_password,omitempty"`
 }
 
+// LoginLink stores the input of the magic link login requests
+type LoginLink struct {
+	Email string `json:"email,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
 type Auth struct {
-	User      *User         `json:"user,omitempty"`
-	AuthToken string        `json:"auth_token,omitempty"`
-	Session   *auth.Session `json:"session,omitempty"`
-	CreatedAt time.Time     `json:"created_at,omitempty"`
+	User         *User         `json:"user,omitempty"`
+	AuthToken    string        `json:"auth_token,omitempty"`
+	RefreshToken string        `json:"refresh_token,omitempty"`
+	Challenge    string        `json:"challenge,omitempty"`
+	Session      *auth.Session `json:"session,omitempty"`
+	Membership   *Membership   `json:"membership,omitempty"`
+	Permissions  []string      `json:"permissions,omitempty"`
+	Impersonator *User         `json:"impersonator,omitempty"`
+	CreatedAt    time.Time     `json:"created_at,omitempty"`
 }
 
 // authenticate compares an input password with the hashed password stored in the User model
 func (r *Auth) authenticate(user *User, checkPassword string) error {
-	if len(user.Password) != 0 {
-		password := []byte(user.Password)
-		cPassword := []byte(checkPassword)
-		return bcrypt.CompareHashAndPassword(password, cPassword)
-	}
-	return errors.New("no password set to hash in user model")
+	return user.CheckPassword(checkPassword)
 }
 
 // Invalidate compares an input password with the hashed password stored in the User model
 func (r *Auth) Invalidate() {
 	r.AuthToken = ""
+	r.RefreshToken = ""
+	r.Challenge = ""
 	r.User = nil
 	r.Session = nil
+	r.Membership = nil
+	r.Permissions = nil
+	r.Impersonator = nil
 	return
 }
 
@@ -63,6 +74,15 @@ func (r *Auth) NewSession() (err error) {
 		return
 	}
 	r.Session = auth.NewSession(r.User.Id, r.User.Role)
+	r.Session.EmailVerified = r.User.EmailVerified()
+	if r.Membership != nil {
+		r.Session.OrgId = r.Membership.OrganizationId
+		r.Session.OrgRole = r.Membership.Role
+	}
+	if r.Impersonator != nil {
+		r.Session.Actor = &auth.Actor{ProfileId: r.Impersonator.Id, Role: r.Impersonator.Role}
+		r.Session.Expiry = auth.ImpersonationExpiry()
+	}
 	return
 }
 
@@ -81,10 +101,21 @@ func (r *Auth) Authenticate(user *User, checkPassword string) (err error) {
 	if err = r.authenticate(user, checkPassword); err != nil {
 		return
 	}
+	return r.Authorize(user, false, "")
+}
+
+// Authorize issues a new session and token for a user whose identity has already been proven, along with
+// whether it completed two factor authentication and the id of its persisted UserSession. The token is scoped to
+// the organization of the Membership of the Auth when it is set, and carries the actor claim of its Impersonator
+func (r *Auth) Authorize(user *User, twoFactor bool, sessionId string) (err error) {
+	user.Password = ""
 	r.User = user
 	if err = r.NewSession(); err != nil {
 		return
 	}
+	r.Session.Id = sessionId
+	r.Session.TwoFactor = twoFactor
+	r.Permissions = (&auth.AppClaims{Role: r.Session.Role, OrgRole: r.Session.OrgRole, TwoFactor: twoFactor}).Permissions()
 	err = r.NewToken()
 	return
 }
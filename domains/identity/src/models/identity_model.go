@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// LinkedIdentity is a root struct that is used to store the json encoded data for/from a mongodb linked identity doc.
+type LinkedIdentity struct {
+	Id          string    `json:"id,omitempty"`
+	UserId      string    `json:"user_id,omitempty"`
+	Provider    string    `json:"provider,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	LastLoginAt time.Time `json:"last_login_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+}
+
+// OIDCCallback stores the input of an OpenID Connect provider callback
+type OIDCCallback struct {
+	State string `json:"state,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"time"
+)
+
+// Organization is a root struct that is used to store the json encoded data for/from a mongodb organization doc,
+// an organization is a tenant owning its events and members
+type Organization struct {
+	Id        string     `json:"id,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	Role      enums.Role `json:"role,omitempty"` // the role of the requester within the organization
+	UpdatedAt time.Time  `json:"updated_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at,omitempty"`
+}
+
+// Membership is a root struct that is used to store the json encoded data for/from a mongodb membership doc, it
+// grants a user a role within an organization
+type Membership struct {
+	Id             string     `json:"id,omitempty"`
+	OrganizationId string     `json:"organization_id,omitempty"`
+	UserId         string     `json:"user_id,omitempty"`
+	Role           enums.Role `json:"role,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at,omitempty"`
+}
+
+// OrgInvitation stores the input of the invite and accept organization invitation requests
+type OrgInvitation struct {
+	Email string     `json:"email,omitempty"`
+	Role  enums.Role `json:"role,omitempty"`
+	Token string     `json:"token,omitempty"`
+}
+
+// OrgSelection stores the input of the switch organization requests
+type OrgSelection struct {
+	OrganizationId string `json:"organization_id,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a root struct that is used to store the json encoded data for/from a mongodb refresh token doc.
+type RefreshToken struct {
+	Id         string    `json:"id,omitempty"`
+	UserId     string    `json:"user_id,omitempty"`
+	FamilyId   string    `json:"family_id,omitempty"`
+	TokenHash  string    `json:"token_hash,omitempty"`
+	ReplacedBy string    `json:"replaced_by,omitempty"`
+	TwoFactor  bool      `json:"two_factor,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	RotatedAt  time.Time `json:"rotated_at,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+}
+
+// RefreshCredentials stores the refresh token input of a refresh request
+type RefreshCredentials struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
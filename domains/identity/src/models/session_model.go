@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// ClientInfo describes the client a login or refresh request was sent from
+type ClientInfo struct {
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// UserSession is a root struct that is used to store the json encoded data for/from a mongodb session doc, a
+// session spans a login and every refresh of its tokens and its id is the jti of its access tokens
+type UserSession struct {
+	Id             string    `json:"id,omitempty"`
+	UserId         string    `json:"user_id,omitempty"`
+	OrganizationId string    `json:"organization_id,omitempty"`
+	ActorId        string    `json:"actor_id,omitempty"` // the user impersonating the user of the session
+	Device         string    `json:"device,omitempty"`
+	IP             string    `json:"ip,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	TwoFactor      bool      `json:"two_factor,omitempty"`
+	Current        bool      `json:"current,omitempty"`
+	LastSeenAt     time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at,omitempty"`
+	RevokedAt      time.Time `json:"revoked_at,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at,omitempty"`
+	CreatedAt      time.Time `json:"created_at,omitempty"`
+}
+
+// Active returns whether the UserSession was neither revoked nor expired
+func (s *UserSession) Active() bool {
+	return s.RevokedAt.IsZero() && time.Now().Before(s.ExpiresAt)
+}
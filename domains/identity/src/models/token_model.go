@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// TokenPurpose enumerates what a OneTimeToken can be consumed for
+type TokenPurpose string
+
+const (
+	PasswordResetToken     TokenPurpose = "password_reset"
+	EmailVerificationToken TokenPurpose = "verify_email"
+	LoginToken             TokenPurpose = "login"
+	TwoFactorChallenge     TokenPurpose = "two_factor"
+	OIDCState              TokenPurpose = "oidc_state"
+	OrgInvitationToken     TokenPurpose = "org_invitation"
+)
+
+// OneTimeToken is a root struct that is used to store the json encoded data for/from a mongodb one time token doc.
+type OneTimeToken struct {
+	Id        string            `json:"id,omitempty"`
+	UserId    string            `json:"user_id,omitempty"`
+	Purpose   TokenPurpose      `json:"purpose,omitempty"`
+	TokenHash string            `json:"token_hash,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+	UsedAt    time.Time         `json:"used_at,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitempty"`
+}
+
+// EmailVerification stores the input of the verify and resend email verification requests
+type EmailVerification struct {
+	Email string `json:"email,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// PasswordReset stores the input of the forgot and reset password requests
+type PasswordReset struct {
+	Email    string `json:"email,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Password string `json:"password,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// TwoFactor is a root struct that is used to store the json encoded data for/from a mongodb two factor doc.
+type TwoFactor struct {
+	Id            string    `json:"id,omitempty"`
+	UserId        string    `json:"user_id,omitempty"`
+	Secret        string    `json:"-"`
+	RecoveryCodes []string  `json:"-"`
+	LastUsedStep  int64     `json:"-"`
+	EnabledAt     time.Time `json:"enabled_at,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty"`
+	CreatedAt     time.Time `json:"created_at,omitempty"`
+}
+
+// Enabled returns whether the TwoFactor enrollment has been confirmed with a valid code
+func (t *TwoFactor) Enabled() bool {
+	return !t.EnabledAt.IsZero()
+}
+
+// TwoFactorEnrollment is returned when enrolling or confirming two factor authentication, the secret and
+// recovery codes are only ever returned by those calls
+type TwoFactorEnrollment struct {
+	Secret        string    `json:"secret,omitempty"`
+	URI           string    `json:"uri,omitempty"`
+	RecoveryCodes []string  `json:"recovery_codes,omitempty"`
+	EnabledAt     time.Time `json:"enabled_at,omitempty"`
+}
+
+// TwoFactorCode stores the input of the two factor confirm, disable and login requests
+type TwoFactorCode struct {
+	Challenge string `json:"challenge,omitempty"`
+	Code      string `json:"code,omitempty"`
+}
@@ -1,40 +1,78 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
 	"github.com/JECSand/eventit-server/domains/shared/enums"
-	"golang.org/x/crypto/bcrypt"
 	"time"
 )
 
 // User is a root struct that is used to store the json encoded data for/from a mongodb user doc.
 type User struct {
-	Id        string     `json:"id,omitempty"`
-	Username  string     `json:"username,omitempty"`
-	Password  string     `json:"password,omitempty"`
-	FirstName string     `json:"firstname,omitempty"`
-	LastName  string     `json:"lastname,omitempty"`
-	Email     string     `json:"email,omitempty"`
-	Role      enums.Role `json:"role,omitempty"`
-	UpdatedAt time.Time  `json:"updated_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at,omitempty"`
-	DeletedAt time.Time  `json:"deleted_at,omitempty"`
-}
-
-// HashPassword hashes a user password and associates it with the user struct
+	Id       string `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// CurrentPassword confirms a change of the password of a user by the user itself, it is never stored
+	CurrentPassword string     `json:"current_password,omitempty"`
+	FirstName       string     `json:"firstname,omitempty"`
+	LastName        string     `json:"lastname,omitempty"`
+	Email           string     `json:"email,omitempty"`
+	Role            enums.Role `json:"role,omitempty"`
+	EmailVerifiedAt time.Time  `json:"email_verified_at,omitempty"`
+	LockedUntil     time.Time  `json:"locked_until,omitempty"`
+	UnlockedAt      time.Time  `json:"unlocked_at,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at,omitempty"`
+	DeletedAt       time.Time  `json:"deleted_at,omitempty"`
+}
+
+// HashPassword hashes a user password with the configured auth.PasswordHasher and associates it with the user struct
 func (g *User) HashPassword() error {
 	if len(g.Password) != 0 {
-		password := []byte(g.Password)
-		hashedPassword, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+		hashedPassword, err := auth.HashPassword(g.Password)
 		if err != nil {
 			return err
 		}
-		g.Password = string(hashedPassword)
+		g.Password = hashedPassword
 		return nil
 	}
 	return errors.New("no password set to hash in user model")
 }
 
+// CheckPassword compares an input password with the hashed password of the User
+func (g *User) CheckPassword(checkPassword string) error {
+	_, err := g.VerifyPassword(checkPassword)
+	return err
+}
+
+// VerifyPassword compares an input password with the hashed password of the User, returning whether the hash
+// uses an outdated algorithm or parameters and should be replaced
+func (g *User) VerifyPassword(checkPassword string) (rehash bool, err error) {
+	if len(g.Password) == 0 {
+		return false, errors.New("no password set to hash in user model")
+	}
+	return auth.VerifyPassword(g.Password, checkPassword)
+}
+
+// EmailVerified returns whether the User has verified its email address
+func (g *User) EmailVerified() bool {
+	return !g.EmailVerifiedAt.IsZero()
+}
+
+// Locked returns whether the User is locked out of password logins after too many failed attempts
+func (g *User) Locked() bool {
+	return time.Now().Before(g.LockedUntil)
+}
+
+// MarshalJSON encodes the User without its password hash so that it is never serialized in a response
+func (g User) MarshalJSON() ([]byte, error) {
+	type user User
+	u := user(g)
+	u.Password = ""
+	return json.Marshal(u)
+}
+
 // UsersPage Multiple Users in a paginated response
 type UsersPage struct {
 	TotalCount int64   `json:"total_count"`
@@ -42,6 +80,8 @@ type UsersPage struct {
 	Page       int64   `json:"page"`
 	Size       int64   `json:"size"`
 	HasMore    bool    `json:"has_more"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
 	Users      []*User `json:"users"`
 }
 
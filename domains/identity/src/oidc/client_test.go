@@ -0,0 +1,160 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// stubProvider is a local OpenID Connect provider issuing id tokens for a single authorization code
+type stubProvider struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	audience  string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &stubProvider{key: key, audience: "eventit"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                p.URL,
+			AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint:         p.URL + "/token",
+			JWKSURI:               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(auth.JWKSet{Keys: []auth.JWK{{
+			Kty: "RSA",
+			Kid: "stub-1",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "stub-code" || PKCEChallenge(r.PostFormValue("code_verifier")) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, IDTokenClaims{
+			Nonce:         p.nonce,
+			Email:         "attendee@example.com",
+			EmailVerified: true,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    p.URL,
+				Subject:   "stub-subject",
+				Audience:  jwt.ClaimStrings{p.audience},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+			},
+		})
+		idToken.Header["kid"] = "stub-1"
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(TokenResponse{AccessToken: "stub-access", TokenType: "Bearer", IDToken: signed})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// authorize mimics the provider authenticating the user, recording the challenge and nonce of the auth url
+func (p *stubProvider) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("AuthCodeURL() did not use S256 PKCE: %s", authURL)
+	}
+	p.challenge = u.Query().Get("code_challenge")
+	p.nonce = u.Query().Get("nonce")
+}
+
+func TestClient_AuthorizationCodeFlow(t *testing.T) {
+	provider := newStubProvider(t)
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name     string // The name of the test
+		audience string // The audience the provider issues the id token for
+		nonce    string // The nonce expected when verifying the id token
+		wantErr  bool   // whether we want an error.
+	}{
+		{"valid id token", "eventit", "stub-nonce", false},
+		{"nonce mismatch", "eventit", "other-nonce", true},
+		{"audience mismatch", "another-client", "stub-nonce", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := NewClient(ProviderConfig{
+				Name:        "stub",
+				Issuer:      provider.URL,
+				ClientID:    "eventit",
+				RedirectURL: "http://localhost:3000/auth/oidc/stub/callback",
+			}, provider.Client())
+			verifier, err := NewPKCEVerifier()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authURL, err := client.AuthCodeURL(ctx, "stub-state", "stub-nonce", verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			provider.authorize(t, authURL)
+			provider.audience = tt.audience
+			tokens, err := client.Exchange(ctx, "stub-code", verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := client.VerifyIDToken(ctx, tokens.IDToken, tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyIDToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && (claims.Subject != "stub-subject" || !claims.EmailVerified) {
+				t.Errorf("VerifyIDToken() claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestClient_ExchangeRejectsWrongVerifier(t *testing.T) {
+	provider := newStubProvider(t)
+	ctx := context.Background()
+	client := NewClient(ProviderConfig{Name: "stub", Issuer: provider.URL, ClientID: "eventit"}, provider.Client())
+	verifier, err := NewPKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := client.AuthCodeURL(ctx, "stub-state", "stub-nonce", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.authorize(t, authURL)
+	if _, err = client.Exchange(ctx, "stub-code", "not-the-verifier"); err == nil {
+		t.Errorf("Exchange() accepted a mismatched PKCE code verifier")
+	}
+}
@@ -0,0 +1,226 @@
+package repositories
+
+import (
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// APIKeyRepo is used by the app to manage all api key related controllers and functionality
+type APIKeyRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*APIKeyRecord]
+}
+
+// NewAPIKeyRepo is an exported function used to initialize a new APIKeyRepo struct
+func NewAPIKeyRepo(db databases.DBClient) *APIKeyRepo {
+	collection := db.GetCollection("api_keys")
+	repoHandler := &databases.DBRepo[*APIKeyRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &APIKeyRepo{collection, db, repoHandler}
+}
+
+// EnsureIndexes creates the unique api key hash lookup index
+func (k *APIKeyRepo) EnsureIndexes() error {
+	return k.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "key_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+}
+
+// APIKeyRecord stores a hashed api key along with its scopes
+type APIKeyRecord struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name       string             `json:"name" bson:"name,omitempty"`
+	Prefix     string             `json:"prefix" bson:"prefix,omitempty"`
+	KeyHash    string             `json:"key_hash" bson:"key_hash,omitempty"`
+	Scopes     []string           `json:"scopes" bson:"scopes,omitempty"`
+	Role       enums.Role         `json:"role" bson:"role,omitempty"`
+	CreatedBy  primitive.ObjectID `json:"created_by" bson:"created_by,omitempty"`
+	ExpiresAt  time.Time          `json:"expires_at" bson:"expires_at,omitempty"`
+	RevokedAt  time.Time          `json:"revoked_at" bson:"revoked_at,omitempty"`
+	LastUsedAt time.Time          `json:"last_used_at" bson:"last_used_at,omitempty"`
+	UpdatedAt  time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewAPIKeyRecord initializes a new pointer to an APIKeyRecord struct from a pointer to a JSON APIKey struct
+func NewAPIKeyRecord(k *models.APIKey) (km *APIKeyRecord, err error) {
+	km = &APIKeyRecord{
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		KeyHash:    k.KeyHash,
+		Scopes:     k.Scopes,
+		Role:       k.Role,
+		ExpiresAt:  k.ExpiresAt,
+		RevokedAt:  k.RevokedAt,
+		LastUsedAt: k.LastUsedAt,
+		UpdatedAt:  k.UpdatedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+	if k.Id != "" && k.Id != "000000000000000000000000" {
+		if km.Id, err = primitive.ObjectIDFromHex(k.Id); err != nil {
+			return
+		}
+	}
+	if k.CreatedBy != "" && k.CreatedBy != "000000000000000000000000" {
+		km.CreatedBy, err = primitive.ObjectIDFromHex(k.CreatedBy)
+	}
+	return
+}
+
+// Update the APIKeyRecord using an overwrite bson doc
+func (k *APIKeyRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	km := APIKeyRecord{}
+	err = bson.Unmarshal(data, &km)
+	if len(km.Name) > 0 {
+		k.Name = km.Name
+	}
+	if len(km.Scopes) > 0 {
+		k.Scopes = km.Scopes
+	}
+	if !km.RevokedAt.IsZero() {
+		k.RevokedAt = km.RevokedAt
+	}
+	if !km.LastUsedAt.IsZero() {
+		k.LastUsedAt = km.LastUsedAt
+	}
+	if !km.UpdatedAt.IsZero() {
+		k.UpdatedAt = km.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the APIKeyRecord
+func (k *APIKeyRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, k)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the APIKeyRecord
+func (k *APIKeyRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	km := APIKeyRecord{}
+	err = bson.Unmarshal(data, &km)
+	if !km.Id.IsZero() {
+		return k.Id == km.Id
+	}
+	if km.KeyHash != "" {
+		return k.KeyHash == km.KeyHash
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the APIKeyRecord
+func (k *APIKeyRecord) GetID() (id interface{}) {
+	return k.Id
+}
+
+// SortFields returns the fields a paginated list of APIKeyRecords may be ordered by
+func (k *APIKeyRecord) SortFields() []string {
+	return []string{"name", "prefix", "role", "expires_at", "last_used_at", "updated_at", "created_at"}
+}
+
+// AddTimeStamps updates an APIKeyRecord struct with a timestamp
+func (k *APIKeyRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	k.UpdatedAt = currentTime
+	if newRecord {
+		k.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if an APIKeyRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (k *APIKeyRecord) AddObjectID() {
+	if k.Id.Hex() == "" || k.Id.Hex() == "000000000000000000000000" {
+		k.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates an APIKeyRecord struct postProcess to do things such as validating required fields
+func (k *APIKeyRecord) PostProcess() (err error) {
+	if k.KeyHash == "" {
+		err = errors.New("api key record does not have a KeyHash")
+	}
+	return
+}
+
+// ToDoc converts the bson APIKeyRecord into a bson.D
+func (k *APIKeyRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(k)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the APIKeyRecord data
+func (k *APIKeyRecord) BsonFilter() (doc bson.D, err error) {
+	if k.KeyHash != "" {
+		doc = bson.D{{Key: "key_hash", Value: k.KeyHash}}
+	} else if !k.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: k.Id}}
+	} else if !k.CreatedBy.IsZero() {
+		doc = bson.D{{Key: "created_by", Value: k.CreatedBy}}
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the APIKeyRecord data
+func (k *APIKeyRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := k.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to an APIKey JSON struct from a pointer to a BSON APIKeyRecord
+func (k *APIKeyRecord) ToRoot() *models.APIKey {
+	return &models.APIKey{
+		Id:         k.Id.Hex(),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		KeyHash:    k.KeyHash,
+		Scopes:     k.Scopes,
+		Role:       k.Role,
+		CreatedBy:  k.CreatedBy.Hex(),
+		ExpiresAt:  k.ExpiresAt,
+		RevokedAt:  k.RevokedAt,
+		LastUsedAt: k.LastUsedAt,
+		UpdatedAt:  k.UpdatedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+// LoadAPIKeyRecords ..
+func LoadAPIKeyRecords(ms []*APIKeyRecord) (keys []*models.APIKey) {
+	keys = make([]*models.APIKey, 0, len(ms))
+	for _, m := range ms {
+		keys = append(keys, m.ToRoot())
+	}
+	return
+}
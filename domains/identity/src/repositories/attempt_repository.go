@@ -0,0 +1,241 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// LoginAttemptRepo is used by the app to track failed logins per email and per client ip
+type LoginAttemptRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*LoginAttemptRecord]
+}
+
+// NewLoginAttemptRepo is an exported function used to initialize a new LoginAttemptRepo struct
+func NewLoginAttemptRepo(db databases.DBClient) *LoginAttemptRepo {
+	collection := db.GetCollection("login_attempts")
+	repoHandler := &databases.DBRepo[*LoginAttemptRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &LoginAttemptRepo{collection, db, repoHandler}
+}
+
+// EnsureIndexes creates the unique attempt key index along with a TTL index forgetting failures once they expire
+func (l *LoginAttemptRepo) EnsureIndexes() error {
+	if err := l.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+	return l.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+}
+
+// Find returns the failed login attempts tracked under a key, or an empty record when there are none
+func (l *LoginAttemptRepo) Find(key string) (*LoginAttemptRecord, error) {
+	rec, err := l.Handler.FindOne(&LoginAttemptRecord{Key: key})
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && time.Now().After(rec.ExpiresAt)) {
+		return &LoginAttemptRecord{Key: key}, nil
+	}
+	return rec, err
+}
+
+// RecordFailure atomically increments the failed attempts tracked under a key, forgetting them once no failure
+// happened for the input window
+func (l *LoginAttemptRepo) RecordFailure(key string, window time.Duration) (*LoginAttemptRecord, error) {
+	now := time.Now().UTC()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// failures left over from an expired window that the TTL monitor has not purged yet are reset first
+	_, err := l.collection.DeleteOne(ctx, bson.D{
+		{Key: "key", Value: key},
+		{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = l.collection.UpdateOne(ctx,
+		bson.D{{Key: "key", Value: key}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "failures", Value: 1}}},
+			{Key: "$set", Value: bson.D{
+				{Key: "last_failure_at", Value: now},
+				{Key: "expires_at", Value: now.Add(window)},
+				{Key: "updated_at", Value: now},
+			}},
+			{Key: "$setOnInsert", Value: bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "created_at", Value: now},
+			}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return l.Handler.FindOne(&LoginAttemptRecord{Key: key})
+}
+
+// Lock blocks logins under a key until the input time
+func (l *LoginAttemptRepo) Lock(key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := l.collection.UpdateOne(ctx,
+		bson.D{{Key: "key", Value: key}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "locked_until", Value: until},
+			{Key: "expires_at", Value: until},
+			{Key: "updated_at", Value: time.Now().UTC()},
+		}}},
+	)
+	return err
+}
+
+// Clear forgets the failed attempts tracked under a key
+func (l *LoginAttemptRepo) Clear(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := l.collection.DeleteMany(ctx, bson.D{{Key: "key", Value: key}})
+	return err
+}
+
+// LoginAttemptRecord stores the failed logins tracked under an email or client ip key
+type LoginAttemptRecord struct {
+	Id            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Key           string             `json:"key" bson:"key,omitempty"`
+	Failures      int                `json:"failures" bson:"failures,omitempty"`
+	LastFailureAt time.Time          `json:"last_failure_at" bson:"last_failure_at,omitempty"`
+	LockedUntil   time.Time          `json:"locked_until" bson:"locked_until,omitempty"`
+	ExpiresAt     time.Time          `json:"expires_at" bson:"expires_at,omitempty"`
+	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// Update the LoginAttemptRecord using an overwrite bson doc
+func (a *LoginAttemptRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	am := LoginAttemptRecord{}
+	err = bson.Unmarshal(data, &am)
+	if am.Failures > 0 {
+		a.Failures = am.Failures
+	}
+	if !am.LastFailureAt.IsZero() {
+		a.LastFailureAt = am.LastFailureAt
+	}
+	if !am.LockedUntil.IsZero() {
+		a.LockedUntil = am.LockedUntil
+	}
+	if !am.ExpiresAt.IsZero() {
+		a.ExpiresAt = am.ExpiresAt
+	}
+	if !am.UpdatedAt.IsZero() {
+		a.UpdatedAt = am.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the LoginAttemptRecord
+func (a *LoginAttemptRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, a)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the LoginAttemptRecord
+func (a *LoginAttemptRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	am := LoginAttemptRecord{}
+	err = bson.Unmarshal(data, &am)
+	if !am.Id.IsZero() {
+		return a.Id == am.Id
+	}
+	if am.Key != "" {
+		return a.Key == am.Key
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the LoginAttemptRecord
+func (a *LoginAttemptRecord) GetID() (id interface{}) {
+	return a.Id
+}
+
+// SortFields returns the fields a paginated list of LoginAttemptRecords may be ordered by
+func (a *LoginAttemptRecord) SortFields() []string {
+	return []string{"key", "failures", "last_failure_at", "updated_at", "created_at"}
+}
+
+// AddTimeStamps updates a LoginAttemptRecord struct with a timestamp
+func (a *LoginAttemptRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	a.UpdatedAt = currentTime
+	if newRecord {
+		a.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if a LoginAttemptRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (a *LoginAttemptRecord) AddObjectID() {
+	if a.Id.Hex() == "" || a.Id.Hex() == "000000000000000000000000" {
+		a.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates a LoginAttemptRecord struct postProcess to do things such as validating required fields
+func (a *LoginAttemptRecord) PostProcess() (err error) {
+	if a.Key == "" {
+		err = errors.New("login attempt record does not have a Key")
+	}
+	return
+}
+
+// ToDoc converts the bson LoginAttemptRecord into a bson.D
+func (a *LoginAttemptRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(a)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the LoginAttemptRecord data
+func (a *LoginAttemptRecord) BsonFilter() (doc bson.D, err error) {
+	if a.Key != "" {
+		doc = bson.D{{Key: "key", Value: a.Key}}
+	} else if !a.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: a.Id}}
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the LoginAttemptRecord data
+func (a *LoginAttemptRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := a.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
@@ -0,0 +1,239 @@
+package repositories
+
+import (
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// AuditRepo is used by the app to manage all audit log related controllers and functionality, audit events are
+// append only
+type AuditRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*AuditEventRecord]
+}
+
+// NewAuditRepo is an exported function used to initialize a new AuditRepo struct
+func NewAuditRepo(db databases.DBClient) *AuditRepo {
+	collection := db.GetCollection("audit_log")
+	repoHandler := &databases.DBRepo[*AuditEventRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &AuditRepo{collection, db, repoHandler}
+}
+
+// EnsureIndexes creates the actor and subject lookup indexes
+func (a *AuditRepo) EnsureIndexes() error {
+	if err := a.Handler.EnsureIndex(mongo.IndexModel{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}}); err != nil {
+		return err
+	}
+	return a.Handler.EnsureIndex(mongo.IndexModel{Keys: bson.D{{Key: "subject_id", Value: 1}, {Key: "created_at", Value: -1}}})
+}
+
+// AuditQuerySchema maps the query params the audit log may be filtered by to the fields of AuditEventRecords
+var AuditQuerySchema = databases.QuerySchema{
+	Params: map[string]databases.QueryParam{
+		"action":     {Field: "action", Ops: []databases.QueryOp{databases.OpIn}},
+		"actor_id":   {Field: "actor_id", Parse: databases.ObjectIDValue, Ops: []databases.QueryOp{databases.OpIn}},
+		"subject_id": {Field: "subject_id", Parse: databases.ObjectIDValue, Ops: []databases.QueryOp{databases.OpIn}},
+		"session_id": {Field: "session_id", Parse: databases.ObjectIDValue, Ops: []databases.QueryOp{databases.OpIn}},
+		"ip":         {Field: "ip", Ops: []databases.QueryOp{databases.OpPrefix}},
+		"created_at": {Field: "created_at", Parse: databases.TimeValue, Ops: []databases.QueryOp{databases.OpGt, databases.OpGte, databases.OpLt, databases.OpLte}},
+	},
+}
+
+// AuditEventRecord stores an action recorded by the audit log
+type AuditEventRecord struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Action    models.AuditAction `json:"action" bson:"action,omitempty"`
+	ActorId   primitive.ObjectID `json:"actor_id" bson:"actor_id,omitempty"`
+	SubjectId primitive.ObjectID `json:"subject_id" bson:"subject_id,omitempty"`
+	SessionId primitive.ObjectID `json:"session_id" bson:"session_id,omitempty"`
+	IP        string             `json:"ip" bson:"ip,omitempty"`
+	UserAgent string             `json:"user_agent" bson:"user_agent,omitempty"`
+	Data      map[string]string  `json:"data" bson:"data,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewAuditEventRecord initializes a new pointer to an AuditEventRecord struct from a pointer to a JSON AuditEvent struct
+func NewAuditEventRecord(e *models.AuditEvent) (em *AuditEventRecord, err error) {
+	em = &AuditEventRecord{
+		Action:    e.Action,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Data:      e.Data,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.Id != "" && e.Id != "000000000000000000000000" {
+		if em.Id, err = primitive.ObjectIDFromHex(e.Id); err != nil {
+			return
+		}
+	}
+	if e.ActorId != "" && e.ActorId != "000000000000000000000000" {
+		if em.ActorId, err = primitive.ObjectIDFromHex(e.ActorId); err != nil {
+			return
+		}
+	}
+	if e.SubjectId != "" && e.SubjectId != "000000000000000000000000" {
+		if em.SubjectId, err = primitive.ObjectIDFromHex(e.SubjectId); err != nil {
+			return
+		}
+	}
+	if e.SessionId != "" && e.SessionId != "000000000000000000000000" {
+		em.SessionId, err = primitive.ObjectIDFromHex(e.SessionId)
+	}
+	return
+}
+
+// Update the AuditEventRecord using an overwrite bson doc, audit events are immutable so only its timestamp changes
+func (e *AuditEventRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	em := AuditEventRecord{}
+	err = bson.Unmarshal(data, &em)
+	if !em.UpdatedAt.IsZero() {
+		e.UpdatedAt = em.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the AuditEventRecord
+func (e *AuditEventRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, e)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the AuditEventRecord
+func (e *AuditEventRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	em := AuditEventRecord{}
+	err = bson.Unmarshal(data, &em)
+	if !em.Id.IsZero() {
+		return e.Id == em.Id
+	}
+	if !em.ActorId.IsZero() {
+		return e.ActorId == em.ActorId
+	}
+	if !em.SubjectId.IsZero() {
+		return e.SubjectId == em.SubjectId
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the AuditEventRecord
+func (e *AuditEventRecord) GetID() (id interface{}) {
+	return e.Id
+}
+
+// SortFields returns the fields a paginated list of AuditEventRecords may be ordered by
+func (e *AuditEventRecord) SortFields() []string {
+	return []string{"action", "created_at"}
+}
+
+// AddTimeStamps updates an AuditEventRecord struct with a timestamp
+func (e *AuditEventRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	e.UpdatedAt = currentTime
+	if newRecord {
+		e.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if an AuditEventRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (e *AuditEventRecord) AddObjectID() {
+	if e.Id.Hex() == "" || e.Id.Hex() == "000000000000000000000000" {
+		e.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates an AuditEventRecord struct postProcess to do things such as validating required fields
+func (e *AuditEventRecord) PostProcess() (err error) {
+	if e.Action == "" {
+		err = errors.New("audit event record does not have an Action")
+	}
+	return
+}
+
+// ToDoc converts the bson AuditEventRecord into a bson.D
+func (e *AuditEventRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(e)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the AuditEventRecord data
+func (e *AuditEventRecord) BsonFilter() (doc bson.D, err error) {
+	if !e.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: e.Id}}
+	}
+	if e.Action != "" {
+		doc = append(doc, bson.E{Key: "action", Value: e.Action})
+	}
+	if !e.ActorId.IsZero() {
+		doc = append(doc, bson.E{Key: "actor_id", Value: e.ActorId})
+	}
+	if !e.SubjectId.IsZero() {
+		doc = append(doc, bson.E{Key: "subject_id", Value: e.SubjectId})
+	}
+	if !e.SessionId.IsZero() {
+		doc = append(doc, bson.E{Key: "session_id", Value: e.SessionId})
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the AuditEventRecord data
+func (e *AuditEventRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := e.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to an AuditEvent JSON struct from a pointer to a BSON AuditEventRecord
+func (e *AuditEventRecord) ToRoot() *models.AuditEvent {
+	event := &models.AuditEvent{
+		Id:        e.Id.Hex(),
+		Action:    e.Action,
+		ActorId:   e.ActorId.Hex(),
+		SubjectId: e.SubjectId.Hex(),
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Data:      e.Data,
+		CreatedAt: e.CreatedAt,
+	}
+	if !e.SessionId.IsZero() {
+		event.SessionId = e.SessionId.Hex()
+	}
+	return event
+}
+
+// LoadAuditEventRecords ..
+func LoadAuditEventRecords(ms []*AuditEventRecord) (events []*models.AuditEvent) {
+	events = make([]*models.AuditEvent, 0, len(ms))
+	for _, m := range ms {
+		events = append(events, m.ToRoot())
+	}
+	return
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/JECSand/eventit-server/domains/shared/utilities"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
 )
 
@@ -27,9 +29,24 @@ func NewBlacklistRepo(db databases.DBClient) *BlacklistRepo {
 	return &BlacklistRepo{collection, db, repoHandler}
 }
 
+// EnsureIndexes creates the blacklist lookup index along with a TTL index purging records once their token expires
+func (b *BlacklistRepo) EnsureIndexes() error {
+	if err := b.Handler.EnsureIndex(mongo.IndexModel{
+		Keys: bson.D{{Key: "auth_token", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	return b.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+}
+
+// BlacklistRecord stores a revoked auth token until it expires
 type BlacklistRecord struct {
 	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	AuthToken string             `json:"auth_token" bson:"auth_token,omitempty"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at,omitempty"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at,omitempty"`
 }
@@ -38,6 +55,7 @@ type BlacklistRecord struct {
 func NewBlacklistRecord(bl *models.Blacklist) (bm *BlacklistRecord, err error) {
 	bm = &BlacklistRecord{
 		AuthToken: bl.AuthToken,
+		ExpiresAt: bl.ExpiresAt,
 		UpdatedAt: bl.UpdatedAt,
 		CreatedAt: bl.CreatedAt,
 	}
@@ -58,6 +76,9 @@ func (b *BlacklistRecord) Update(doc interface{}) (err error) {
 	if len(bm.AuthToken) > 0 {
 		b.AuthToken = bm.AuthToken
 	}
+	if !bm.ExpiresAt.IsZero() {
+		b.ExpiresAt = bm.ExpiresAt
+	}
 	if !bm.UpdatedAt.IsZero() {
 		b.UpdatedAt = bm.UpdatedAt
 	}
@@ -96,6 +117,11 @@ func (b *BlacklistRecord) GetID() (id interface{}) {
 	return b.Id
 }
 
+// SortFields returns the fields a paginated list of BlacklistRecords may be ordered by
+func (b *BlacklistRecord) SortFields() []string {
+	return []string{"expires_at", "created_at"}
+}
+
 // AddTimeStamps updates a blacklistModel struct with a timestamp
 func (b *BlacklistRecord) AddTimeStamps(newRecord bool) {
 	currentTime := time.Now().UTC()
@@ -133,9 +159,9 @@ func (b *BlacklistRecord) ToDoc() (doc bson.D, err error) {
 // BsonFilter generates a bson filter for MongoDB queries from the blacklistModel data
 func (b *BlacklistRecord) BsonFilter() (doc bson.D, err error) {
 	if b.AuthToken != "" {
-		doc = bson.D{{"auth_token", b.AuthToken}}
+		doc = bson.D{{Key: "auth_token", Value: b.AuthToken}}
 	} else if b.Id.Hex() != "" && b.Id.Hex() != "000000000000000000000000" {
-		doc = bson.D{{"_id", b.Id}}
+		doc = bson.D{{Key: "_id", Value: b.Id}}
 	}
 	return
 }
@@ -146,7 +172,7 @@ func (b *BlacklistRecord) BsonUpdate() (doc bson.D, err error) {
 	if err != nil {
 		return
 	}
-	doc = bson.D{{"$set", inner}}
+	doc = bson.D{{Key: "$set", Value: inner}}
 	return
 }
 
@@ -155,6 +181,7 @@ func (b *BlacklistRecord) ToRoot() *models.Blacklist {
 	return &models.Blacklist{
 		Id:        b.Id.Hex(),
 		AuthToken: b.AuthToken,
+		ExpiresAt: b.ExpiresAt,
 		UpdatedAt: b.UpdatedAt,
 		CreatedAt: b.CreatedAt,
 	}
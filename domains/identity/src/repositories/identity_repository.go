@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// LinkedIdentityRepo is used by the app to manage all linked identity related controllers and functionality
+type LinkedIdentityRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*LinkedIdentityRecord]
+}
+
+// NewLinkedIdentityRepo is an exported function used to initialize a new LinkedIdentityRepo struct
+func NewLinkedIdentityRepo(db databases.DBClient) *LinkedIdentityRepo {
+	collection := db.GetCollection("linked_identities")
+	repoHandler := &databases.DBRepo[*LinkedIdentityRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &LinkedIdentityRepo{collection, db, repoHandler}
+}
+
+// EnsureIndexes creates the unique provider subject index, an external identity is linked to at most one user
+func (l *LinkedIdentityRepo) EnsureIndexes() error {
+	return l.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+}
+
+// LinkedIdentityRecord stores an external OpenID Connect identity linked to a user
+type LinkedIdentityRecord struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId      primitive.ObjectID `json:"user_id" bson:"user_id,omitempty"`
+	Provider    string             `json:"provider" bson:"provider,omitempty"`
+	Subject     string             `json:"subject" bson:"subject,omitempty"`
+	Email       string             `json:"email" bson:"email,omitempty"`
+	LastLoginAt time.Time          `json:"last_login_at" bson:"last_login_at,omitempty"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewLinkedIdentityRecord initializes a new pointer to a LinkedIdentityRecord struct from a pointer to a JSON LinkedIdentity struct
+func NewLinkedIdentityRecord(i *models.LinkedIdentity) (im *LinkedIdentityRecord, err error) {
+	im = &LinkedIdentityRecord{
+		Provider:    i.Provider,
+		Subject:     i.Subject,
+		Email:       i.Email,
+		LastLoginAt: i.LastLoginAt,
+		UpdatedAt:   i.UpdatedAt,
+		CreatedAt:   i.CreatedAt,
+	}
+	if i.Id != "" && i.Id != "000000000000000000000000" {
+		if im.Id, err = primitive.ObjectIDFromHex(i.Id); err != nil {
+			return
+		}
+	}
+	if i.UserId != "" && i.UserId != "000000000000000000000000" {
+		im.UserId, err = primitive.ObjectIDFromHex(i.UserId)
+	}
+	return
+}
+
+// Update the LinkedIdentityRecord using an overwrite bson doc
+func (i *LinkedIdentityRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	im := LinkedIdentityRecord{}
+	err = bson.Unmarshal(data, &im)
+	if len(im.Email) > 0 {
+		i.Email = im.Email
+	}
+	if !im.LastLoginAt.IsZero() {
+		i.LastLoginAt = im.LastLoginAt
+	}
+	if !im.UpdatedAt.IsZero() {
+		i.UpdatedAt = im.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the LinkedIdentityRecord
+func (i *LinkedIdentityRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, i)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the LinkedIdentityRecord
+func (i *LinkedIdentityRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	im := LinkedIdentityRecord{}
+	err = bson.Unmarshal(data, &im)
+	if !im.Id.IsZero() {
+		return i.Id == im.Id
+	}
+	if im.Provider != "" && im.Subject != "" {
+		return i.Provider == im.Provider && i.Subject == im.Subject
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the LinkedIdentityRecord
+func (i *LinkedIdentityRecord) GetID() (id interface{}) {
+	return i.Id
+}
+
+// SortFields returns the fields a paginated list of LinkedIdentityRecords may be ordered by
+func (i *LinkedIdentityRecord) SortFields() []string {
+	return []string{"provider", "email", "last_login_at", "updated_at", "created_at"}
+}
+
+// AddTimeStamps updates a LinkedIdentityRecord struct with a timestamp
+func (i *LinkedIdentityRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	i.UpdatedAt = currentTime
+	if newRecord {
+		i.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if a LinkedIdentityRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (i *LinkedIdentityRecord) AddObjectID() {
+	if i.Id.Hex() == "" || i.Id.Hex() == "000000000000000000000000" {
+		i.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates a LinkedIdentityRecord struct postProcess to do things such as validating required fields
+func (i *LinkedIdentityRecord) PostProcess() (err error) {
+	if i.Provider == "" || i.Subject == "" {
+		err = errors.New("linked identity record does not have a Provider and Subject")
+	}
+	return
+}
+
+// ToDoc converts the bson LinkedIdentityRecord into a bson.D
+func (i *LinkedIdentityRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(i)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the LinkedIdentityRecord data
+func (i *LinkedIdentityRecord) BsonFilter() (doc bson.D, err error) {
+	if !i.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: i.Id}}
+		if !i.UserId.IsZero() {
+			doc = append(doc, bson.E{Key: "user_id", Value: i.UserId})
+		}
+		return
+	}
+	if i.Provider != "" && i.Subject != "" {
+		doc = bson.D{{Key: "provider", Value: i.Provider}, {Key: "subject", Value: i.Subject}}
+		return
+	}
+	if !i.UserId.IsZero() {
+		doc = append(doc, bson.E{Key: "user_id", Value: i.UserId})
+	}
+	if i.Provider != "" {
+		doc = append(doc, bson.E{Key: "provider", Value: i.Provider})
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the LinkedIdentityRecord data
+func (i *LinkedIdentityRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := i.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to a LinkedIdentity JSON struct from a pointer to a BSON LinkedIdentityRecord
+func (i *LinkedIdentityRecord) ToRoot() *models.LinkedIdentity {
+	return &models.LinkedIdentity{
+		Id:          i.Id.Hex(),
+		UserId:      i.UserId.Hex(),
+		Provider:    i.Provider,
+		Subject:     i.Subject,
+		Email:       i.Email,
+		LastLoginAt: i.LastLoginAt,
+		UpdatedAt:   i.UpdatedAt,
+		CreatedAt:   i.CreatedAt,
+	}
+}
+
+// LoadLinkedIdentityRecords ..
+func LoadLinkedIdentityRecords(ms []*LinkedIdentityRecord) (identities []*models.LinkedIdentity) {
+	identities = make([]*models.LinkedIdentity, 0, len(ms))
+	for _, m := range ms {
+		identities = append(identities, m.ToRoot())
+	}
+	return
+}
@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// MembershipRepo is used by the app to manage all membership related controllers and functionality, memberships
+// are owned by their organization so requests should go through Handler.WithContext
+type MembershipRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*MembershipRecord]
+}
+
+// NewMembershipRepo is an exported function used to initialize a new MembershipRepo struct
+func NewMembershipRepo(db databases.DBClient) *MembershipRepo {
+	collection := db.GetCollection("memberships")
+	repoHandler := &databases.DBRepo[*MembershipRecord]{
+		DB:         db,
+		Collection: collection,
+		OrgScoped:  true,
+	}
+	return &MembershipRepo{collection, db, repoHandler}
+}
+
+// EnsureIndexes creates the unique organization and user index along with the user lookup index
+func (m *MembershipRepo) EnsureIndexes() error {
+	if err := m.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+	return m.Handler.EnsureIndex(mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}})
+}
+
+// MembershipRecord stores the role of a user within an organization
+type MembershipRecord struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrganizationId primitive.ObjectID `json:"organization_id" bson:"organization_id,omitempty"`
+	UserId         primitive.ObjectID `json:"user_id" bson:"user_id,omitempty"`
+	Role           enums.Role         `json:"role" bson:"role,omitempty"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewMembershipRecord initializes a new pointer to a MembershipRecord struct from a pointer to a JSON Membership struct
+func NewMembershipRecord(ms *models.Membership) (mm *MembershipRecord, err error) {
+	mm = &MembershipRecord{
+		Role:      ms.Role,
+		UpdatedAt: ms.UpdatedAt,
+		CreatedAt: ms.CreatedAt,
+	}
+	if ms.Id != "" && ms.Id != "000000000000000000000000" {
+		if mm.Id, err = primitive.ObjectIDFromHex(ms.Id); err != nil {
+			return
+		}
+	}
+	if ms.OrganizationId != "" && ms.OrganizationId != "000000000000000000000000" {
+		if mm.OrganizationId, err = primitive.ObjectIDFromHex(ms.OrganizationId); err != nil {
+			return
+		}
+	}
+	if ms.UserId != "" && ms.UserId != "000000000000000000000000" {
+		mm.UserId, err = primitive.ObjectIDFromHex(ms.UserId)
+	}
+	return
+}
+
+// SetOrganizationID assigns the organization owning the MembershipRecord
+func (m *MembershipRecord) SetOrganizationID(id primitive.ObjectID) {
+	m.OrganizationId = id
+}
+
+// Update the MembershipRecord using an overwrite bson doc
+func (m *MembershipRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	mm := MembershipRecord{}
+	err = bson.Unmarshal(data, &mm)
+	if mm.Role.EnumIndex() > 0 {
+		m.Role = mm.Role
+	}
+	if !mm.UpdatedAt.IsZero() {
+		m.UpdatedAt = mm.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the MembershipRecord
+func (m *MembershipRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, m)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the MembershipRecord
+func (m *MembershipRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	mm := MembershipRecord{}
+	err = bson.Unmarshal(data, &mm)
+	if !mm.Id.IsZero() {
+		return m.Id == mm.Id
+	}
+	if !mm.OrganizationId.IsZero() && !mm.UserId.IsZero() {
+		return m.OrganizationId == mm.OrganizationId && m.UserId == mm.UserId
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the MembershipRecord
+func (m *MembershipRecord) GetID() (id interface{}) {
+	return m.Id
+}
+
+// SortFields returns the fields a paginated list of MembershipRecords may be ordered by
+func (m *MembershipRecord) SortFields() []string {
+	return []string{"role", "updated_at", "created_at"}
+}
+
+// AddTimeStamps updates a MembershipRecord struct with a timestamp
+func (m *MembershipRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	m.UpdatedAt = currentTime
+	if newRecord {
+		m.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if a MembershipRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (m *MembershipRecord) AddObjectID() {
+	if m.Id.Hex() == "" || m.Id.Hex() == "000000000000000000000000" {
+		m.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates a MembershipRecord struct postProcess to do things such as validating required fields
+func (m *MembershipRecord) PostProcess() (err error) {
+	if m.OrganizationId.IsZero() || m.UserId.IsZero() {
+		err = errors.New("membership record does not have an OrganizationId and a UserId")
+	}
+	return
+}
+
+// ToDoc converts the bson MembershipRecord into a bson.D
+func (m *MembershipRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(m)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the MembershipRecord data
+func (m *MembershipRecord) BsonFilter() (doc bson.D, err error) {
+	if !m.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: m.Id}}
+	}
+	if !m.OrganizationId.IsZero() {
+		doc = append(doc, bson.E{Key: "organization_id", Value: m.OrganizationId})
+	}
+	if !m.UserId.IsZero() {
+		doc = append(doc, bson.E{Key: "user_id", Value: m.UserId})
+	}
+	if m.Role.EnumIndex() > 0 {
+		doc = append(doc, bson.E{Key: "role", Value: m.Role})
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the MembershipRecord data
+func (m *MembershipRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := m.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to a Membership JSON struct from a pointer to a BSON MembershipRecord
+func (m *MembershipRecord) ToRoot() *models.Membership {
+	return &models.Membership{
+		Id:             m.Id.Hex(),
+		OrganizationId: m.OrganizationId.Hex(),
+		UserId:         m.UserId.Hex(),
+		Role:           m.Role,
+		UpdatedAt:      m.UpdatedAt,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+// LoadMembershipRecords ..
+func LoadMembershipRecords(ms []*MembershipRecord) (memberships []*models.Membership) {
+	memberships = make([]*models.Membership, 0, len(ms))
+	for _, m := range ms {
+		memberships = append(memberships, m.ToRoot())
+	}
+	return
+}
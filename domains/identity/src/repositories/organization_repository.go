@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// OrganizationRepo is used by the app to manage all organization related controllers and functionality
+type OrganizationRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*OrganizationRecord]
+}
+
+// NewOrganizationRepo is an exported function used to initialize a new OrganizationRepo struct
+func NewOrganizationRepo(db databases.DBClient) *OrganizationRepo {
+	collection := db.GetCollection("organizations")
+	repoHandler := &databases.DBRepo[*OrganizationRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &OrganizationRepo{collection, db, repoHandler}
+}
+
+// OrganizationRecord stores an organization, the tenant owning events and members
+type OrganizationRecord struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name,omitempty"`
+	CreatedBy primitive.ObjectID `json:"created_by" bson:"created_by,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewOrganizationRecord initializes a new pointer to an OrganizationRecord struct from a pointer to a JSON Organization struct
+func NewOrganizationRecord(o *models.Organization) (om *OrganizationRecord, err error) {
+	om = &OrganizationRecord{
+		Name:      o.Name,
+		UpdatedAt: o.UpdatedAt,
+		CreatedAt: o.CreatedAt,
+	}
+	if o.Id != "" && o.Id != "000000000000000000000000" {
+		if om.Id, err = primitive.ObjectIDFromHex(o.Id); err != nil {
+			return
+		}
+	}
+	if o.CreatedBy != "" && o.CreatedBy != "000000000000000000000000" {
+		om.CreatedBy, err = primitive.ObjectIDFromHex(o.CreatedBy)
+	}
+	return
+}
+
+// Update the OrganizationRecord using an overwrite bson doc
+func (o *OrganizationRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	om := OrganizationRecord{}
+	err = bson.Unmarshal(data, &om)
+	if len(om.Name) > 0 {
+		o.Name = om.Name
+	}
+	if !om.UpdatedAt.IsZero() {
+		o.UpdatedAt = om.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the OrganizationRecord
+func (o *OrganizationRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, o)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the OrganizationRecord
+func (o *OrganizationRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	om := OrganizationRecord{}
+	err = bson.Unmarshal(data, &om)
+	if !om.Id.IsZero() {
+		return o.Id == om.Id
+	}
+	if !om.CreatedBy.IsZero() {
+		return o.CreatedBy == om.CreatedBy
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the OrganizationRecord
+func (o *OrganizationRecord) GetID() (id interface{}) {
+	return o.Id
+}
+
+// SortFields returns the fields a paginated list of OrganizationRecords may be ordered by
+func (o *OrganizationRecord) SortFields() []string {
+	return []string{"name", "updated_at", "created_at"}
+}
+
+// AddTimeStamps updates an OrganizationRecord struct with a timestamp
+func (o *OrganizationRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	o.UpdatedAt = currentTime
+	if newRecord {
+		o.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if an OrganizationRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (o *OrganizationRecord) AddObjectID() {
+	if o.Id.Hex() == "" || o.Id.Hex() == "000000000000000000000000" {
+		o.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates an OrganizationRecord struct postProcess to do things such as validating required fields
+func (o *OrganizationRecord) PostProcess() (err error) {
+	if o.Name == "" {
+		err = errors.New("organization record does not have a Name")
+	}
+	return
+}
+
+// ToDoc converts the bson OrganizationRecord into a bson.D
+func (o *OrganizationRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(o)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the OrganizationRecord data
+func (o *OrganizationRecord) BsonFilter() (doc bson.D, err error) {
+	if !o.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: o.Id}}
+	}
+	if !o.CreatedBy.IsZero() {
+		doc = append(doc, bson.E{Key: "created_by", Value: o.CreatedBy})
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the OrganizationRecord data
+func (o *OrganizationRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := o.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to an Organization JSON struct from a pointer to a BSON OrganizationRecord
+func (o *OrganizationRecord) ToRoot() *models.Organization {
+	return &models.Organization{
+		Id:        o.Id.Hex(),
+		Name:      o.Name,
+		CreatedBy: o.CreatedBy.Hex(),
+		UpdatedAt: o.UpdatedAt,
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+// LoadOrganizationRecords ..
+func LoadOrganizationRecords(ms []*OrganizationRecord) (organizations []*models.Organization) {
+	organizations = make([]*models.Organization, 0, len(ms))
+	for _, m := range ms {
+		organizations = append(organizations, m.ToRoot())
+	}
+	return
+}
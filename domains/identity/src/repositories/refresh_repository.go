@@ -0,0 +1,237 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// RefreshTokenRepo is used by the app to manage all refresh token related controllers and functionality
+type RefreshTokenRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*RefreshTokenRecord]
+}
+
+// NewRefreshTokenRepo is an exported function used to initialize a new RefreshTokenRepo struct
+func NewRefreshTokenRepo(db databases.DBClient) *RefreshTokenRepo {
+	collection := db.GetCollection("refresh_tokens")
+	repoHandler := &databases.DBRepo[*RefreshTokenRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &RefreshTokenRepo{collection, db, repoHandler}
+}
+
+// Rotate atomically marks an active RefreshTokenRecord as rotated and replaced by the input id,
+// returning false when the record was already rotated or revoked
+func (r *RefreshTokenRepo) Rotate(rt *RefreshTokenRecord, replacedBy primitive.ObjectID) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: rt.Id},
+		{Key: "rotated_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	now := time.Now().UTC()
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "rotated_at", Value: now},
+		{Key: "replaced_by", Value: replacedBy},
+		{Key: "updated_at", Value: now},
+	}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// RefreshTokenRecord stores a hashed refresh token belonging to a token family
+type RefreshTokenRecord struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId     primitive.ObjectID `json:"user_id" bson:"user_id,omitempty"`
+	FamilyId   primitive.ObjectID `json:"family_id" bson:"family_id,omitempty"`
+	TokenHash  string             `json:"token_hash" bson:"token_hash,omitempty"`
+	ReplacedBy primitive.ObjectID `json:"replaced_by" bson:"replaced_by,omitempty"`
+	TwoFactor  bool               `json:"two_factor" bson:"two_factor,omitempty"`
+	ExpiresAt  time.Time          `json:"expires_at" bson:"expires_at,omitempty"`
+	RotatedAt  time.Time          `json:"rotated_at" bson:"rotated_at,omitempty"`
+	RevokedAt  time.Time          `json:"revoked_at" bson:"revoked_at,omitempty"`
+	UpdatedAt  time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewRefreshTokenRecord initializes a new pointer to a RefreshTokenRecord struct from a pointer to a JSON RefreshToken struct
+func NewRefreshTokenRecord(rt *models.RefreshToken) (rm *RefreshTokenRecord, err error) {
+	rm = &RefreshTokenRecord{
+		TokenHash: rt.TokenHash,
+		TwoFactor: rt.TwoFactor,
+		ExpiresAt: rt.ExpiresAt,
+		RotatedAt: rt.RotatedAt,
+		RevokedAt: rt.RevokedAt,
+		UpdatedAt: rt.UpdatedAt,
+		CreatedAt: rt.CreatedAt,
+	}
+	if rt.Id != "" && rt.Id != "000000000000000000000000" {
+		if rm.Id, err = primitive.ObjectIDFromHex(rt.Id); err != nil {
+			return
+		}
+	}
+	if rt.UserId != "" && rt.UserId != "000000000000000000000000" {
+		if rm.UserId, err = primitive.ObjectIDFromHex(rt.UserId); err != nil {
+			return
+		}
+	}
+	if rt.FamilyId != "" && rt.FamilyId != "000000000000000000000000" {
+		if rm.FamilyId, err = primitive.ObjectIDFromHex(rt.FamilyId); err != nil {
+			return
+		}
+	}
+	if rt.ReplacedBy != "" && rt.ReplacedBy != "000000000000000000000000" {
+		rm.ReplacedBy, err = primitive.ObjectIDFromHex(rt.ReplacedBy)
+	}
+	return
+}
+
+// Update the RefreshTokenRecord using an overwrite bson doc
+func (r *RefreshTokenRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	rm := RefreshTokenRecord{}
+	err = bson.Unmarshal(data, &rm)
+	if !rm.ReplacedBy.IsZero() {
+		r.ReplacedBy = rm.ReplacedBy
+	}
+	if !rm.RotatedAt.IsZero() {
+		r.RotatedAt = rm.RotatedAt
+	}
+	if !rm.RevokedAt.IsZero() {
+		r.RevokedAt = rm.RevokedAt
+	}
+	if !rm.UpdatedAt.IsZero() {
+		r.UpdatedAt = rm.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the RefreshTokenRecord
+func (r *RefreshTokenRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, r)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the RefreshTokenRecord
+func (r *RefreshTokenRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	rm := RefreshTokenRecord{}
+	err = bson.Unmarshal(data, &rm)
+	if !rm.Id.IsZero() {
+		return r.Id == rm.Id
+	}
+	if rm.TokenHash != "" {
+		return r.TokenHash == rm.TokenHash
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the RefreshTokenRecord
+func (r *RefreshTokenRecord) GetID() (id interface{}) {
+	return r.Id
+}
+
+// SortFields returns the fields a paginated list of RefreshTokenRecords may be ordered by
+func (r *RefreshTokenRecord) SortFields() []string {
+	return []string{"expires_at", "created_at"}
+}
+
+// AddTimeStamps updates a RefreshTokenRecord struct with a timestamp
+func (r *RefreshTokenRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	r.UpdatedAt = currentTime
+	if newRecord {
+		r.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if a RefreshTokenRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (r *RefreshTokenRecord) AddObjectID() {
+	if r.Id.Hex() == "" || r.Id.Hex() == "000000000000000000000000" {
+		r.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates a RefreshTokenRecord struct postProcess to do things such as validating required fields
+func (r *RefreshTokenRecord) PostProcess() (err error) {
+	if r.TokenHash == "" {
+		err = errors.New("refresh token record does not have a TokenHash")
+	}
+	return
+}
+
+// ToDoc converts the bson RefreshTokenRecord into a bson.D
+func (r *RefreshTokenRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(r)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the RefreshTokenRecord data
+func (r *RefreshTokenRecord) BsonFilter() (doc bson.D, err error) {
+	if r.TokenHash != "" {
+		doc = bson.D{{Key: "token_hash", Value: r.TokenHash}}
+	} else if !r.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: r.Id}}
+	} else if !r.FamilyId.IsZero() {
+		doc = bson.D{{Key: "family_id", Value: r.FamilyId}}
+	} else if !r.UserId.IsZero() {
+		doc = bson.D{{Key: "user_id", Value: r.UserId}}
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the RefreshTokenRecord data
+func (r *RefreshTokenRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := r.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to a RefreshToken JSON struct from a pointer to a BSON RefreshTokenRecord
+func (r *RefreshTokenRecord) ToRoot() *models.RefreshToken {
+	rt := &models.RefreshToken{
+		Id:        r.Id.Hex(),
+		UserId:    r.UserId.Hex(),
+		FamilyId:  r.FamilyId.Hex(),
+		TokenHash: r.TokenHash,
+		TwoFactor: r.TwoFactor,
+		ExpiresAt: r.ExpiresAt,
+		RotatedAt: r.RotatedAt,
+		RevokedAt: r.RevokedAt,
+		UpdatedAt: r.UpdatedAt,
+		CreatedAt: r.CreatedAt,
+	}
+	if !r.ReplacedBy.IsZero() {
+		rt.ReplacedBy = r.ReplacedBy.Hex()
+	}
+	return rt
+}
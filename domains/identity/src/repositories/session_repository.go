@@ -0,0 +1,289 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// SessionRepo is used by the app to manage all session related controllers and functionality
+type SessionRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*SessionRecord]
+}
+
+// NewSessionRepo is an exported function used to initialize a new SessionRepo struct
+func NewSessionRepo(db databases.DBClient) *SessionRepo {
+	collection := db.GetCollection("sessions")
+	repoHandler := &databases.DBRepo[*SessionRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &SessionRepo{collection, db, repoHandler}
+}
+
+// EnsureIndexes creates the user lookup index and the TTL index removing sessions once they expired
+func (s *SessionRepo) EnsureIndexes() error {
+	if err := s.Handler.EnsureIndex(mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}}); err != nil {
+		return err
+	}
+	return s.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+}
+
+// Touch records activity on an active session from the input client, extending its expiry when expiresAt is set
+func (s *SessionRepo) Touch(id primitive.ObjectID, client *models.ClientInfo, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	set := bson.D{
+		{Key: "last_seen_at", Value: now},
+		{Key: "updated_at", Value: now},
+	}
+	if client != nil && client.IP != "" {
+		set = append(set, bson.E{Key: "ip", Value: client.IP})
+	}
+	if !expiresAt.IsZero() {
+		set = append(set, bson.E{Key: "expires_at", Value: expiresAt})
+	}
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.collection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: set}})
+	return err
+}
+
+// SetOrganization scopes the access tokens issued for a session to an organization, a nil id unscopes them
+func (s *SessionRepo) SetOrganization(id primitive.ObjectID, orgId primitive.ObjectID) error {
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "organization_id", Value: orgId},
+		{Key: "updated_at", Value: time.Now().UTC()},
+	}}}
+	if orgId.IsZero() {
+		update = bson.D{
+			{Key: "$unset", Value: bson.D{{Key: "organization_id", Value: ""}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now().UTC()}}},
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	return err
+}
+
+// SessionRecord stores a login session along with the client it was started from
+type SessionRecord struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId         primitive.ObjectID `json:"user_id" bson:"user_id,omitempty"`
+	OrganizationId primitive.ObjectID `json:"organization_id" bson:"organization_id,omitempty"`
+	ActorId        primitive.ObjectID `json:"actor_id" bson:"actor_id,omitempty"`
+	Device         string             `json:"device" bson:"device,omitempty"`
+	IP             string             `json:"ip" bson:"ip,omitempty"`
+	UserAgent      string             `json:"user_agent" bson:"user_agent,omitempty"`
+	TwoFactor      bool               `json:"two_factor" bson:"two_factor,omitempty"`
+	LastSeenAt     time.Time          `json:"last_seen_at" bson:"last_seen_at,omitempty"`
+	ExpiresAt      time.Time          `json:"expires_at" bson:"expires_at,omitempty"`
+	RevokedAt      time.Time          `json:"revoked_at" bson:"revoked_at,omitempty"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewSessionRecord initializes a new pointer to a SessionRecord struct from a pointer to a JSON UserSession struct
+func NewSessionRecord(s *models.UserSession) (sm *SessionRecord, err error) {
+	sm = &SessionRecord{
+		Device:     s.Device,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		TwoFactor:  s.TwoFactor,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		RevokedAt:  s.RevokedAt,
+		UpdatedAt:  s.UpdatedAt,
+		CreatedAt:  s.CreatedAt,
+	}
+	if s.Id != "" && s.Id != "000000000000000000000000" {
+		if sm.Id, err = primitive.ObjectIDFromHex(s.Id); err != nil {
+			return
+		}
+	}
+	if s.UserId != "" && s.UserId != "000000000000000000000000" {
+		if sm.UserId, err = primitive.ObjectIDFromHex(s.UserId); err != nil {
+			return
+		}
+	}
+	if s.OrganizationId != "" && s.OrganizationId != "000000000000000000000000" {
+		if sm.OrganizationId, err = primitive.ObjectIDFromHex(s.OrganizationId); err != nil {
+			return
+		}
+	}
+	if s.ActorId != "" && s.ActorId != "000000000000000000000000" {
+		sm.ActorId, err = primitive.ObjectIDFromHex(s.ActorId)
+	}
+	return
+}
+
+// Update the SessionRecord using an overwrite bson doc
+func (s *SessionRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	sm := SessionRecord{}
+	err = bson.Unmarshal(data, &sm)
+	if !sm.OrganizationId.IsZero() {
+		s.OrganizationId = sm.OrganizationId
+	}
+	if len(sm.IP) > 0 {
+		s.IP = sm.IP
+	}
+	if !sm.LastSeenAt.IsZero() {
+		s.LastSeenAt = sm.LastSeenAt
+	}
+	if !sm.ExpiresAt.IsZero() {
+		s.ExpiresAt = sm.ExpiresAt
+	}
+	if !sm.RevokedAt.IsZero() {
+		s.RevokedAt = sm.RevokedAt
+	}
+	if !sm.UpdatedAt.IsZero() {
+		s.UpdatedAt = sm.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the SessionRecord
+func (s *SessionRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, s)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the SessionRecord
+func (s *SessionRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	sm := SessionRecord{}
+	err = bson.Unmarshal(data, &sm)
+	if !sm.Id.IsZero() {
+		return s.Id == sm.Id
+	}
+	if !sm.UserId.IsZero() {
+		return s.UserId == sm.UserId
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the SessionRecord
+func (s *SessionRecord) GetID() (id interface{}) {
+	return s.Id
+}
+
+// SortFields returns the fields a paginated list of SessionRecords may be ordered by
+func (s *SessionRecord) SortFields() []string {
+	return []string{"last_seen_at", "expires_at", "created_at"}
+}
+
+// AddTimeStamps updates a SessionRecord struct with a timestamp
+func (s *SessionRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	s.UpdatedAt = currentTime
+	if newRecord {
+		s.CreatedAt = currentTime
+		s.LastSeenAt = currentTime
+	}
+}
+
+// AddObjectID checks if a SessionRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (s *SessionRecord) AddObjectID() {
+	if s.Id.Hex() == "" || s.Id.Hex() == "000000000000000000000000" {
+		s.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates a SessionRecord struct postProcess to do things such as validating required fields
+func (s *SessionRecord) PostProcess() (err error) {
+	if s.UserId.IsZero() {
+		err = errors.New("session record does not have a UserId")
+	}
+	return
+}
+
+// ToDoc converts the bson SessionRecord into a bson.D
+func (s *SessionRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(s)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the SessionRecord data
+func (s *SessionRecord) BsonFilter() (doc bson.D, err error) {
+	if !s.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: s.Id}}
+	}
+	if !s.UserId.IsZero() {
+		doc = append(doc, bson.E{Key: "user_id", Value: s.UserId})
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the SessionRecord data
+func (s *SessionRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := s.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to a UserSession JSON struct from a pointer to a BSON SessionRecord
+func (s *SessionRecord) ToRoot() *models.UserSession {
+	session := &models.UserSession{
+		Id:         s.Id.Hex(),
+		UserId:     s.UserId.Hex(),
+		Device:     s.Device,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		TwoFactor:  s.TwoFactor,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		RevokedAt:  s.RevokedAt,
+		UpdatedAt:  s.UpdatedAt,
+		CreatedAt:  s.CreatedAt,
+	}
+	if !s.OrganizationId.IsZero() {
+		session.OrganizationId = s.OrganizationId.Hex()
+	}
+	if !s.ActorId.IsZero() {
+		session.ActorId = s.ActorId.Hex()
+	}
+	return session
+}
+
+// LoadSessionRecords ..
+func LoadSessionRecords(ms []*SessionRecord) (sessions []*models.UserSession) {
+	sessions = make([]*models.UserSession, 0, len(ms))
+	for _, m := range ms {
+		sessions = append(sessions, m.ToRoot())
+	}
+	return
+}
@@ -0,0 +1,255 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// OneTimeTokenRepo is used by the app to manage all one time token related controllers and functionality
+type OneTimeTokenRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*OneTimeTokenRecord]
+}
+
+// NewOneTimeTokenRepo is an exported function used to initialize a new OneTimeTokenRepo struct
+func NewOneTimeTokenRepo(db databases.DBClient) *OneTimeTokenRepo {
+	collection := db.GetCollection("one_time_tokens")
+	repoHandler := &databases.DBRepo[*OneTimeTokenRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &OneTimeTokenRepo{collection, db, repoHandler}
+}
+
+// EnsureIndexes creates the token hash lookup index along with a TTL index purging records once they expire
+func (t *OneTimeTokenRepo) EnsureIndexes() error {
+	if err := t.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "token_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+	return t.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+}
+
+// Consume atomically marks an unused and unexpired token of the input purpose as used, returning its record
+func (t *OneTimeTokenRepo) Consume(tokenHash string, purpose models.TokenPurpose) (*OneTimeTokenRecord, error) {
+	now := time.Now().UTC()
+	filter := bson.D{
+		{Key: "token_hash", Value: tokenHash},
+		{Key: "purpose", Value: purpose},
+		{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "used_at", Value: now},
+		{Key: "updated_at", Value: now},
+	}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := t.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	if res.ModifiedCount != 1 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return t.Handler.FindOne(&OneTimeTokenRecord{TokenHash: tokenHash})
+}
+
+// Invalidate marks every unused token of the input purpose belonging to a user as used
+func (t *OneTimeTokenRepo) Invalidate(userId primitive.ObjectID, purpose models.TokenPurpose) error {
+	now := time.Now().UTC()
+	filter := bson.D{
+		{Key: "user_id", Value: userId},
+		{Key: "purpose", Value: purpose},
+		{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "used_at", Value: now},
+		{Key: "updated_at", Value: now},
+	}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := t.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// OneTimeTokenRecord stores a hashed single use token issued to a user for a purpose
+type OneTimeTokenRecord struct {
+	Id        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	UserId    primitive.ObjectID  `json:"user_id" bson:"user_id,omitempty"`
+	Purpose   models.TokenPurpose `json:"purpose" bson:"purpose,omitempty"`
+	TokenHash string              `json:"token_hash" bson:"token_hash,omitempty"`
+	Data      map[string]string   `json:"data" bson:"data,omitempty"`
+	ExpiresAt time.Time           `json:"expires_at" bson:"expires_at,omitempty"`
+	UsedAt    time.Time           `json:"used_at" bson:"used_at,omitempty"`
+	UpdatedAt time.Time           `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt time.Time           `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewOneTimeTokenRecord initializes a new pointer to a OneTimeTokenRecord struct from a pointer to a JSON OneTimeToken struct
+func NewOneTimeTokenRecord(t *models.OneTimeToken) (tm *OneTimeTokenRecord, err error) {
+	tm = &OneTimeTokenRecord{
+		Purpose:   t.Purpose,
+		TokenHash: t.TokenHash,
+		Data:      t.Data,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    t.UsedAt,
+		UpdatedAt: t.UpdatedAt,
+		CreatedAt: t.CreatedAt,
+	}
+	if t.Id != "" && t.Id != "000000000000000000000000" {
+		if tm.Id, err = primitive.ObjectIDFromHex(t.Id); err != nil {
+			return
+		}
+	}
+	if t.UserId != "" && t.UserId != "000000000000000000000000" {
+		tm.UserId, err = primitive.ObjectIDFromHex(t.UserId)
+	}
+	return
+}
+
+// Update the OneTimeTokenRecord using an overwrite bson doc
+func (t *OneTimeTokenRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	tm := OneTimeTokenRecord{}
+	err = bson.Unmarshal(data, &tm)
+	if !tm.UsedAt.IsZero() {
+		t.UsedAt = tm.UsedAt
+	}
+	if !tm.UpdatedAt.IsZero() {
+		t.UpdatedAt = tm.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the OneTimeTokenRecord
+func (t *OneTimeTokenRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, t)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the OneTimeTokenRecord
+func (t *OneTimeTokenRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	tm := OneTimeTokenRecord{}
+	err = bson.Unmarshal(data, &tm)
+	if !tm.Id.IsZero() {
+		return t.Id == tm.Id
+	}
+	if tm.TokenHash != "" {
+		return t.TokenHash == tm.TokenHash
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the OneTimeTokenRecord
+func (t *OneTimeTokenRecord) GetID() (id interface{}) {
+	return t.Id
+}
+
+// SortFields returns the fields a paginated list of OneTimeTokenRecords may be ordered by
+func (t *OneTimeTokenRecord) SortFields() []string {
+	return []string{"purpose", "expires_at", "created_at"}
+}
+
+// AddTimeStamps updates a OneTimeTokenRecord struct with a timestamp
+func (t *OneTimeTokenRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	t.UpdatedAt = currentTime
+	if newRecord {
+		t.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if a OneTimeTokenRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (t *OneTimeTokenRecord) AddObjectID() {
+	if t.Id.Hex() == "" || t.Id.Hex() == "000000000000000000000000" {
+		t.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates a OneTimeTokenRecord struct postProcess to do things such as validating required fields
+func (t *OneTimeTokenRecord) PostProcess() (err error) {
+	if t.TokenHash == "" {
+		err = errors.New("one time token record does not have a TokenHash")
+	}
+	return
+}
+
+// ToDoc converts the bson OneTimeTokenRecord into a bson.D
+func (t *OneTimeTokenRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(t)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the OneTimeTokenRecord data
+func (t *OneTimeTokenRecord) BsonFilter() (doc bson.D, err error) {
+	if t.TokenHash != "" {
+		doc = bson.D{{Key: "token_hash", Value: t.TokenHash}}
+		return
+	}
+	if !t.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: t.Id}}
+		return
+	}
+	if !t.UserId.IsZero() {
+		doc = append(doc, bson.E{Key: "user_id", Value: t.UserId})
+	}
+	if t.Purpose != "" {
+		doc = append(doc, bson.E{Key: "purpose", Value: t.Purpose})
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the OneTimeTokenRecord data
+func (t *OneTimeTokenRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := t.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to a OneTimeToken JSON struct from a pointer to a BSON OneTimeTokenRecord
+func (t *OneTimeTokenRecord) ToRoot() *models.OneTimeToken {
+	return &models.OneTimeToken{
+		Id:        t.Id.Hex(),
+		UserId:    t.UserId.Hex(),
+		Purpose:   t.Purpose,
+		TokenHash: t.TokenHash,
+		Data:      t.Data,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    t.UsedAt,
+		UpdatedAt: t.UpdatedAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
@@ -0,0 +1,244 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// TwoFactorRepo is used by the app to manage all two factor related controllers and functionality
+type TwoFactorRepo struct {
+	collection databases.DBCollection
+	db         databases.DBClient
+	Handler    *databases.DBRepo[*TwoFactorRecord]
+}
+
+// NewTwoFactorRepo is an exported function used to initialize a new TwoFactorRepo struct
+func NewTwoFactorRepo(db databases.DBClient) *TwoFactorRepo {
+	collection := db.GetCollection("two_factors")
+	repoHandler := &databases.DBRepo[*TwoFactorRecord]{
+		DB:         db,
+		Collection: collection,
+	}
+	return &TwoFactorRepo{collection, db, repoHandler}
+}
+
+// EnsureIndexes creates the unique user lookup index, a user has at most one two factor enrollment
+func (t *TwoFactorRepo) EnsureIndexes() error {
+	return t.Handler.EnsureIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+}
+
+// update applies an update to the TwoFactorRecord matching the filter, returning whether one was modified
+func (t *TwoFactorRepo) update(filter bson.D, update bson.D) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := t.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// UseStep atomically records a TOTP time step as used, returning false when it or a later step was already
+// used so that a code cannot be replayed
+func (t *TwoFactorRepo) UseStep(id primitive.ObjectID, step int64) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "last_used_step", Value: bson.D{{Key: "$lt", Value: step}}}},
+			bson.D{{Key: "last_used_step", Value: bson.D{{Key: "$exists", Value: false}}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "last_used_step", Value: step},
+		{Key: "updated_at", Value: time.Now().UTC()},
+	}}}
+	return t.update(filter, update)
+}
+
+// UseRecoveryCode atomically removes a hashed recovery code, returning false when it is unknown or already used
+func (t *TwoFactorRepo) UseRecoveryCode(id primitive.ObjectID, codeHash string) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "recovery_codes", Value: codeHash},
+	}
+	update := bson.D{
+		{Key: "$pull", Value: bson.D{{Key: "recovery_codes", Value: codeHash}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now().UTC()}}},
+	}
+	return t.update(filter, update)
+}
+
+// TwoFactorRecord stores the TOTP secret and hashed recovery codes of a user
+type TwoFactorRecord struct {
+	Id            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId        primitive.ObjectID `json:"user_id" bson:"user_id,omitempty"`
+	Secret        string             `json:"secret" bson:"secret,omitempty"`
+	RecoveryCodes []string           `json:"recovery_codes" bson:"recovery_codes,omitempty"`
+	LastUsedStep  int64              `json:"last_used_step" bson:"last_used_step,omitempty"`
+	EnabledAt     time.Time          `json:"enabled_at" bson:"enabled_at,omitempty"`
+	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at,omitempty"`
+}
+
+// NewTwoFactorRecord initializes a new pointer to a TwoFactorRecord struct from a pointer to a JSON TwoFactor struct
+func NewTwoFactorRecord(t *models.TwoFactor) (tm *TwoFactorRecord, err error) {
+	tm = &TwoFactorRecord{
+		Secret:        t.Secret,
+		RecoveryCodes: t.RecoveryCodes,
+		LastUsedStep:  t.LastUsedStep,
+		EnabledAt:     t.EnabledAt,
+		UpdatedAt:     t.UpdatedAt,
+		CreatedAt:     t.CreatedAt,
+	}
+	if t.Id != "" && t.Id != "000000000000000000000000" {
+		if tm.Id, err = primitive.ObjectIDFromHex(t.Id); err != nil {
+			return
+		}
+	}
+	if t.UserId != "" && t.UserId != "000000000000000000000000" {
+		tm.UserId, err = primitive.ObjectIDFromHex(t.UserId)
+	}
+	return
+}
+
+// Update the TwoFactorRecord using an overwrite bson doc
+func (t *TwoFactorRecord) Update(doc interface{}) (err error) {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return
+	}
+	tm := TwoFactorRecord{}
+	err = bson.Unmarshal(data, &tm)
+	if tm.Secret != "" {
+		t.Secret = tm.Secret
+	}
+	if len(tm.RecoveryCodes) > 0 {
+		t.RecoveryCodes = tm.RecoveryCodes
+	}
+	if tm.LastUsedStep > 0 {
+		t.LastUsedStep = tm.LastUsedStep
+	}
+	if !tm.EnabledAt.IsZero() {
+		t.EnabledAt = tm.EnabledAt
+	}
+	if !tm.UpdatedAt.IsZero() {
+		t.UpdatedAt = tm.UpdatedAt
+	}
+	return
+}
+
+// BsonLoad loads a bson doc into the TwoFactorRecord
+func (t *TwoFactorRecord) BsonLoad(doc bson.D) (err error) {
+	bData, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return err
+	}
+	err = bson.Unmarshal(bData, t)
+	return err
+}
+
+// Match compares an input bson doc and returns whether there's a match with the TwoFactorRecord
+func (t *TwoFactorRecord) Match(doc interface{}) bool {
+	data, err := utilities.BSONMarshall(doc)
+	if err != nil {
+		return false
+	}
+	tm := TwoFactorRecord{}
+	err = bson.Unmarshal(data, &tm)
+	if !tm.Id.IsZero() {
+		return t.Id == tm.Id
+	}
+	if !tm.UserId.IsZero() {
+		return t.UserId == tm.UserId
+	}
+	return false
+}
+
+// GetID returns the unique identifier of the TwoFactorRecord
+func (t *TwoFactorRecord) GetID() (id interface{}) {
+	return t.Id
+}
+
+// SortFields returns the fields a paginated list of TwoFactorRecords may be ordered by
+func (t *TwoFactorRecord) SortFields() []string {
+	return []string{"enabled_at", "created_at"}
+}
+
+// AddTimeStamps updates a TwoFactorRecord struct with a timestamp
+func (t *TwoFactorRecord) AddTimeStamps(newRecord bool) {
+	currentTime := time.Now().UTC()
+	t.UpdatedAt = currentTime
+	if newRecord {
+		t.CreatedAt = currentTime
+	}
+}
+
+// AddObjectID checks if a TwoFactorRecord has a value assigned for Id, if no value a new one is generated and assigned
+func (t *TwoFactorRecord) AddObjectID() {
+	if t.Id.Hex() == "" || t.Id.Hex() == "000000000000000000000000" {
+		t.Id = primitive.NewObjectID()
+	}
+}
+
+// PostProcess updates a TwoFactorRecord struct postProcess to do things such as validating required fields
+func (t *TwoFactorRecord) PostProcess() (err error) {
+	if t.Secret == "" {
+		err = errors.New("two factor record does not have a Secret")
+	}
+	return
+}
+
+// ToDoc converts the bson TwoFactorRecord into a bson.D
+func (t *TwoFactorRecord) ToDoc() (doc bson.D, err error) {
+	data, err := bson.Marshal(t)
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(data, &doc)
+	return
+}
+
+// BsonFilter generates a bson filter for MongoDB queries from the TwoFactorRecord data
+func (t *TwoFactorRecord) BsonFilter() (doc bson.D, err error) {
+	if !t.Id.IsZero() {
+		doc = bson.D{{Key: "_id", Value: t.Id}}
+	} else if !t.UserId.IsZero() {
+		doc = bson.D{{Key: "user_id", Value: t.UserId}}
+	}
+	return
+}
+
+// BsonUpdate generates a bson update for MongoDB queries from the TwoFactorRecord data
+func (t *TwoFactorRecord) BsonUpdate() (doc bson.D, err error) {
+	inner, err := t.ToDoc()
+	if err != nil {
+		return
+	}
+	doc = bson.D{{Key: "$set", Value: inner}}
+	return
+}
+
+// ToRoot creates and return a new pointer to a TwoFactor JSON struct from a pointer to a BSON TwoFactorRecord
+func (t *TwoFactorRecord) ToRoot() *models.TwoFactor {
+	return &models.TwoFactor{
+		Id:            t.Id.Hex(),
+		UserId:        t.UserId.Hex(),
+		Secret:        t.Secret,
+		RecoveryCodes: t.RecoveryCodes,
+		LastUsedStep:  t.LastUsedStep,
+		EnabledAt:     t.EnabledAt,
+		UpdatedAt:     t.UpdatedAt,
+		CreatedAt:     t.CreatedAt,
+	}
+}
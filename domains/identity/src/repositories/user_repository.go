@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 	"github.com/JECSand/eventit-server/domains/identity/src/models"
 	"github.com/JECSand/eventit-server/domains/shared/databases"
@@ -24,36 +25,108 @@ func NewUserRepo(db databases.DBClient) *UserRepo {
 	repoHandler := &databases.DBRepo[*UserRecord]{
 		DB:         db,
 		Collection: collection,
+		SoftDelete: true,
 	}
 	return &UserRepo{collection, db, repoHandler}
 }
 
+// ClearEmailVerification unsets the email verification timestamp of a user, used when its email changes
+func (r *UserRepo) ClearEmailVerification(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$unset", Value: bson.D{{Key: "email_verified_at", Value: ""}}}},
+	)
+	return err
+}
+
+// Lock locks a user out of password logins until the input time
+func (r *UserRepo) Lock(id primitive.ObjectID, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "locked_until", Value: until}}}},
+	)
+	return err
+}
+
+// Unlock lifts the lockout of a user, recording when it was unlocked
+func (r *UserRepo) Unlock(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	now := time.Now().UTC()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{
+			{Key: "$unset", Value: bson.D{{Key: "locked_until", Value: ""}}},
+			{Key: "$set", Value: bson.D{{Key: "unlocked_at", Value: now}, {Key: "updated_at", Value: now}}},
+		},
+	)
+	return err
+}
+
 // UserRecord stores User information
 type UserRecord struct {
-	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Username  string             `json:"username" bson:"username,omitempty"`
-	Password  string             `json:"password" bson:"password,omitempty"`
-	FirstName string             `json:"firstname" bson:"firstname,omitempty"`
-	LastName  string             `json:"lastname" bson:"lastname,omitempty"`
-	Email     string             `json:"email" bson:"email,omitempty"`
-	Role      enums.Role         `json:"role" bson:"role,omitempty"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at,omitempty"`
-	DeletedAt time.Time          `json:"deleted_at" bson:"deleted_at,omitempty"`
+	Id              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username        string             `json:"username" bson:"username,omitempty"`
+	Password        string             `json:"password" bson:"password,omitempty"`
+	FirstName       string             `json:"firstname" bson:"firstname,omitempty"`
+	LastName        string             `json:"lastname" bson:"lastname,omitempty"`
+	Email           string             `json:"email" bson:"email,omitempty"`
+	Role            enums.Role         `json:"role" bson:"role,omitempty"`
+	EmailVerifiedAt time.Time          `json:"email_verified_at" bson:"email_verified_at,omitempty"`
+	LockedUntil     time.Time          `json:"locked_until" bson:"locked_until,omitempty"`
+	UnlockedAt      time.Time          `json:"unlocked_at" bson:"unlocked_at,omitempty"`
+	UpdatedAt       time.Time          `json:"updated_at" bson:"updated_at,omitempty"`
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at,omitempty"`
+	DeletedAt       time.Time          `json:"deleted_at" bson:"deleted_at,omitempty"`
+}
+
+// UserQuerySchema maps the query params a list of users may be filtered by to the fields of UserRecords
+var UserQuerySchema = databases.QuerySchema{
+	Params: map[string]databases.QueryParam{
+		"username":          {Field: "username", Ops: []databases.QueryOp{databases.OpIn, databases.OpPrefix}},
+		"firstname":         {Field: "firstname", Ops: []databases.QueryOp{databases.OpIn, databases.OpPrefix}},
+		"lastname":          {Field: "lastname", Ops: []databases.QueryOp{databases.OpIn, databases.OpPrefix}},
+		"email":             {Field: "email", Ops: []databases.QueryOp{databases.OpIn, databases.OpPrefix}},
+		"role":              {Field: "role", Parse: roleValue, Ops: []databases.QueryOp{databases.OpNe, databases.OpIn}},
+		"email_verified_at": {Field: "email_verified_at", Parse: databases.TimeValue, Ops: timeQueryOps},
+		"created_at":        {Field: "created_at", Parse: databases.TimeValue, Ops: timeQueryOps},
+		"updated_at":        {Field: "updated_at", Parse: databases.TimeValue, Ops: timeQueryOps},
+		"deleted_at":        {Field: "deleted_at", Parse: databases.TimeValue, Ops: timeQueryOps},
+	},
+	Search: []string{"username", "firstname", "lastname", "email"},
+}
+
+// timeQueryOps are the operators timestamp query params support
+var timeQueryOps = []databases.QueryOp{databases.OpGt, databases.OpGte, databases.OpLt, databases.OpLte, databases.OpExists}
+
+// roleValue parses a role query param value
+func roleValue(value string) (interface{}, error) {
+	role := enums.RoleFromString(value)
+	if role == 0 {
+		return nil, errors.New("invalid role")
+	}
+	return role, nil
 }
 
 // NewUserRecord initializes a new pointer to a UserRecord struct from a pointer to a JSON User struct
 func NewUserRecord(u *models.User) (um *UserRecord, err error) {
 	um = &UserRecord{
-		Username:  u.Username,
-		Password:  u.Password,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Email:     u.Email,
-		Role:      u.Role,
-		UpdatedAt: u.UpdatedAt,
-		CreatedAt: u.CreatedAt,
-		DeletedAt: u.DeletedAt,
+		Username:        u.Username,
+		Password:        u.Password,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		Email:           u.Email,
+		Role:            u.Role,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		LockedUntil:     u.LockedUntil,
+		UnlockedAt:      u.UnlockedAt,
+		UpdatedAt:       u.UpdatedAt,
+		CreatedAt:       u.CreatedAt,
+		DeletedAt:       u.DeletedAt,
 	}
 	if u.Id != "" && u.Id != "000000000000000000000000" {
 		um.Id, err = primitive.ObjectIDFromHex(u.Id)
@@ -87,6 +160,9 @@ func (u *UserRecord) Update(doc interface{}) (err error) {
 	if um.Role.EnumIndex() > 0 {
 		u.Role = um.Role
 	}
+	if !um.EmailVerifiedAt.IsZero() {
+		u.EmailVerifiedAt = um.EmailVerifiedAt
+	}
 	if !um.UpdatedAt.IsZero() {
 		u.UpdatedAt = um.UpdatedAt
 	}
@@ -132,6 +208,11 @@ func (u *UserRecord) GetID() (id interface{}) {
 	return u.Id
 }
 
+// SortFields returns the fields a paginated list of UserRecords may be ordered by
+func (u *UserRecord) SortFields() []string {
+	return []string{"username", "firstname", "lastname", "email", "role", "updated_at", "created_at", "deleted_at"}
+}
+
 // AddTimeStamps updates an UserRecord struct with a timestamp
 func (u *UserRecord) AddTimeStamps(newRecord bool) {
 	currentTime := time.Now().UTC()
@@ -154,7 +235,6 @@ func (u *UserRecord) PostProcess() (err error) {
 	if u.Email == "" {
 		err = errors.New("user record does not have an email")
 	}
-	// TODO - When implementing soft delete, DeletedAt can be checked here to ensure deleted users are filtered out
 	return
 }
 
@@ -171,9 +251,23 @@ func (u *UserRecord) ToDoc() (doc bson.D, err error) {
 // BsonFilter generates a bson filter for MongoDB queries from the userModel data
 func (u *UserRecord) BsonFilter() (doc bson.D, err error) {
 	if u.Id.Hex() != "" && u.Id.Hex() != "000000000000000000000000" {
-		doc = bson.D{{"_id", u.Id}}
-	} else if u.Email != "" {
-		doc = bson.D{{"email", u.Email}}
+		doc = bson.D{{Key: "_id", Value: u.Id}}
+		return
+	}
+	if u.Email != "" {
+		doc = append(doc, bson.E{Key: "email", Value: u.Email})
+	}
+	if u.Username != "" {
+		doc = append(doc, bson.E{Key: "username", Value: u.Username})
+	}
+	if u.FirstName != "" {
+		doc = append(doc, bson.E{Key: "firstname", Value: u.FirstName})
+	}
+	if u.LastName != "" {
+		doc = append(doc, bson.E{Key: "lastname", Value: u.LastName})
+	}
+	if u.Role.EnumIndex() > 0 {
+		doc = append(doc, bson.E{Key: "role", Value: u.Role})
 	}
 	return
 }
@@ -184,23 +278,26 @@ func (u *UserRecord) BsonUpdate() (doc bson.D, err error) {
 	if err != nil {
 		return
 	}
-	doc = bson.D{{"$set", inner}}
+	doc = bson.D{{Key: "$set", Value: inner}}
 	return
 }
 
 // ToRoot creates and return a new pointer to a User JSON struct from a pointer to a BSON userModel
 func (u *UserRecord) ToRoot() *models.User {
 	return &models.User{
-		Id:        u.Id.Hex(),
-		Username:  u.Username,
-		Password:  u.Password,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Email:     u.Email,
-		Role:      u.Role,
-		UpdatedAt: u.UpdatedAt,
-		CreatedAt: u.CreatedAt,
-		DeletedAt: u.DeletedAt,
+		Id:              u.Id.Hex(),
+		Username:        u.Username,
+		Password:        u.Password,
+		FirstName:       u.FirstName,
+		LastName:        u.LastName,
+		Email:           u.Email,
+		Role:            u.Role,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		LockedUntil:     u.LockedUntil,
+		UnlockedAt:      u.UnlockedAt,
+		UpdatedAt:       u.UpdatedAt,
+		CreatedAt:       u.CreatedAt,
+		DeletedAt:       u.DeletedAt,
 	}
 }
 
@@ -0,0 +1,78 @@
+package routers
+
+import (
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"net/http"
+)
+
+// apiKeyRouter handles the api key management routes of the identity domain
+type apiKeyRouter struct {
+	kService *services.APIKeyService
+}
+
+// newAPIKeyRouter initializes a new apiKeyRouter struct
+func newAPIKeyRouter(kService *services.APIKeyService) *apiKeyRouter {
+	return &apiKeyRouter{kService}
+}
+
+// register mounts the api key routes onto the input ServeMux
+func (kr *apiKeyRouter) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api-keys", auth.RequirePermission("api_keys:write", denyIntegration(auth.DenyImpersonation(kr.CreateAPIKey))))
+	mux.HandleFunc("GET /api-keys", auth.RequirePermission("api_keys:read", denyIntegration(kr.ListAPIKeys)))
+	mux.HandleFunc("DELETE /api-keys/{id}", auth.RequirePermission("api_keys:write", denyIntegration(auth.DenyImpersonation(kr.RevokeAPIKey))))
+}
+
+// denyIntegration rejects requests authenticated with an api key so that keys cannot manage other keys
+func denyIntegration(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := auth.ClaimsFromCtx(r.Context())
+		if claims.IsIntegration() {
+			routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Not available to api keys"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// CreateAPIKey issues a new api key, returning its raw value once
+func (kr *apiKeyRouter) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	var apiKey models.APIKey
+	if err := routers.DecodeJSONBody(r, &apiKey); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	if apiKey.Role > claims.Role {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "cannot assign a role above your own"})
+		return
+	}
+	k, err := kr.kService.Create(claims.ProfileId, &apiKey)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusCreated, k)
+}
+
+// ListAPIKeys returns the api keys, optionally filtered by the created_by query param
+func (kr *apiKeyRouter) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := kr.kService.Find(r.URL.Query().Get("created_by"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, keys)
+}
+
+// RevokeAPIKey revokes an api key by id
+func (kr *apiKeyRouter) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	k, err := kr.kService.Revoke(r.PathValue("id"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, k)
+}
@@ -0,0 +1,46 @@
+package routers
+
+import (
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"net/http"
+)
+
+// auditRouter handles the audit log routes of the identity domain
+type auditRouter struct {
+	aService *services.AuditService
+}
+
+// newAuditRouter initializes a new auditRouter struct
+func newAuditRouter(aService *services.AuditService) *auditRouter {
+	return &auditRouter{aService}
+}
+
+// register mounts the audit log routes onto the input ServeMux
+func (ar *auditRouter) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /audit-events", auth.RequirePermission("audit:read", denyIntegration(ar.ListAuditEvents)))
+}
+
+// ListAuditEvents returns a paginated AuditEventsPage of the audit log events matching the query params
+func (ar *auditRouter) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pagination, err := utilities.PaginationFromQuery(query)
+	if err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	filter, err := ar.aService.ParseQuery(query)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	page, err := ar.aService.Find(r.Context(), &models.AuditEvent{}, filter, pagination)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, page)
+}
@@ -0,0 +1,255 @@
+package routers
+
+import (
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"net/http"
+)
+
+// authRouter handles the authentication routes of the identity domain
+type authRouter struct {
+	aService *services.AuthService
+	uService *services.UserService
+	pService *services.PasswordService
+}
+
+// newAuthRouter initializes a new authRouter struct
+func newAuthRouter(aService *services.AuthService, uService *services.UserService, pService *services.PasswordService) *authRouter {
+	return &authRouter{aService, uService, pService}
+}
+
+// register mounts the auth routes onto the input ServeMux
+func (ar *authRouter) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /auth/register", ar.Register)
+	mux.HandleFunc("POST /auth/login", ar.Login)
+	mux.HandleFunc("POST /auth/login/2fa", ar.LoginWithTwoFactor)
+	mux.HandleFunc("POST /auth/login/link", ar.SendLoginLink)
+	mux.HandleFunc("POST /auth/login/token", ar.LoginWithToken)
+	mux.HandleFunc("POST /auth/refresh", ar.Refresh)
+	mux.HandleFunc("POST /auth/logout", ar.Logout)
+	mux.HandleFunc("POST /auth/organization", auth.VerifyMemberMiddleWare(denyIntegration(auth.DenyImpersonation(ar.SwitchOrganization))))
+	mux.HandleFunc("POST /auth/impersonation/stop", auth.VerifyMemberMiddleWare(ar.StopImpersonation))
+	mux.HandleFunc("GET /auth/me", ar.Me)
+	mux.HandleFunc("POST /auth/password/forgot", ar.ForgotPassword)
+	mux.HandleFunc("POST /auth/password/reset", ar.ResetPassword)
+	mux.HandleFunc("POST /auth/verify-email", ar.VerifyEmail)
+	mux.HandleFunc("POST /auth/verify-email/resend", ar.ResendVerification)
+	mux.HandleFunc("GET /.well-known/jwks.json", ar.JWKS)
+}
+
+// Register signs up a new member and emails it an email verification token
+func (ar *authRouter) Register(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if err := routers.DecodeJSONBody(r, &user); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	user.Id = ""
+	user.Role = enums.MEMBER
+	created, err := ar.uService.Create(&user)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusCreated, created)
+}
+
+// Login authenticates a set of user credentials and returns a new Auth
+func (ar *authRouter) Login(w http.ResponseWriter, r *http.Request) {
+	var credentials models.Credentials
+	if err := routers.DecodeJSONBody(r, &credentials); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	a, err := ar.aService.Login(&credentials, clientInfo(r))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, a.AuthToken, ""), http.StatusOK, a)
+}
+
+// LoginWithTwoFactor exchanges the challenge returned by a login along with a two factor code for a new Auth
+func (ar *authRouter) LoginWithTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var code models.TwoFactorCode
+	if err := routers.DecodeJSONBody(r, &code); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	a, err := ar.aService.LoginWithTwoFactor(&code, clientInfo(r))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, a.AuthToken, ""), http.StatusOK, a)
+}
+
+// SendLoginLink emails a magic link login token, always responding with 202 so accounts cannot be discovered
+func (ar *authRouter) SendLoginLink(w http.ResponseWriter, r *http.Request) {
+	var link models.LoginLink
+	if err := routers.DecodeJSONBody(r, &link); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	if err := ar.aService.SendLoginLink(r.Context(), link.Email); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// LoginWithToken exchanges a magic link login token for a new Auth
+func (ar *authRouter) LoginWithToken(w http.ResponseWriter, r *http.Request) {
+	var link models.LoginLink
+	if err := routers.DecodeJSONBody(r, &link); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	a, err := ar.aService.LoginWithToken(&link, clientInfo(r))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, a.AuthToken, ""), http.StatusOK, a)
+}
+
+// Refresh exchanges a refresh token for a new Auth with rotated access and refresh tokens
+func (ar *authRouter) Refresh(w http.ResponseWriter, r *http.Request) {
+	var credentials models.RefreshCredentials
+	if err := routers.DecodeJSONBody(r, &credentials); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	a, err := ar.aService.Refresh(&credentials, clientInfo(r))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, a.AuthToken, ""), http.StatusOK, a)
+}
+
+// Logout blacklists the Auth-Token of the requester along with the family of an optional refresh token
+func (ar *authRouter) Logout(w http.ResponseWriter, r *http.Request) {
+	a := &models.Auth{AuthToken: r.Header.Get("Auth-Token")}
+	var credentials models.RefreshCredentials
+	if r.ContentLength > 0 {
+		if err := routers.DecodeJSONBody(r, &credentials); err != nil {
+			routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+			return
+		}
+		a.RefreshToken = credentials.RefreshToken
+	}
+	if err := ar.aService.Logout(a); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SwitchOrganization returns a new Auth whose token is scoped to the selected organization of the requester, an
+// empty organization_id unscopes it
+func (ar *authRouter) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	var selection models.OrgSelection
+	if err := routers.DecodeJSONBody(r, &selection); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	a, err := ar.aService.SwitchOrganization(auth.ClaimsFromCtx(r.Context()), selection.OrganizationId)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, a.AuthToken, ""), http.StatusOK, a)
+}
+
+// Me validates the Auth-Token of the requester and returns its Auth
+func (ar *authRouter) Me(w http.ResponseWriter, r *http.Request) {
+	a := &models.Auth{AuthToken: r.Header.Get("Auth-Token")}
+	if err := ar.aService.Validate(a); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, a.AuthToken, ""), http.StatusOK, a)
+}
+
+// StopImpersonation ends the impersonation of the requesting token, revoking its session
+func (ar *authRouter) StopImpersonation(w http.ResponseWriter, r *http.Request) {
+	if err := ar.aService.StopImpersonation(auth.ClaimsFromCtx(r.Context()), clientInfo(r)); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKS returns the public json web key set used to verify issued tokens
+func (ar *authRouter) JWKS(w http.ResponseWriter, r *http.Request) {
+	w = routers.SetResponseHeaders(w, "", "")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	routers.RespondWithJSON(w, http.StatusOK, auth.CurrentKeyring().JWKS())
+}
+
+// ForgotPassword emails a password reset token, always responding with 202 so accounts cannot be discovered
+func (ar *authRouter) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var reset models.PasswordReset
+	if err := routers.DecodeJSONBody(r, &reset); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	if err := ar.pService.ForgotPassword(r.Context(), reset.Email); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword consumes a password reset token and sets a new password
+func (ar *authRouter) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var reset models.PasswordReset
+	if err := routers.DecodeJSONBody(r, &reset); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	if err := ar.pService.ResetPassword(&reset); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail consumes an email verification token and returns the verified user
+func (ar *authRouter) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var verification models.EmailVerification
+	if err := routers.DecodeJSONBody(r, &verification); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	user, err := ar.uService.VerifyEmail(verification.Token)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, user)
+}
+
+// ResendVerification emails a new email verification token, responding with 202 for unknown emails as well
+func (ar *authRouter) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var verification models.EmailVerification
+	if err := routers.DecodeJSONBody(r, &verification); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	if err := ar.uService.ResendVerification(r.Context(), verification.Email); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusAccepted)
+}
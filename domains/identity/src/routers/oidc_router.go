@@ -0,0 +1,89 @@
+package routers
+
+import (
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"net/http"
+)
+
+// oidcRouter handles the external identity provider login routes of the identity domain
+type oidcRouter struct {
+	oService *services.OIDCService
+}
+
+// newOIDCRouter initializes a new oidcRouter struct
+func newOIDCRouter(oService *services.OIDCService) *oidcRouter {
+	return &oidcRouter{oService}
+}
+
+// register mounts the oidc routes onto the input ServeMux
+func (or *oidcRouter) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /auth/oidc", or.ListProviders)
+	mux.HandleFunc("GET /auth/oidc/{provider}", or.Begin)
+	mux.HandleFunc("GET /auth/oidc/{provider}/callback", or.Callback)
+	mux.HandleFunc("POST /auth/oidc/{provider}/callback", or.Callback)
+	mux.HandleFunc("GET /auth/identities", auth.VerifyMemberMiddleWare(denyIntegration(or.ListIdentities)))
+	mux.HandleFunc("DELETE /auth/identities/{id}", auth.VerifyMemberMiddleWare(denyIntegration(auth.DenyImpersonation(or.Unlink))))
+}
+
+// ListProviders returns the names of the configured identity providers
+func (or *oidcRouter) ListProviders(w http.ResponseWriter, r *http.Request) {
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, map[string][]string{"providers": or.oService.Providers()})
+}
+
+// Begin redirects the requester to the identity provider to authenticate
+func (or *oidcRouter) Begin(w http.ResponseWriter, r *http.Request) {
+	authURL, err := or.oService.Begin(r.Context(), r.PathValue("provider"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback exchanges the authorization code returned by the identity provider for a new Auth, the code and
+// state are read from the query of a provider redirect or from the body of a POST made by a client app
+func (or *oidcRouter) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		routers.RespondWithError(w, http.StatusUnauthorized, routers.JWTError{Message: providerErr + ": " + query.Get("error_description")})
+		return
+	}
+	callback := models.OIDCCallback{State: query.Get("state"), Code: query.Get("code")}
+	if r.Method == http.MethodPost {
+		if err := routers.DecodeJSONBody(r, &callback); err != nil {
+			routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+			return
+		}
+	}
+	a, err := or.oService.Callback(r.Context(), r.PathValue("provider"), &callback, clientInfo(r))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, a.AuthToken, ""), http.StatusOK, a)
+}
+
+// ListIdentities returns the external identities linked to the requester
+func (or *oidcRouter) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	identities, err := or.oService.FindLinked(claims.ProfileId)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, identities)
+}
+
+// Unlink removes an external identity linked to the requester
+func (or *oidcRouter) Unlink(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	if err := or.oService.Unlink(claims.ProfileId, r.PathValue("id")); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
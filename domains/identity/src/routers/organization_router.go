@@ -0,0 +1,235 @@
+package routers
+
+import (
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"net/http"
+)
+
+// organizationRouter handles the organization, membership and invitation routes of the identity domain
+type organizationRouter struct {
+	oService *services.OrganizationService
+}
+
+// newOrganizationRouter initializes a new organizationRouter struct
+func newOrganizationRouter(oService *services.OrganizationService) *organizationRouter {
+	return &organizationRouter{oService}
+}
+
+// register mounts the organization routes onto the input ServeMux, the routes of an organization are only
+// available to tokens scoped to it
+func (or *organizationRouter) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /organizations", auth.VerifyMemberMiddleWare(denyIntegration(or.CreateOrganization)))
+	mux.HandleFunc("GET /organizations", auth.VerifyMemberMiddleWare(denyIntegration(or.ListOrganizations)))
+	mux.HandleFunc("POST /organizations/invitations/accept", auth.VerifyMemberMiddleWare(denyIntegration(or.AcceptInvitation)))
+	mux.HandleFunc("GET /organizations/{id}", auth.VerifyMemberMiddleWare(activeOrganization(or.GetOrganization)))
+	mux.HandleFunc("PATCH /organizations/{id}", auth.VerifyMemberMiddleWare(activeOrganization(or.UpdateOrganization)))
+	mux.HandleFunc("GET /organizations/{id}/members", auth.VerifyMemberMiddleWare(activeOrganization(or.ListMembers)))
+	mux.HandleFunc("PATCH /organizations/{id}/members/{userId}", auth.VerifyMemberMiddleWare(activeOrganization(or.UpdateMember)))
+	mux.HandleFunc("DELETE /organizations/{id}/members/{userId}", auth.VerifyMemberMiddleWare(activeOrganization(or.RemoveMember)))
+	mux.HandleFunc("POST /organizations/{id}/invitations", auth.VerifyMemberMiddleWare(activeOrganization(or.InviteMember)))
+}
+
+// activeOrganization rejects requests to an organization other than the one the token of the requester is scoped to
+func activeOrganization(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := auth.ClaimsFromCtx(r.Context())
+		if claims.OrgId == "" || claims.OrgId != r.PathValue("id") {
+			routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Switch to the organization first"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// orgRole returns the current role of the requester within its organization, read from its membership so that
+// role changes apply before its token is refreshed. ROOT users are ROOT in every organization
+func (or *organizationRouter) orgRole(w http.ResponseWriter, r *http.Request) (enums.Role, bool) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	if claims.Role == enums.ROOT {
+		return enums.ROOT, true
+	}
+	membership, err := or.oService.Membership(r.Context(), claims.ProfileId)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return 0, false
+	}
+	return membership.Role, true
+}
+
+// CreateOrganization creates a new organization with the requester as its ROOT member
+func (or *organizationRouter) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var org models.Organization
+	if err := routers.DecodeJSONBody(r, &org); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	o, err := or.oService.Create(auth.ClaimsFromCtx(r.Context()).ProfileId, &org)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusCreated, o)
+}
+
+// ListOrganizations returns the organizations the requester is a member of
+func (or *organizationRouter) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := or.oService.FindByUser(auth.ClaimsFromCtx(r.Context()).ProfileId)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, orgs)
+}
+
+// GetOrganization returns the organization of the requester along with its role within it
+func (or *organizationRouter) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	role, ok := or.orgRole(w, r)
+	if !ok {
+		return
+	}
+	o, err := or.oService.FindById(r.PathValue("id"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	o.Role = role
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, o)
+}
+
+// UpdateOrganization renames the organization of the requester, it requires the ADMIN organization role
+func (or *organizationRouter) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	role, ok := or.orgRole(w, r)
+	if !ok {
+		return
+	}
+	if role < enums.ADMIN {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return
+	}
+	var org models.Organization
+	if err := routers.DecodeJSONBody(r, &org); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	org.Id = r.PathValue("id")
+	o, err := or.oService.Update(r.Context(), &org)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	o.Role = role
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, o)
+}
+
+// ListMembers returns the memberships of the organization of the requester
+func (or *organizationRouter) ListMembers(w http.ResponseWriter, r *http.Request) {
+	if _, ok := or.orgRole(w, r); !ok {
+		return
+	}
+	members, err := or.oService.Members(r.Context())
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, members)
+}
+
+// manageableMember returns the membership of the target member when the requester may manage it, ADMIN members
+// may only manage members with a role up to their own
+func (or *organizationRouter) manageableMember(w http.ResponseWriter, r *http.Request) (*models.Membership, enums.Role, bool) {
+	role, ok := or.orgRole(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+	target, err := or.oService.Membership(r.Context(), r.PathValue("userId"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return nil, 0, false
+	}
+	self := target.UserId == auth.ClaimsFromCtx(r.Context()).ProfileId
+	if !self && (role < enums.ADMIN || target.Role > role) {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return nil, 0, false
+	}
+	return target, role, true
+}
+
+// UpdateMember changes the organization role of a member, up to the role of the requester
+func (or *organizationRouter) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	target, role, ok := or.manageableMember(w, r)
+	if !ok {
+		return
+	}
+	var membership models.Membership
+	if err := routers.DecodeJSONBody(r, &membership); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	if role < enums.ADMIN || membership.Role > role {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "cannot assign a role above your own"})
+		return
+	}
+	m, err := or.oService.UpdateMember(r.Context(), target.UserId, membership.Role)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, m)
+}
+
+// RemoveMember removes a member from the organization of the requester, members may always leave
+func (or *organizationRouter) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	target, _, ok := or.manageableMember(w, r)
+	if !ok {
+		return
+	}
+	if err := or.oService.RemoveMember(r.Context(), target.UserId); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InviteMember emails an invitation to join the organization of the requester, it requires the ADMIN organization
+// role and the invited role may not be above the role of the requester
+func (or *organizationRouter) InviteMember(w http.ResponseWriter, r *http.Request) {
+	role, ok := or.orgRole(w, r)
+	if !ok {
+		return
+	}
+	var invitation models.OrgInvitation
+	if err := routers.DecodeJSONBody(r, &invitation); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	if role < enums.ADMIN || invitation.Role > role {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return
+	}
+	if err := or.oService.Invite(r.Context(), auth.ClaimsFromCtx(r.Context()).ProfileId, &invitation); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// AcceptInvitation makes the requester a member of the organization it was invited to
+func (or *organizationRouter) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var invitation models.OrgInvitation
+	if err := routers.DecodeJSONBody(r, &invitation); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	m, err := or.oService.Accept(auth.ClaimsFromCtx(r.Context()).ProfileId, invitation.Token)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusCreated, m)
+}
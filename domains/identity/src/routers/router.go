@@ -0,0 +1,140 @@
+package routers
+
+import (
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/oidc"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"net/http"
+	"strconv"
+)
+
+// Router mounts the http routes of the identity domain
+type Router struct {
+	userService      *services.UserService
+	authService      *services.AuthService
+	apiKeyService    *services.APIKeyService
+	passwordService  *services.PasswordService
+	twoFactorService *services.TwoFactorService
+	oidcService      *services.OIDCService
+	sessionService   *services.SessionService
+	orgService       *services.OrganizationService
+	auditService     *services.AuditService
+}
+
+// NewRouter is an exported function used to initialize a new identity Router struct
+func NewRouter(uService *services.UserService, aService *services.AuthService, kService *services.APIKeyService, pService *services.PasswordService, tfService *services.TwoFactorService, oService *services.OIDCService, sService *services.SessionService, orgService *services.OrganizationService, auditService *services.AuditService) *Router {
+	return &Router{
+		userService:      uService,
+		authService:      aService,
+		apiKeyService:    kService,
+		passwordService:  pService,
+		twoFactorService: tfService,
+		oidcService:      oService,
+		sessionService:   sService,
+		orgService:       orgService,
+		auditService:     auditService,
+	}
+}
+
+// Register mounts the identity routes onto the input ServeMux
+func (rt *Router) Register(mux *http.ServeMux) {
+	mux.HandleFunc("OPTIONS /", routers.HandleOptionsRequest)
+	newAuthRouter(rt.authService, rt.userService, rt.passwordService).register(mux)
+	newUserRouter(rt.userService, rt.authService, rt.sessionService, rt.apiKeyService).register(mux)
+	newAPIKeyRouter(rt.apiKeyService).register(mux)
+	newTwoFactorRouter(rt.twoFactorService).register(mux)
+	newOIDCRouter(rt.oidcService).register(mux)
+	newOrganizationRouter(rt.orgService).register(mux)
+	newAuditRouter(rt.auditService).register(mux)
+}
+
+// serviceErrorStatus maps an error returned by the identity services to a http status code
+func serviceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrEmptyPassword),
+		errors.Is(err, services.ErrEmptyEmail),
+		errors.Is(err, services.ErrInvalidEmail),
+		errors.Is(err, services.ErrWeakPassword),
+		errors.Is(err, services.ErrInvalidUserId),
+		errors.Is(err, services.ErrInvalidAPIKeyInput),
+		errors.Is(err, services.ErrInvalidOrganization),
+		errors.Is(err, services.ErrInvalidOrgRole),
+		errors.Is(err, services.ErrInvalidInvitation),
+		errors.Is(err, services.ErrInvalidAuditFilter),
+		errors.Is(err, services.ErrNotImpersonating),
+		errors.Is(err, services.ErrCurrentPasswordRequired),
+		errors.Is(err, utilities.ErrInvalidOrderBy),
+		errors.Is(err, utilities.ErrInvalidCursor),
+		errors.Is(err, databases.ErrInvalidQuery),
+		errors.Is(err, services.ErrInvalidVerificationToken):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrEmptyToken),
+		errors.Is(err, services.ErrInvalidToken),
+		errors.Is(err, services.ErrInvalidCredentials),
+		errors.Is(err, services.ErrInvalidRefreshToken),
+		errors.Is(err, services.ErrRefreshTokenReused),
+		errors.Is(err, services.ErrTokenRevoked),
+		errors.Is(err, services.ErrSessionRevoked),
+		errors.Is(err, services.ErrInvalidAPIKey),
+		errors.Is(err, services.ErrInvalidResetToken),
+		errors.Is(err, services.ErrInvalidLoginToken),
+		errors.Is(err, services.ErrInvalidChallenge),
+		errors.Is(err, services.ErrInvalidTwoFactorCode),
+		errors.Is(err, services.ErrInvalidOIDCState),
+		errors.Is(err, oidc.ErrInvalidIDToken),
+		errors.Is(err, oidc.ErrExchangeFailed):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrEmailNotVerified),
+		errors.Is(err, services.ErrOIDCEmailNotVerified),
+		errors.Is(err, services.ErrNotOrgMember),
+		errors.Is(err, services.ErrInvitationMismatch),
+		errors.Is(err, services.ErrIncorrectPassword),
+		errors.Is(err, services.ErrImpersonationNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrAPIKeyNotFound),
+		errors.Is(err, services.ErrTwoFactorNotEnrolled),
+		errors.Is(err, services.ErrUnknownProvider),
+		errors.Is(err, services.ErrLinkedIdentityNotFound),
+		errors.Is(err, services.ErrSessionNotFound),
+		errors.Is(err, services.ErrOrganizationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrEmailTaken),
+		errors.Is(err, services.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, services.ErrOrgMemberExists),
+		errors.Is(err, services.ErrLastOrgOwner):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrAccountLocked):
+		return http.StatusLocked
+	case errors.Is(err, services.ErrVerificationThrottled),
+		errors.Is(err, services.ErrLoginThrottled):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// clientInfo returns the ClientInfo of the client a request was sent from
+func clientInfo(r *http.Request) *models.ClientInfo {
+	return &models.ClientInfo{IP: routers.ClientIP(r), UserAgent: r.UserAgent()}
+}
+
+// respondWithServiceError writes an error returned by the identity services along with its status code
+func respondWithServiceError(w http.ResponseWriter, err error) {
+	var policyErr *services.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusBadRequest, routers.ValidationError{
+			Message: services.ErrWeakPassword.Error(),
+			Errors:  policyErr.Errors,
+		})
+		return
+	}
+	var retryErr *services.RetryError
+	if errors.As(err, &retryErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryErr.RetryAfter().Seconds())))
+	}
+	routers.RespondWithError(w, serviceErrorStatus(err), routers.JWTError{Message: err.Error()})
+}
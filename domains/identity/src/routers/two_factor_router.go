@@ -0,0 +1,87 @@
+package routers
+
+import (
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"net/http"
+)
+
+// twoFactorRouter handles the two factor authentication management routes of the identity domain
+type twoFactorRouter struct {
+	tfService *services.TwoFactorService
+}
+
+// newTwoFactorRouter initializes a new twoFactorRouter struct
+func newTwoFactorRouter(tfService *services.TwoFactorService) *twoFactorRouter {
+	return &twoFactorRouter{tfService}
+}
+
+// register mounts the two factor routes onto the input ServeMux, they are member routes so that users required
+// to use two factor authentication are still able to enroll
+func (tr *twoFactorRouter) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /auth/2fa/enroll", auth.VerifyMemberMiddleWare(denyIntegration(auth.DenyImpersonation(tr.Enroll))))
+	mux.HandleFunc("POST /auth/2fa/confirm", auth.VerifyMemberMiddleWare(denyIntegration(auth.DenyImpersonation(tr.Confirm))))
+	mux.HandleFunc("POST /auth/2fa/recovery-codes", auth.VerifyMemberMiddleWare(denyIntegration(auth.DenyImpersonation(tr.RegenerateRecoveryCodes))))
+	mux.HandleFunc("DELETE /auth/2fa", auth.VerifyMemberMiddleWare(denyIntegration(auth.DenyImpersonation(tr.Disable))))
+}
+
+// Enroll generates a new TOTP secret for the requester along with its otpauth uri
+func (tr *twoFactorRouter) Enroll(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	enrollment, err := tr.tfService.Enroll(claims.ProfileId)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusCreated, enrollment)
+}
+
+// Confirm enables the pending enrollment of the requester, returning its recovery codes once
+func (tr *twoFactorRouter) Confirm(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	var code models.TwoFactorCode
+	if err := routers.DecodeJSONBody(r, &code); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	enrollment, err := tr.tfService.Confirm(claims.ProfileId, code.Code)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, enrollment)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the requester, returning the new codes once
+func (tr *twoFactorRouter) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	var code models.TwoFactorCode
+	if err := routers.DecodeJSONBody(r, &code); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	enrollment, err := tr.tfService.RegenerateRecoveryCodes(claims.ProfileId, code.Code)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, enrollment)
+}
+
+// Disable removes the two factor enrollment of the requester
+func (tr *twoFactorRouter) Disable(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	var code models.TwoFactorCode
+	if err := routers.DecodeJSONBody(r, &code); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	if err := tr.tfService.Disable(claims.ProfileId, code.Code); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
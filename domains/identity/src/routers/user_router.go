@@ -0,0 +1,319 @@
+package routers
+
+import (
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/services"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"net/http"
+	"strconv"
+)
+
+// userRouter handles the user management routes of the identity domain
+type userRouter struct {
+	uService *services.UserService
+	aService *services.AuthService
+	sService *services.SessionService
+	kService *services.APIKeyService
+}
+
+// newUserRouter initializes a new userRouter struct
+func newUserRouter(uService *services.UserService, aService *services.AuthService, sService *services.SessionService, kService *services.APIKeyService) *userRouter {
+	return &userRouter{uService, aService, sService, kService}
+}
+
+// register mounts the user routes onto the input ServeMux
+func (ur *userRouter) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /users", auth.RequirePermission("users:write", ur.CreateUser))
+	mux.HandleFunc("GET /users", auth.RequirePermission("users:read", ur.ListUsers))
+	mux.HandleFunc("GET /users/{id}", selfOrPermission("users:read", ur.GetUser))
+	mux.HandleFunc("PATCH /users/{id}", selfOrPermission("users:write", auth.DenyImpersonation(ur.UpdateUser)))
+	mux.HandleFunc("DELETE /users/{id}", auth.RequirePermission("users:delete", auth.DenyImpersonation(ur.DeleteUser)))
+	mux.HandleFunc("POST /users/{id}/unlock", auth.RequirePermission("users:write", ur.UnlockUser))
+	mux.HandleFunc("POST /users/{id}/restore", auth.RequirePermission("users:delete", ur.RestoreUser))
+	mux.HandleFunc("POST /users/{id}/impersonate", auth.RequirePermission("users:impersonate", denyIntegration(auth.DenyImpersonation(ur.ImpersonateUser))))
+	mux.HandleFunc("GET /users/{id}/sessions", auth.VerifyMemberMiddleWare(denyIntegration(ur.ListSessions)))
+	mux.HandleFunc("DELETE /users/{id}/sessions", auth.VerifyMemberMiddleWare(denyIntegration(auth.DenyImpersonation(ur.RevokeSessions))))
+	mux.HandleFunc("DELETE /users/{id}/sessions/{sessionId}", auth.VerifyMemberMiddleWare(denyIntegration(auth.DenyImpersonation(ur.RevokeSession))))
+}
+
+// selfOrPermission is used to verify that the requester targets itself or is granted a permission. Requests
+// targeting the requester itself are denied to api keys, so that a key cannot act as the user who created it
+func selfOrPermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return auth.VerifyMemberMiddleWare(func(w http.ResponseWriter, r *http.Request) {
+		claims := auth.ClaimsFromCtx(r.Context())
+		if claims.ProfileId == r.PathValue("id") {
+			denyIntegration(next).ServeHTTP(w, r)
+			return
+		}
+		if !claims.HasPermission(permission) {
+			routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Missing permission " + permission})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canManage returns whether the requester's claims allow it to manage the target user
+func canManage(claims auth.AppClaims, target *models.User) bool {
+	if claims.ProfileId == target.Id || claims.Role == enums.ROOT {
+		return true
+	}
+	return claims.Role > target.Role
+}
+
+// CreateUser creates a new user, admins may only create users with a role up to their own
+func (ur *userRouter) CreateUser(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	var user models.User
+	if err := routers.DecodeJSONBody(r, &user); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	user.Id = ""
+	if user.Role > claims.Role {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "cannot assign a role above your own"})
+		return
+	}
+	u, err := ur.uService.Create(&user)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusCreated, u)
+}
+
+// ListUsers returns a paginated UsersPage of the users matching the query params
+func (ur *userRouter) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pagination, err := utilities.PaginationFromQuery(query)
+	if err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	filter, err := ur.uService.ParseQuery(query)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	includeDeleted := false
+	if deleted := query.Get("include_deleted"); deleted != "" {
+		if includeDeleted, err = strconv.ParseBool(deleted); err != nil {
+			routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: "invalid include_deleted"})
+			return
+		}
+	}
+	page, err := ur.uService.Find(r.Context(), &models.User{}, filter, pagination, includeDeleted)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, page)
+}
+
+// GetUser returns a user by id, members may only read themselves
+func (ur *userRouter) GetUser(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	id := r.PathValue("id")
+	if claims.Role == enums.MEMBER && claims.ProfileId != id {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return
+	}
+	u, err := ur.uService.FindById(id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, u)
+}
+
+// UpdateUser updates a user by id, members may only update themselves and never their role. Users changing their
+// own password must confirm their current password, and a password change revokes every other session of the user
+func (ur *userRouter) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	var user models.User
+	if err := routers.DecodeJSONBody(r, &user); err != nil {
+		routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+		return
+	}
+	target, err := ur.uService.FindById(r.PathValue("id"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if !canManage(claims, target) || user.Role > claims.Role || (claims.Role == enums.MEMBER && user.Role != 0) {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return
+	}
+	self := target.Id == claims.ProfileId
+	passwordChanged := user.Password != ""
+	if passwordChanged && self {
+		if err = ur.aService.VerifyCurrentPassword(target, user.CurrentPassword, clientInfo(r)); err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+	}
+	user.Id = target.Id
+	u, err := ur.uService.Update(&user)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if passwordChanged {
+		keepId := ""
+		if self {
+			keepId = claims.ID
+		}
+		if err = ur.aService.RevokeSessions(target.Id, keepId); err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, u)
+}
+
+// DeleteUser soft deletes a user by id and revokes its sessions and api keys, the user can be restored until it is
+// purged
+func (ur *userRouter) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	target, err := ur.uService.FindById(r.PathValue("id"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if !canManage(claims, target) {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return
+	}
+	if err = ur.uService.DeleteById(target.Id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if err = ur.sService.RevokeAll(target.Id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if err = ur.kService.RevokeByCreator(target.Id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser restores a soft deleted user that was not purged yet
+func (ur *userRouter) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	target, err := ur.uService.FindDeletedById(r.PathValue("id"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if !canManage(claims, target) {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return
+	}
+	u, err := ur.uService.Restore(target.Id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, u)
+}
+
+// ImpersonateUser issues a short lived token letting the requester act as a user of a lower role, the optional
+// reason of the request is written to the audit log along with the start of the impersonation
+func (ur *userRouter) ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	var impersonation models.Impersonation
+	if r.ContentLength > 0 {
+		if err := routers.DecodeJSONBody(r, &impersonation); err != nil {
+			routers.RespondWithError(w, http.StatusBadRequest, routers.JWTError{Message: err.Error()})
+			return
+		}
+	}
+	a, err := ur.aService.Impersonate(auth.ClaimsFromCtx(r.Context()), r.PathValue("id"), &impersonation, clientInfo(r))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, a.AuthToken, ""), http.StatusCreated, a)
+}
+
+// UnlockUser lifts the lockout of a user locked after too many failed logins
+func (ur *userRouter) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	target, err := ur.uService.FindById(r.PathValue("id"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if !canManage(claims, target) {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return
+	}
+	u, err := ur.aService.Unlock(target.Id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, u)
+}
+
+// sessionOwner returns the user whose sessions are requested, members may only manage their own sessions
+func (ur *userRouter) sessionOwner(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	claims := auth.ClaimsFromCtx(r.Context())
+	target, err := ur.uService.FindById(r.PathValue("id"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return nil, false
+	}
+	if !canManage(claims, target) {
+		routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Insufficient Permissions"})
+		return nil, false
+	}
+	return target, true
+}
+
+// ListSessions returns the active sessions of a user, flagging the session of the requester
+func (ur *userRouter) ListSessions(w http.ResponseWriter, r *http.Request) {
+	target, ok := ur.sessionOwner(w, r)
+	if !ok {
+		return
+	}
+	sessions, err := ur.sService.FindByUser(target.Id, auth.ClaimsFromCtx(r.Context()).ID)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	routers.RespondWithJSON(routers.SetResponseHeaders(w, "", ""), http.StatusOK, sessions)
+}
+
+// RevokeSession revokes a session of a user, its tokens are rejected from the next request on
+func (ur *userRouter) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	target, ok := ur.sessionOwner(w, r)
+	if !ok {
+		return
+	}
+	if err := ur.sService.Revoke(target.Id, r.PathValue("sessionId")); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeSessions revokes every session of a user, including the session of the requester when it is its own
+func (ur *userRouter) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	target, ok := ur.sessionOwner(w, r)
+	if !ok {
+		return
+	}
+	if err := ur.sService.RevokeAll(target.Id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	w = routers.SetResponseHeaders(w, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+var (
+	// ErrInvalidAPIKey is returned when an api key is unknown, expired or revoked
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrAPIKeyNotFound is returned when no api key matches the requested id
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidAPIKeyInput is returned when an api key is created with a missing name or malformed scopes
+	ErrInvalidAPIKeyInput = errors.New("api keys require a name and scopes formatted as resource:action")
+)
+
+const (
+	// apiKeyPrefix marks the values of keys issued by eventit so they are easy to recognize in leaked secret scans
+	apiKeyPrefix = "eit_"
+	// apiKeyCacheTTL bounds how long a resolved api key is cached, and so how long a revocation performed on
+	// another instance can go unnoticed by this one
+	apiKeyCacheTTL = 30 * time.Second
+	// apiKeyCacheSize caps the number of api keys cached in-process
+	apiKeyCacheSize = 1000
+)
+
+// APIKeyService is used by the app to manage all api key related controllers and functionality
+type APIKeyService struct {
+	keyRepo     *repos.APIKeyRepo
+	userService *UserService
+	resolved    *utilities.TTLCache[*auth.AppClaims]
+}
+
+// NewAPIKeyService is an exported function used to initialize a new APIKeyService struct
+func NewAPIKeyService(kHandler *repos.APIKeyRepo, userService *UserService) *APIKeyService {
+	return &APIKeyService{
+		kHandler,
+		userService,
+		utilities.NewTTLCache[*auth.AppClaims](apiKeyCacheSize),
+	}
+}
+
+// generateAPIKey returns a new api key value along with the prefix displayed to identify it
+func generateAPIKey() (key string, prefix string, err error) {
+	b := make([]byte, 4)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	prefix = apiKeyPrefix + hex.EncodeToString(b)
+	secret, err := utilities.GenerateRandomToken(32)
+	if err != nil {
+		return
+	}
+	key = prefix + "_" + secret
+	return
+}
+
+// Create issues a new api key owned by the input creator, the raw key is only ever returned by this call
+func (ks *APIKeyService) Create(creatorId string, apiKey *models.APIKey) (*models.APIKey, error) {
+	if apiKey.Name == "" || len(apiKey.Scopes) == 0 {
+		return apiKey, ErrInvalidAPIKeyInput
+	}
+	for _, scope := range apiKey.Scopes {
+		if !auth.ValidPermission(scope) {
+			return apiKey, ErrInvalidAPIKeyInput
+		}
+	}
+	if apiKey.Role.EnumIndex() == 0 {
+		apiKey.Role = enums.MEMBER
+	}
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		return apiKey, err
+	}
+	apiKey.Id = ""
+	apiKey.CreatedBy = creatorId
+	apiKey.Prefix = prefix
+	apiKey.KeyHash = utilities.HashToken(key)
+	apiKey.RevokedAt = time.Time{}
+	apiKey.LastUsedAt = time.Time{}
+	keyRec, err := repos.NewAPIKeyRecord(apiKey)
+	if err != nil {
+		return apiKey, ErrInvalidUserId
+	}
+	keyRec, err = ks.keyRepo.Handler.InsertOne(keyRec)
+	if err != nil {
+		return apiKey, err
+	}
+	created := keyRec.ToRoot()
+	created.Key = key
+	return created, nil
+}
+
+// Find returns the api keys created by the input user, or every api key when createdBy is empty
+func (ks *APIKeyService) Find(createdBy string) ([]*models.APIKey, error) {
+	keyRec, err := repos.NewAPIKeyRecord(&models.APIKey{CreatedBy: createdBy})
+	if err != nil {
+		return nil, ErrInvalidUserId
+	}
+	keyRecs, err := ks.keyRepo.Handler.FindMany(keyRec)
+	if err != nil {
+		return nil, err
+	}
+	return repos.LoadAPIKeyRecords(keyRecs), nil
+}
+
+// Revoke permanently disables an api key by id
+func (ks *APIKeyService) Revoke(id string) (*models.APIKey, error) {
+	keyRec, err := repos.NewAPIKeyRecord(&models.APIKey{Id: id})
+	if err != nil || keyRec.Id.IsZero() {
+		return nil, ErrAPIKeyNotFound
+	}
+	keyRec, err = ks.keyRepo.Handler.FindOne(keyRec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if keyRec.RevokedAt.IsZero() {
+		_, err = ks.keyRepo.Handler.UpdateOne(&repos.APIKeyRecord{Id: keyRec.Id}, &repos.APIKeyRecord{RevokedAt: time.Now().UTC()})
+		if err != nil {
+			return nil, err
+		}
+		ks.resolved.Delete(keyRec.KeyHash)
+	}
+	return ks.findById(keyRec.Id.Hex())
+}
+
+// RevokeByCreator permanently disables every active api key created by a user, used when the user is deleted
+func (ks *APIKeyService) RevokeByCreator(creatorId string) error {
+	keyRec, err := repos.NewAPIKeyRecord(&models.APIKey{CreatedBy: creatorId})
+	if err != nil || keyRec.CreatedBy.IsZero() {
+		return ErrInvalidUserId
+	}
+	keyRecs, err := ks.keyRepo.Handler.FindMany(keyRec)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, k := range keyRecs {
+		if !k.RevokedAt.IsZero() {
+			continue
+		}
+		if _, err = ks.keyRepo.Handler.UpdateOne(&repos.APIKeyRecord{Id: k.Id}, &repos.APIKeyRecord{RevokedAt: now}); err != nil {
+			return err
+		}
+		ks.resolved.Delete(k.KeyHash)
+	}
+	return nil
+}
+
+// findById returns an api key by id
+func (ks *APIKeyService) findById(id string) (*models.APIKey, error) {
+	keyRec, err := repos.NewAPIKeyRecord(&models.APIKey{Id: id})
+	if err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	keyRec, err = ks.keyRepo.Handler.FindOne(keyRec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keyRec.ToRoot(), nil
+}
+
+// Resolve is an auth.APIKeyResolver returning the INTEGRATION AppClaims of an active api key, keys whose creator
+// was deleted or no longer holds the role of the key are rejected
+func (ks *APIKeyService) Resolve(ctx context.Context, apiKey string) (*auth.AppClaims, error) {
+	keyHash := utilities.HashToken(apiKey)
+	if claims, ok := ks.resolved.Get(keyHash); ok {
+		return claims, nil
+	}
+	keyRec, err := ks.keyRepo.Handler.FindOne(&repos.APIKeyRecord{KeyHash: keyHash})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &auth.AppClaims{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return &auth.AppClaims{}, err
+	}
+	key := keyRec.ToRoot()
+	if !key.Active() {
+		return &auth.AppClaims{}, ErrInvalidAPIKey
+	}
+	creator, err := ks.userService.FindById(key.CreatedBy)
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidUserId) || (err == nil && creator.Role < key.Role) {
+		return &auth.AppClaims{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return &auth.AppClaims{}, err
+	}
+	_, err = ks.keyRepo.Handler.UpdateOne(&repos.APIKeyRecord{Id: keyRec.Id}, &repos.APIKeyRecord{LastUsedAt: time.Now().UTC()})
+	if err != nil {
+		return &auth.AppClaims{}, err
+	}
+	claims := &auth.AppClaims{
+		ProfileId:   key.CreatedBy,
+		Role:        key.Role,
+		SessionType: enums.INTEGRATION,
+		Scopes:      key.Scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID: key.Id,
+		},
+	}
+	ttl := apiKeyCacheTTL
+	if !key.ExpiresAt.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(key.ExpiresAt)
+		if time.Until(key.ExpiresAt) < ttl {
+			ttl = time.Until(key.ExpiresAt)
+		}
+	}
+	ks.resolved.Set(keyHash, claims, ttl)
+	return claims, nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"testing"
+)
+
+func TestAPIKeyService_Resolve(t *testing.T) {
+	db := newTestDB(t)
+	us, _ := newTestUserService(db)
+	ks := NewAPIKeyService(repos.NewAPIKeyRepo(db), us)
+	admin := createTestUser(t, us, "admin@example.com", enums.ADMIN)
+	member := createTestUser(t, us, "member@example.com", enums.MEMBER)
+	create := func(creator *models.User, role enums.Role) *models.APIKey {
+		key, err := ks.Create(creator.Id, &models.APIKey{Name: "ci", Scopes: []string{"users:read"}, Role: role})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return key
+	}
+	adminKey := create(admin, enums.ADMIN)
+	claims, err := ks.Resolve(context.Background(), adminKey.Key)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if claims.ProfileId != admin.Id || claims.Role != enums.ADMIN || !claims.IsIntegration() {
+		t.Errorf("Resolve() = %+v, want the integration claims of %s", claims, admin.Id)
+	}
+	if used, _ := ks.findById(adminKey.Id); used.LastUsedAt.IsZero() {
+		t.Errorf("Resolve() did not record the last use of the key")
+	}
+	if _, err = ks.Resolve(context.Background(), "unknown"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Resolve() of an unknown key error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+	// a key may not grant more than the role its creator holds
+	if _, err = ks.Resolve(context.Background(), create(member, enums.ADMIN).Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Resolve() of a key above its creator's role error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+	memberKey := create(member, enums.MEMBER)
+	if err = us.DeleteById(member.Id); err != nil {
+		t.Fatalf("DeleteById() error = %v", err)
+	}
+	if _, err = ks.Resolve(context.Background(), memberKey.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Resolve() of a key of a deleted creator error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+	// the resolved claims of the admin key are cached, revoking the keys of its creator must drop them
+	if err = ks.RevokeByCreator(admin.Id); err != nil {
+		t.Fatalf("RevokeByCreator() error = %v", err)
+	}
+	if _, err = ks.Resolve(context.Background(), adminKey.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Resolve() of a revoked key error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+}
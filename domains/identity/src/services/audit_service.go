@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"net/url"
+)
+
+// ErrInvalidAuditFilter is returned when the audit log is filtered by a malformed actor, subject or session id
+var ErrInvalidAuditFilter = errors.New("invalid audit log filter")
+
+// AuditService is used by the app to record and list the actions written to the audit log
+type AuditService struct {
+	auditRepo *repos.AuditRepo
+}
+
+// NewAuditService is an exported function used to initialize a new AuditService struct
+func NewAuditService(aHandler *repos.AuditRepo) *AuditService {
+	return &AuditService{aHandler}
+}
+
+// Record writes an event to the audit log along with the client it was performed from
+func (as *AuditService) Record(event *models.AuditEvent, client *models.ClientInfo) error {
+	if client != nil {
+		event.IP = client.IP
+		event.UserAgent = client.UserAgent
+	}
+	eventRec, err := repos.NewAuditEventRecord(event)
+	if err != nil {
+		return err
+	}
+	_, err = as.auditRepo.Handler.InsertOne(eventRec)
+	return err
+}
+
+// ParseQuery builds the Query of the filter params of the audit log from a url query
+func (as *AuditService) ParseQuery(values url.Values) (*databases.Query, error) {
+	return repos.AuditQuerySchema.Parse(values)
+}
+
+// Find returns a paginated AuditEventsPage of the events matching the filter and query, selected by cursor when the
+// pagination uses cursors
+func (as *AuditService) Find(ctx context.Context, filter *models.AuditEvent, query *databases.Query, pagination *utilities.Pagination) (*models.AuditEventsPage, error) {
+	eventRec, err := repos.NewAuditEventRecord(filter)
+	if err != nil {
+		return &models.AuditEventsPage{}, ErrInvalidAuditFilter
+	}
+	count, err := as.auditRepo.Handler.Count(eventRec, query)
+	if err != nil {
+		return &models.AuditEventsPage{}, err
+	}
+	if count == 0 {
+		return &models.AuditEventsPage{
+			TotalCount: 0,
+			TotalPages: 0,
+			Page:       0,
+			Size:       0,
+			HasMore:    false,
+			Events:     make([]*models.AuditEvent, 0),
+		}, nil
+	}
+	if pagination.UsesCursor() {
+		eventRecs, cursors, err := as.auditRepo.Handler.CursorFind(ctx, eventRec, pagination, query)
+		if err != nil {
+			return &models.AuditEventsPage{}, err
+		}
+		return &models.AuditEventsPage{
+			TotalCount: count,
+			Size:       int64(pagination.GetSize()),
+			HasMore:    cursors.Next != "",
+			NextCursor: cursors.Next,
+			PrevCursor: cursors.Prev,
+			Events:     repos.LoadAuditEventRecords(eventRecs),
+		}, nil
+	}
+	eventRecs, err := as.auditRepo.Handler.PaginatedFind(ctx, eventRec, pagination, query)
+	if err != nil {
+		return &models.AuditEventsPage{}, err
+	}
+	return &models.AuditEventsPage{
+		TotalCount: count,
+		TotalPages: int64(pagination.GetTotalPages(int(count))),
+		Page:       int64(pagination.GetPage()),
+		Size:       int64(pagination.GetSize()),
+		HasMore:    pagination.GetHasMore(int(count)),
+		Events:     repos.LoadAuditEventRecords(eventRecs),
+	}, nil
+}
@@ -1,67 +1,615 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/JECSand/eventit-server/domains/identity/src/models"
 	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/mailers"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
 	"time"
 )
 
+var (
+	// ErrEmptyPassword is returned when credentials are missing a password
+	ErrEmptyPassword = errors.New("password is empty")
+	// ErrEmptyEmail is returned when credentials are missing an email
+	ErrEmptyEmail = errors.New("email is empty")
+	// ErrEmptyToken is returned when an auth token is required but missing
+	ErrEmptyToken = errors.New("token is empty")
+	// ErrInvalidCredentials is returned when an input password does not match the stored hash
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidToken is returned when an auth token cannot be decoded or verified
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrTokenRevoked is returned when an auth token has been blacklisted
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrEmailNotVerified is returned on login by users with an unverified email when the policy requires one
+	ErrEmailNotVerified = errors.New("email address is not verified")
+	// ErrInvalidLoginToken is returned when a magic link login token is unknown, expired or already used
+	ErrInvalidLoginToken = errors.New("invalid or expired login token")
+	// ErrImpersonationNotAllowed is returned when a user may not impersonate the requested user
+	ErrImpersonationNotAllowed = errors.New("impersonation not allowed")
+	// ErrNotImpersonating is returned when stopping an impersonation with a token that is not impersonating a user
+	ErrNotImpersonating = errors.New("token is not impersonating a user")
+	// ErrCurrentPasswordRequired is returned when users change their own password without confirming the current one
+	ErrCurrentPasswordRequired = errors.New("the current password is required to change the password")
+	// ErrIncorrectPassword is returned when the current password confirming a password change does not match
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+)
+
+const (
+	// blacklistCacheTTL bounds how long a token known to not be blacklisted is cached, which is also
+	// the longest a logout performed on another instance can go unnoticed by this one
+	blacklistCacheTTL = 30 * time.Second
+	// blacklistCacheSize caps the number of token lookups cached in-process
+	blacklistCacheSize = 10000
+)
+
 // AuthService is used by the app to manage all user related controllers and functionality
 type AuthService struct {
 	userService *UserService
 	blacklist   *repos.BlacklistRepo
+	refresh     *repos.RefreshTokenRepo
+	tokenRepo   *repos.OneTimeTokenRepo
+	twoFactor   *TwoFactorService
+	sessions    *SessionService
+	orgs        *OrganizationService
+	audit       *AuditService
+	throttle    *loginThrottle
+	mailer      mailers.Mailer
+	revoked     *utilities.TTLCache[bool]
 }
 
 // NewAuthService is an exported function used to initialize a new UserService struct
-func NewAuthService(userService *UserService, blHandler *repos.BlacklistRepo) *AuthService {
+func NewAuthService(userService *UserService, twoFactor *TwoFactorService, sessions *SessionService, orgs *OrganizationService, audit *AuditService, blHandler *repos.BlacklistRepo, rtHandler *repos.RefreshTokenRepo, tHandler *repos.OneTimeTokenRepo, laHandler *repos.LoginAttemptRepo, mailer mailers.Mailer) *AuthService {
 	return &AuthService{
 		userService,
 		blHandler,
+		rtHandler,
+		tHandler,
+		twoFactor,
+		sessions,
+		orgs,
+		audit,
+		&loginThrottle{laHandler},
+		mailer,
+		utilities.NewTTLCache[bool](blacklistCacheSize),
+	}
+}
+
+// loginRequiresVerifiedEmail returns whether the auth_email_verification_policy requires a verified email to log in
+func loginRequiresVerifiedEmail() bool {
+	return auth.EmailVerificationPolicy() == auth.VerifyEmailForLogin
+}
+
+// issueRefreshToken generates a new refresh token for the input user within the token family of its session, twoFactor records
+// whether the family was started by a two factor login so that refreshed sessions keep it
+func (us *AuthService) issueRefreshToken(userId primitive.ObjectID, familyId primitive.ObjectID, twoFactor bool) (string, *repos.RefreshTokenRecord, error) {
+	token, err := utilities.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	rtRec, err := us.refresh.Handler.InsertOne(&repos.RefreshTokenRecord{
+		UserId:    userId,
+		FamilyId:  familyId,
+		TokenHash: utilities.HashToken(token),
+		TwoFactor: twoFactor,
+		ExpiresAt: time.Now().UTC().Add(auth.RefreshExpiry()),
+	})
+	if err != nil {
+		return "", nil, err
 	}
+	return token, rtRec, nil
 }
 
-func (us *AuthService) Login(credentials *models.Credentials) (*models.Auth, error) {
+// revokeRefreshFamily revokes every refresh token belonging to a token family
+func (us *AuthService) revokeRefreshFamily(familyId primitive.ObjectID) error {
+	_, err := us.refresh.Handler.UpdateMany(
+		&repos.RefreshTokenRecord{FamilyId: familyId},
+		&repos.RefreshTokenRecord{RevokedAt: time.Now().UTC()},
+	)
+	return err
+}
+
+// RevokeSessions revokes every session of a user but the session with the input keep id along with their refresh
+// tokens, so that their access tokens are rejected and none of them can be extended. An empty keep id revokes all
+func (us *AuthService) RevokeSessions(userId string, keepId string) error {
+	if keepId == "" {
+		return us.sessions.RevokeAll(userId)
+	}
+	return us.sessions.RevokeOthers(userId, keepId)
+}
+
+// VerifyCurrentPassword confirms a password change by a user with its current password, failures are throttled
+// like failed logins. Passwordless users have no current password and set their first one without it
+func (us *AuthService) VerifyCurrentPassword(user *models.User, password string, client *models.ClientInfo) error {
+	if user.Password == "" {
+		return nil
+	}
+	if password == "" {
+		return ErrCurrentPasswordRequired
+	}
+	if err := us.throttle.check(user.Email, client.IP); err != nil {
+		return err
+	}
+	_, err := user.VerifyPassword(password)
+	if !passwordMismatch(err) {
+		return err
+	}
+	if err = us.loginFailed(user.Email, client.IP, user); errors.Is(err, ErrInvalidCredentials) {
+		return ErrIncorrectPassword
+	}
+	return err
+}
+
+// Refresh rotates a refresh token sent from the input client and returns a new Auth with a fresh access and
+// refresh token, revoking the whole token family when a previously rotated token is reused
+func (us *AuthService) Refresh(credentials *models.RefreshCredentials, client *models.ClientInfo) (*models.Auth, error) {
+	a := &models.Auth{CreatedAt: time.Now().UTC()}
+	if credentials.RefreshToken == "" {
+		return a, ErrEmptyToken
+	}
+	rtRec, err := us.refresh.Handler.FindOne(&repos.RefreshTokenRecord{TokenHash: utilities.HashToken(credentials.RefreshToken)})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return a, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return a, err
+	}
+	if !rtRec.RotatedAt.IsZero() {
+		if err = us.revokeRefreshFamily(rtRec.FamilyId); err != nil {
+			return a, err
+		}
+		return a, ErrRefreshTokenReused
+	}
+	if !rtRec.RevokedAt.IsZero() || time.Now().UTC().After(rtRec.ExpiresAt) {
+		return a, ErrInvalidRefreshToken
+	}
+	foundUser, err := us.userService.FindById(rtRec.UserId.Hex())
+	if err != nil {
+		return a, err
+	}
+	token, newRec, err := us.issueRefreshToken(rtRec.UserId, rtRec.FamilyId, rtRec.TwoFactor)
+	if err != nil {
+		return a, err
+	}
+	rotated, err := us.refresh.Rotate(rtRec, newRec.Id)
+	if err != nil {
+		return a, err
+	}
+	if !rotated {
+		// a concurrent request rotated or revoked the token first, treat it as reuse
+		if err = us.revokeRefreshFamily(rtRec.FamilyId); err != nil {
+			return a, err
+		}
+		return a, ErrRefreshTokenReused
+	}
+	orgId, err := us.sessions.resume(rtRec.FamilyId, rtRec.UserId, client, rtRec.TwoFactor)
+	if errors.Is(err, ErrSessionRevoked) {
+		return a, ErrInvalidRefreshToken
+	} else if err != nil {
+		return a, err
+	}
+	if !orgId.IsZero() {
+		if a.Membership, err = us.rescope(rtRec.FamilyId.Hex(), orgId.Hex(), foundUser); err != nil {
+			return a, err
+		}
+	}
+	if err = a.Authorize(foundUser, rtRec.TwoFactor, rtRec.FamilyId.Hex()); err != nil {
+		return a, err
+	}
+	a.RefreshToken = token
+	return a, nil
+}
+
+// rescope returns the current membership of a user in the organization its session is scoped to, unscoping the
+// session when the user is no longer a member of the organization
+func (us *AuthService) rescope(sessionId string, orgId string, user *models.User) (*models.Membership, error) {
+	membership, err := us.orgs.membershipOf(orgId, user)
+	if errors.Is(err, ErrNotOrgMember) || errors.Is(err, ErrOrganizationNotFound) {
+		return nil, us.sessions.scope(sessionId, "")
+	}
+	return membership, err
+}
+
+// SwitchOrganization issues a new access token for the session of the input claims scoped to an organization the
+// user is a member of, or unscoped when orgId is empty. Later refreshes of the session keep the organization
+func (us *AuthService) SwitchOrganization(claims auth.AppClaims, orgId string) (*models.Auth, error) {
+	a := &models.Auth{CreatedAt: time.Now().UTC()}
+	if claims.ID == "" {
+		// tokens issued before sessions were persisted have no session to scope
+		return a, ErrSessionNotFound
+	}
+	foundUser, err := us.userService.FindById(claims.ProfileId)
+	if err != nil {
+		return a, err
+	}
+	if orgId != "" {
+		if a.Membership, err = us.orgs.membershipOf(orgId, foundUser); err != nil {
+			return a, err
+		}
+	}
+	if err = us.sessions.scope(claims.ID, orgId); err != nil {
+		return a, err
+	}
+	if err = a.Authorize(foundUser, claims.TwoFactor, claims.ID); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// Impersonate issues a time limited access token letting the user of the actor claims act as another user of a
+// lower role, the token carries an actor claim and cannot be refreshed. Impersonations are written to the audit log
+func (us *AuthService) Impersonate(actor auth.AppClaims, userId string, impersonation *models.Impersonation, client *models.ClientInfo) (*models.Auth, error) {
+	a := &models.Auth{CreatedAt: time.Now().UTC()}
+	if actor.IsImpersonated() || actor.IsIntegration() {
+		return a, ErrImpersonationNotAllowed
+	}
+	target, err := us.userService.FindById(userId)
+	if err != nil {
+		return a, err
+	}
+	if target.Id == actor.ProfileId || target.Role >= actor.Role {
+		return a, ErrImpersonationNotAllowed
+	}
+	impersonator, err := us.userService.FindById(actor.ProfileId)
+	if err != nil {
+		return a, err
+	}
+	targetId, _ := primitive.ObjectIDFromHex(target.Id)
+	actorId, _ := primitive.ObjectIDFromHex(impersonator.Id)
+	sessionId, err := us.sessions.startImpersonation(targetId, actorId, client)
+	if err != nil {
+		return a, err
+	}
+	event := &models.AuditEvent{
+		Action:    models.ImpersonationStarted,
+		ActorId:   impersonator.Id,
+		SubjectId: target.Id,
+		SessionId: sessionId.Hex(),
+	}
+	if impersonation != nil && impersonation.Reason != "" {
+		event.Data = map[string]string{"reason": impersonation.Reason}
+	}
+	if err = us.audit.Record(event, client); err != nil {
+		// an impersonation that could not be audited must not be usable
+		if revokeErr := us.sessions.Revoke(target.Id, sessionId.Hex()); revokeErr != nil {
+			log.Printf("unable to revoke unaudited impersonation session %s: %v", sessionId.Hex(), revokeErr)
+		}
+		return a, err
+	}
+	impersonator.Password = ""
+	a.Impersonator = impersonator
+	if err = a.Authorize(target, actor.TwoFactor, sessionId.Hex()); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// StopImpersonation revokes the impersonation session of the input claims and writes its end to the audit log
+func (us *AuthService) StopImpersonation(claims auth.AppClaims, client *models.ClientInfo) error {
+	if !claims.IsImpersonated() {
+		return ErrNotImpersonating
+	}
+	if err := us.sessions.Revoke(claims.ProfileId, claims.ID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	return us.audit.Record(&models.AuditEvent{
+		Action:    models.ImpersonationStopped,
+		ActorId:   claims.Actor.ProfileId,
+		SubjectId: claims.ProfileId,
+		SessionId: claims.ID,
+	}, client)
+}
+
+// Login authenticates a set of credentials sent from the input client, failed attempts are throttled with an
+// exponential backoff per email and per client ip and lock the account out once auth_login_max_failures is reached
+func (us *AuthService) Login(credentials *models.Credentials, client *models.ClientInfo) (*models.Auth, error) {
 	auth := &models.Auth{CreatedAt: time.Now().UTC()}
 	if credentials.Password == "" {
-		return auth, errors.New("password is empty")
+		return auth, ErrEmptyPassword
 	}
 	if credentials.Email == "" {
-		return auth, errors.New("email is empty")
+		return auth, ErrEmptyEmail
+	}
+	if err := us.throttle.check(credentials.Email, client.IP); err != nil {
+		return auth, err
 	}
 	foundUser, err := us.userService.FindByEmail(credentials.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		foundUser = nil
+	} else if err != nil {
+		return auth, err
+	}
+	if foundUser != nil && foundUser.Locked() {
+		return auth, &RetryError{Err: ErrAccountLocked, After: time.Until(foundUser.LockedUntil)}
+	}
+	// unknown emails and passwordless users, who can only log in through a magic link, fail like a wrong password
+	rehash := false
+	if foundUser == nil || foundUser.Password == "" {
+		err = ErrInvalidCredentials
+	} else if rehash, err = foundUser.VerifyPassword(credentials.Password); passwordMismatch(err) {
+		err = ErrInvalidCredentials
+	}
+	if errors.Is(err, ErrInvalidCredentials) {
+		return auth, us.loginFailed(credentials.Email, client.IP, foundUser)
+	}
 	if err != nil {
 		return auth, err
 	}
-	if err = auth.Authenticate(foundUser, credentials.Password); err != nil {
+	if rehash {
+		// the password is known to be correct, so a failed upgrade must not fail the login
+		if err = us.userService.rehashPassword(foundUser.Id, credentials.Password); err != nil {
+			log.Printf("unable to rehash the password of user %s: %v", foundUser.Id, err)
+		}
+	}
+	if !foundUser.EmailVerified() && loginRequiresVerifiedEmail() {
+		return auth, ErrEmailNotVerified
+	}
+	if auth, err = us.startSession(auth, foundUser, client); err != nil || auth.Challenge != "" {
+		// the failed logins of users with two factor authentication are only forgotten once it is completed
+		return auth, err
+	}
+	return auth, us.throttle.reset(credentials.Email)
+}
+
+// passwordMismatch returns whether a password verification error is a wrong password rather than a failure to verify it
+func passwordMismatch(err error) bool {
+	return errors.Is(err, auth.ErrPasswordMismatch)
+}
+
+// loginFailed records a failed login and locks the user out once it reached the failure threshold
+func (us *AuthService) loginFailed(email string, clientIP string, user *models.User) error {
+	lockedUntil, err := us.throttle.fail(email, clientIP)
+	if err != nil {
+		return err
+	}
+	if lockedUntil.IsZero() || user == nil {
+		return ErrInvalidCredentials
+	}
+	userId, err := primitive.ObjectIDFromHex(user.Id)
+	if err != nil {
+		return ErrInvalidUserId
+	}
+	if err = us.userService.userRepo.Lock(userId, lockedUntil); err != nil {
+		return err
+	}
+	return &RetryError{Err: ErrAccountLocked, After: time.Until(lockedUntil)}
+}
+
+// Unlock lifts the lockout of a user along with its tracked failed logins
+func (us *AuthService) Unlock(userId string) (*models.User, error) {
+	user, err := us.userService.FindById(userId)
+	if err != nil {
+		return user, err
+	}
+	id, err := primitive.ObjectIDFromHex(user.Id)
+	if err != nil {
+		return user, ErrInvalidUserId
+	}
+	if err = us.userService.userRepo.Unlock(id); err != nil {
+		return user, err
+	}
+	if err = us.throttle.reset(user.Email); err != nil {
+		return user, err
+	}
+	return us.userService.FindById(userId)
+}
+
+// startSession completes the first factor of a login, returning a two factor challenge instead of a session
+// when the user has two factor authentication enabled
+func (us *AuthService) startSession(auth *models.Auth, user *models.User, client *models.ClientInfo) (*models.Auth, error) {
+	enabled, err := us.twoFactor.Enabled(user.Id)
+	if err != nil {
+		return auth, err
+	}
+	if enabled {
+		auth.Challenge, err = issueOneTimeToken(us.tokenRepo, user.Id, models.TwoFactorChallenge, loginTokenExpiry())
+		return auth, err
+	}
+	return us.issueSession(auth, user, false, client)
+}
+
+// issueSession persists a new session for a user logging in from the input client and returns a new Auth with
+// its access and refresh tokens
+func (us *AuthService) issueSession(auth *models.Auth, user *models.User, twoFactor bool, client *models.ClientInfo) (*models.Auth, error) {
+	userId, err := primitive.ObjectIDFromHex(user.Id)
+	if err != nil {
+		return auth, ErrInvalidUserId
+	}
+	sessionId, err := us.sessions.start(userId, client, twoFactor)
+	if err != nil {
+		return auth, err
+	}
+	if err = auth.Authorize(user, twoFactor, sessionId.Hex()); err != nil {
+		return auth, err
+	}
+	if auth.RefreshToken, _, err = us.issueRefreshToken(userId, sessionId, twoFactor); err != nil {
 		return auth, err
 	}
 	return auth, nil
 }
 
-func (us *AuthService) Logout(auth *models.Auth) error {
-	if auth.AuthToken == "" {
-		return errors.New("token is empty")
+// LoginWithTwoFactor completes a login by exchanging its two factor challenge along with a TOTP or recovery code
+// for a new Auth, the challenge is consumed by the attempt whether or not the code is valid. Invalid codes count as
+// failed logins of the user, so that codes cannot be guessed faster than passwords
+func (us *AuthService) LoginWithTwoFactor(code *models.TwoFactorCode, client *models.ClientInfo) (*models.Auth, error) {
+	auth := &models.Auth{CreatedAt: time.Now().UTC()}
+	if code.Challenge == "" || code.Code == "" {
+		return auth, ErrEmptyToken
+	}
+	tokenRec, err := us.tokenRepo.Consume(utilities.HashToken(code.Challenge), models.TwoFactorChallenge)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return auth, ErrInvalidChallenge
+	}
+	if err != nil {
+		return auth, err
+	}
+	userId := tokenRec.UserId.Hex()
+	foundUser, err := us.userService.FindById(userId)
+	if err != nil {
+		return auth, err
+	}
+	if err = us.throttle.check(foundUser.Email, client.IP); err != nil {
+		return auth, err
+	}
+	if err = us.twoFactor.Verify(userId, code.Code); errors.Is(err, ErrInvalidTwoFactorCode) {
+		if failErr := us.loginFailed(foundUser.Email, client.IP, foundUser); !errors.Is(failErr, ErrInvalidCredentials) {
+			return auth, failErr
+		}
+		return auth, err
+	} else if err != nil {
+		return auth, err
+	}
+	if err = us.throttle.reset(foundUser.Email); err != nil {
+		return auth, err
+	}
+	return us.issueSession(auth, foundUser, true, client)
+}
+
+// SendLoginLink emails a single use magic link login token to the input email, signing up a passwordless member
+// when auth_login_link_signup is enabled and unknown emails are otherwise silently ignored
+func (us *AuthService) SendLoginLink(ctx context.Context, email string) error {
+	if email == "" {
+		return ErrEmptyEmail
 	}
-	_, err := us.blacklist.Handler.InsertOne(&repos.BlacklistRecord{AuthToken: auth.AuthToken})
-	if err == nil {
+	user, err := us.userService.FindOrRegister(email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
 		return err
 	}
-	auth.Invalidate()
-	return nil
+	token, err := issueOneTimeToken(us.tokenRepo, user.Id, models.LoginToken, loginTokenExpiry())
+	if err != nil {
+		return err
+	}
+	return us.mailer.Send(ctx, &mailers.Message{
+		To:      user.Email,
+		Subject: "Your eventit login link",
+		Body: fmt.Sprintf("Use the code %s or follow the link below to log in, it expires in %s.\n\n%s",
+			token, loginTokenExpiry(), tokenLink("auth_login_url", token)),
+	})
+}
+
+// LoginWithToken consumes a magic link login token and returns a new Auth for its user, since the token was
+// delivered by email it also proves the user owns its email address
+func (us *AuthService) LoginWithToken(link *models.LoginLink, client *models.ClientInfo) (*models.Auth, error) {
+	auth := &models.Auth{CreatedAt: time.Now().UTC()}
+	if link.Token == "" {
+		return auth, ErrEmptyToken
+	}
+	tokenRec, err := us.tokenRepo.Consume(utilities.HashToken(link.Token), models.LoginToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return auth, ErrInvalidLoginToken
+	}
+	if err != nil {
+		return auth, err
+	}
+	foundUser, err := us.userService.MarkEmailVerified(tokenRec.UserId.Hex())
+	if err != nil {
+		return auth, err
+	}
+	return us.startSession(auth, foundUser, client)
 }
 
-func (us *AuthService) Validate(auth *models.Auth) error {
-	if auth.AuthToken == "" {
-		return errors.New("token is empty")
+func (us *AuthService) Logout(a *models.Auth) error {
+	if a.AuthToken == "" {
+		return ErrEmptyToken
+	}
+	claims, err := auth.VerifyToken(context.Background(), a.AuthToken)
+	if err != nil {
+		return ErrInvalidToken
 	}
-	if err := auth.LoadSession(); err != nil {
+	blRec := &repos.BlacklistRecord{AuthToken: a.AuthToken}
+	if claims.ExpiresAt != nil {
+		blRec.ExpiresAt = claims.ExpiresAt.Time
+	}
+	if _, err = us.blacklist.Handler.InsertOne(blRec); err != nil {
 		return err
 	}
-	foundUser, err := us.userService.FindById(auth.Session.ProfileId)
+	us.revoked.Set(utilities.HashToken(a.AuthToken), true, time.Until(blRec.ExpiresAt))
+	if claims.IsImpersonated() {
+		if err = us.StopImpersonation(*claims, nil); err != nil {
+			return err
+		}
+	} else if claims.ID != "" {
+		if err = us.sessions.Revoke(claims.ProfileId, claims.ID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	if a.RefreshToken != "" {
+		rtRec, err := us.refresh.Handler.FindOne(&repos.RefreshTokenRecord{TokenHash: utilities.HashToken(a.RefreshToken)})
+		if err == nil && rtRec.UserId.Hex() == claims.ProfileId {
+			if err = us.revokeRefreshFamily(rtRec.FamilyId); err != nil {
+				return err
+			}
+		}
+	}
+	a.Invalidate()
+	return nil
+}
+
+func (us *AuthService) Validate(a *models.Auth) error {
+	if a.AuthToken == "" {
+		return ErrEmptyToken
+	}
+	claims, err := auth.VerifyToken(context.Background(), a.AuthToken)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err = a.LoadSession(); err != nil {
+		return ErrInvalidToken
+	}
+	foundUser, err := us.userService.FindById(a.Session.ProfileId)
 	if err != nil {
 		return err
 	}
-	auth.User = foundUser
+	foundUser.Password = ""
+	a.User = foundUser
+	a.Permissions = claims.Permissions()
+	if claims.IsImpersonated() {
+		if a.Impersonator, err = us.userService.FindById(claims.Actor.ProfileId); err != nil {
+			return err
+		}
+		a.Impersonator.Password = ""
+	}
 	return nil
 }
+
+// CheckBlacklist is an auth.TokenCheck rejecting tokens that were blacklisted on logout, lookups are
+// cached in-process so that only the first request of a token within blacklistCacheTTL hits the DB
+func (us *AuthService) CheckBlacklist(ctx context.Context, tokenString string, claims *auth.AppClaims) error {
+	key := utilities.HashToken(tokenString)
+	if revoked, ok := us.revoked.Get(key); ok {
+		if revoked {
+			return ErrTokenRevoked
+		}
+		return nil
+	}
+	_, err := us.blacklist.Handler.FindOne(&repos.BlacklistRecord{AuthToken: tokenString})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ttl := blacklistCacheTTL
+		if claims.ExpiresAt != nil && time.Until(claims.ExpiresAt.Time) < ttl {
+			ttl = time.Until(claims.ExpiresAt.Time)
+		}
+		us.revoked.Set(key, false, ttl)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if claims.ExpiresAt != nil {
+		us.revoked.Set(key, true, time.Until(claims.ExpiresAt.Time))
+	}
+	return ErrTokenRevoked
+}
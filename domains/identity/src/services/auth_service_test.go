@@ -0,0 +1,417 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestAuthService returns an AuthService on the input DBClient along with the mailer of its UserService
+func newTestAuthService(t *testing.T, db databases.DBClient) (*AuthService, *testMailer) {
+	t.Helper()
+	viper.SetDefault("auth_jwt_secret", "random")
+	viper.SetDefault("auth_jwt_expiry", "15m")
+	viper.SetDefault("auth_jwt_refresh_expiry", "1h")
+	us, mailer := newTestUserService(db)
+	refresh := repos.NewRefreshTokenRepo(db)
+	return NewAuthService(
+		us,
+		NewTwoFactorService(us, repos.NewTwoFactorRepo(db)),
+		NewSessionService(repos.NewSessionRepo(db), refresh),
+		nil,
+		NewAuditService(repos.NewAuditRepo(db)),
+		repos.NewBlacklistRepo(db),
+		refresh,
+		us.tokenRepo,
+		repos.NewLoginAttemptRepo(db),
+		mailer,
+	), mailer
+}
+
+// loginTestUser logs a user in with testPassword
+func loginTestUser(t *testing.T, as *AuthService, email string) *models.Auth {
+	t.Helper()
+	a, err := as.Login(&models.Credentials{Email: email, Password: testPassword}, testClient)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	return a
+}
+
+func TestAuthService_Login(t *testing.T) {
+	as, _ := newTestAuthService(t, newTestDB(t))
+	user := createTestUser(t, as.userService, "ann@example.com", enums.MEMBER)
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name        string              // The name of the test
+		credentials *models.Credentials // The credentials logging in
+		wantErr     error               // The error we want Login to return
+	}{
+		{"missing password", &models.Credentials{Email: user.Email}, ErrEmptyPassword},
+		{"missing email", &models.Credentials{Password: testPassword}, ErrEmptyEmail},
+		{"unknown email", &models.Credentials{Email: "bob@example.com", Password: testPassword}, ErrInvalidCredentials},
+		{"valid credentials", &models.Credentials{Email: user.Email, Password: testPassword}, nil},
+		{"wrong password", &models.Credentials{Email: user.Email, Password: "wrong-Password-1"}, ErrInvalidCredentials},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// every case logs in from its own client so that failures are not throttled by the ones before
+			client := &models.ClientInfo{IP: fmt.Sprintf("198.51.100.%d", i+1)}
+			a, err := as.Login(tt.credentials, client)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			claims, err := auth.DecodeJWT(a.AuthToken)
+			if err != nil {
+				t.Fatalf("DecodeJWT() error = %v", err)
+			}
+			if claims.ProfileId != user.Id || claims.ID == "" || a.RefreshToken == "" {
+				t.Errorf("Login() = %+v, want the tokens of a session of user %s", a, user.Id)
+			}
+		})
+	}
+}
+
+func TestAuthService_ValidateLogout(t *testing.T) {
+	as, _ := newTestAuthService(t, newTestDB(t))
+	user := createTestUser(t, as.userService, "ann@example.com", enums.MEMBER)
+	a := loginTestUser(t, as, user.Email)
+	token := a.AuthToken
+	validated := &models.Auth{AuthToken: token}
+	if err := as.Validate(validated); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if validated.User == nil || validated.User.Id != user.Id || validated.User.Password != "" {
+		t.Errorf("Validate() user = %+v, want user %s without its password", validated.User, user.Id)
+	}
+	if err := as.Validate(&models.Auth{AuthToken: "not-a-token"}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Validate() of a malformed token error = %v, want %v", err, ErrInvalidToken)
+	}
+	if err := as.Logout(a); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if a.AuthToken != "" || a.RefreshToken != "" {
+		t.Errorf("Logout() kept the tokens of the Auth")
+	}
+	if _, err := as.blacklist.Handler.FindOne(&repos.BlacklistRecord{AuthToken: token}); err != nil {
+		t.Errorf("Logout() did not blacklist the token: %v", err)
+	}
+	if err := as.Logout(&models.Auth{}); !errors.Is(err, ErrEmptyToken) {
+		t.Errorf("Logout() without a token error = %v, want %v", err, ErrEmptyToken)
+	}
+}
+
+func TestAuthService_VerifyCurrentPassword(t *testing.T) {
+	db := newTestDB(t)
+	us, _ := newTestUserService(db)
+	as := &AuthService{userService: us, throttle: &loginThrottle{repos.NewLoginAttemptRepo(db)}}
+	user := createTestUser(t, us, "ann@example.com", enums.MEMBER)
+	passwordless, err := us.registerPasswordless(&models.User{Email: "bob@example.com"})
+	if err != nil {
+		t.Fatalf("registerPasswordless() error = %v", err)
+	}
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name     string       // The name of the test
+		user     *models.User // The user changing its password
+		password string       // The current password confirming the change
+		wantErr  error        // The error we want VerifyCurrentPassword to return
+	}{
+		{"current password", user, testPassword, nil},
+		{"missing password", user, "", ErrCurrentPasswordRequired},
+		{"passwordless user", passwordless, "", nil},
+		{"wrong password", user, "wrong-Password-1", ErrIncorrectPassword},
+		{"throttled after a wrong password", user, testPassword, ErrLoginThrottled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := as.VerifyCurrentPassword(tt.user, tt.password, testClient); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyCurrentPassword() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	as, _ := newTestAuthService(t, newTestDB(t))
+	user := createTestUser(t, as.userService, "ann@example.com", enums.MEMBER)
+	a := loginTestUser(t, as, user.Email)
+	refresh := func(token string) (*models.Auth, error) {
+		return as.Refresh(&models.RefreshCredentials{RefreshToken: token}, testClient)
+	}
+	first, err := refresh(a.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	loginClaims, _ := auth.DecodeJWT(a.AuthToken)
+	claims, err := auth.DecodeJWT(first.AuthToken)
+	if err != nil {
+		t.Fatalf("DecodeJWT() error = %v", err)
+	}
+	if claims.ProfileId != user.Id || claims.ID != loginClaims.ID {
+		t.Errorf("Refresh() claims = %+v, want the session %s of user %s", claims, loginClaims.ID, user.Id)
+	}
+	if first.RefreshToken == "" || first.RefreshToken == a.RefreshToken {
+		t.Errorf("Refresh() did not rotate the refresh token")
+	}
+	second, err := refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() with the rotated token error = %v", err)
+	}
+	if _, err = refresh(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("second Refresh() with the same token error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	// the reuse revoked the whole family, including the token it was rotated into
+	if _, err = refresh(second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() with a token of a revoked family error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err = refresh("unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() with an unknown token error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	other := loginTestUser(t, as, user.Email)
+	if _, err = refresh(other.RefreshToken); err != nil {
+		t.Errorf("Refresh() of another session error = %v", err)
+	}
+}
+
+func TestAuthService_CheckBlacklist(t *testing.T) {
+	db := newTestDB(t)
+	as, _ := newTestAuthService(t, db)
+	other, _ := newTestAuthService(t, db)
+	user := createTestUser(t, as.userService, "ann@example.com", enums.MEMBER)
+	a := loginTestUser(t, as, user.Email)
+	token := a.AuthToken
+	claims, err := auth.DecodeJWT(token)
+	if err != nil {
+		t.Fatalf("DecodeJWT() error = %v", err)
+	}
+	// the token is cached as not revoked, the logout must still take effect on the next request
+	if err = as.CheckBlacklist(context.Background(), token, claims); err != nil {
+		t.Fatalf("CheckBlacklist() error = %v", err)
+	}
+	if err = as.Logout(a); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if err = as.CheckBlacklist(context.Background(), token, claims); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("CheckBlacklist() of a logged out token error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if err = other.CheckBlacklist(context.Background(), token, claims); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("CheckBlacklist() on another instance error = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestAuthService_CheckBlacklistCacheExpiry(t *testing.T) {
+	as, _ := newTestAuthService(t, newTestDB(t))
+	// a token expiring well within blacklistCacheTTL, blacklisted by another instance once it was cached
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	claims := &auth.AppClaims{RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: &jwt.NumericDate{Time: expiresAt}}}
+	if err := as.CheckBlacklist(context.Background(), "token", claims); err != nil {
+		t.Fatalf("CheckBlacklist() error = %v", err)
+	}
+	if _, err := as.blacklist.Handler.InsertOne(&repos.BlacklistRecord{AuthToken: "token", ExpiresAt: expiresAt}); err != nil {
+		t.Fatalf("InsertOne() error = %v", err)
+	}
+	if err := as.CheckBlacklist(context.Background(), "token", claims); err != nil {
+		t.Errorf("CheckBlacklist() of a cached token error = %v, want the cached lookup", err)
+	}
+	time.Sleep(time.Until(expiresAt) + 10*time.Millisecond)
+	if err := as.CheckBlacklist(context.Background(), "token", claims); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("CheckBlacklist() once the token expired error = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestAuthService_LoginWithToken(t *testing.T) {
+	as, mailer := newTestAuthService(t, newTestDB(t))
+	user := createTestUser(t, as.userService, "ann@example.com", enums.MEMBER)
+	if err := as.SendLoginLink(context.Background(), user.Email); err != nil {
+		t.Fatalf("SendLoginLink() error = %v", err)
+	}
+	link := &models.LoginLink{Token: mailer.token(user.Email)}
+	a, err := as.LoginWithToken(link, testClient)
+	if err != nil {
+		t.Fatalf("LoginWithToken() error = %v", err)
+	}
+	if a.User == nil || a.User.Id != user.Id || !a.User.EmailVerified() {
+		t.Errorf("LoginWithToken() user = %+v, want user %s with a verified email", a.User, user.Id)
+	}
+	if _, err = as.LoginWithToken(link, testClient); !errors.Is(err, ErrInvalidLoginToken) {
+		t.Errorf("second LoginWithToken() error = %v, want %v", err, ErrInvalidLoginToken)
+	}
+	// unknown emails are ignored unless auth_login_link_signup allows signing them up
+	sent := len(mailer.sent)
+	if err = as.SendLoginLink(context.Background(), "bob@example.com"); err != nil || len(mailer.sent) != sent {
+		t.Errorf("SendLoginLink() of an unknown email error = %v, sent %d, want nil and nothing sent", err, len(mailer.sent)-sent)
+	}
+	viper.Set("auth_login_link_signup", true)
+	t.Cleanup(func() { viper.Set("auth_login_link_signup", false) })
+	if err = as.SendLoginLink(context.Background(), "bob@example.com"); err != nil {
+		t.Fatalf("SendLoginLink() with signup error = %v", err)
+	}
+	if a, err = as.LoginWithToken(&models.LoginLink{Token: mailer.token("bob@example.com")}, testClient); err != nil {
+		t.Fatalf("LoginWithToken() of a new user error = %v", err)
+	}
+	if a.User == nil || a.User.Role != enums.MEMBER || !a.User.EmailVerified() {
+		t.Errorf("LoginWithToken() of a new user = %+v, want a verified member", a.User)
+	}
+}
+
+// loginTestClaims logs a user in with testPassword, returning the claims of its access token
+func loginTestClaims(t *testing.T, as *AuthService, email string) auth.AppClaims {
+	t.Helper()
+	claims, err := auth.DecodeJWT(loginTestUser(t, as, email).AuthToken)
+	if err != nil {
+		t.Fatalf("DecodeJWT() error = %v", err)
+	}
+	return *claims
+}
+
+// errAuditUnavailable is returned by every write to the audit log of an auditDownClient
+var errAuditUnavailable = errors.New("audit log unavailable")
+
+// auditDownClient is a DBClient whose audit log collection rejects every insert
+type auditDownClient struct {
+	databases.DBClient
+}
+
+// GetCollection returns the collection of the input name, the audit log rejecting inserts
+func (c *auditDownClient) GetCollection(collectionName string) databases.DBCollection {
+	col := c.DBClient.GetCollection(collectionName)
+	if collectionName == "audit_log" {
+		return &auditDownCollection{col}
+	}
+	return col
+}
+
+// auditDownCollection is a DBCollection rejecting every insert
+type auditDownCollection struct {
+	databases.DBCollection
+}
+
+// InsertOne always fails with errAuditUnavailable
+func (c *auditDownCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return nil, errAuditUnavailable
+}
+
+func TestAuthService_Impersonate(t *testing.T) {
+	as, _ := newTestAuthService(t, newTestDB(t))
+	root := createTestUser(t, as.userService, "root@example.com", enums.ROOT)
+	admin := createTestUser(t, as.userService, "admin@example.com", enums.ADMIN)
+	otherAdmin := createTestUser(t, as.userService, "other@example.com", enums.ADMIN)
+	member := createTestUser(t, as.userService, "member@example.com", enums.MEMBER)
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name    string       // The name of the test
+		actor   *models.User // The user impersonating
+		target  *models.User // The user being impersonated
+		wantErr error        // The error we want Impersonate to return
+	}{
+		{"admin impersonating a member", admin, member, nil},
+		{"root impersonating an admin", root, admin, nil},
+		{"admin impersonating an admin", admin, otherAdmin, ErrImpersonationNotAllowed},
+		{"admin impersonating root", admin, root, ErrImpersonationNotAllowed},
+		{"member impersonating an admin", member, admin, ErrImpersonationNotAllowed},
+		{"impersonating oneself", admin, admin, ErrImpersonationNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor := loginTestClaims(t, as, tt.actor.Email)
+			a, err := as.Impersonate(actor, tt.target.Id, &models.Impersonation{Reason: "support"}, testClient)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Impersonate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			claims, err := auth.DecodeJWT(a.AuthToken)
+			if err != nil {
+				t.Fatalf("DecodeJWT() error = %v", err)
+			}
+			if claims.ProfileId != tt.target.Id || !claims.IsImpersonated() || claims.Actor.ProfileId != tt.actor.Id {
+				t.Errorf("Impersonate() claims = %+v, want %s impersonated by %s", claims, tt.target.Id, tt.actor.Id)
+			}
+			if a.RefreshToken != "" {
+				t.Errorf("Impersonate() issued a refresh token")
+			}
+		})
+	}
+	// an impersonation token cannot start another impersonation
+	a, err := as.Impersonate(loginTestClaims(t, as, root.Email), admin.Id, nil, testClient)
+	if err != nil {
+		t.Fatalf("Impersonate() error = %v", err)
+	}
+	impersonated, err := auth.DecodeJWT(a.AuthToken)
+	if err != nil {
+		t.Fatalf("DecodeJWT() error = %v", err)
+	}
+	if _, err = as.Impersonate(*impersonated, member.Id, nil, testClient); !errors.Is(err, ErrImpersonationNotAllowed) {
+		t.Errorf("Impersonate() with an impersonation token error = %v, want %v", err, ErrImpersonationNotAllowed)
+	}
+}
+
+func TestAuthService_ImpersonateUnaudited(t *testing.T) {
+	as, _ := newTestAuthService(t, &auditDownClient{newTestDB(t)})
+	admin := createTestUser(t, as.userService, "admin@example.com", enums.ADMIN)
+	member := createTestUser(t, as.userService, "member@example.com", enums.MEMBER)
+	actor := loginTestClaims(t, as, admin.Email)
+	if _, err := as.Impersonate(actor, member.Id, nil, testClient); !errors.Is(err, errAuditUnavailable) {
+		t.Fatalf("Impersonate() error = %v, want %v", err, errAuditUnavailable)
+	}
+	// the impersonation session was started before the audit write failed and must have been revoked
+	if sessions, _ := as.sessions.FindByUser(member.Id, ""); len(sessions) != 0 {
+		t.Errorf("FindByUser() returned %d active sessions, want 0", len(sessions))
+	}
+}
+
+func TestDenyImpersonation(t *testing.T) {
+	as, _ := newTestAuthService(t, newTestDB(t))
+	admin := createTestUser(t, as.userService, "admin@example.com", enums.ADMIN)
+	member := createTestUser(t, as.userService, "member@example.com", enums.MEMBER)
+	own := loginTestUser(t, as, admin.Email)
+	actor, err := auth.DecodeJWT(own.AuthToken)
+	if err != nil {
+		t.Fatalf("DecodeJWT() error = %v", err)
+	}
+	impersonation, err := as.Impersonate(*actor, member.Id, nil, testClient)
+	if err != nil {
+		t.Fatalf("Impersonate() error = %v", err)
+	}
+	sensitive := auth.VerifyMemberMiddleWare(auth.DenyImpersonation(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name  string // The name of the test
+		token string // The Auth-Token of the request
+		want  int    // The status code we want the sensitive route to respond with
+	}{
+		{"own token", own.AuthToken, http.StatusNoContent},
+		{"impersonation token", impersonation.AuthToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/users/"+member.Id, nil)
+			r.Header.Set("Auth-Token", tt.token)
+			w := httptest.NewRecorder()
+			sensitive(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	"github.com/JECSand/eventit-server/domains/identity/src/oidc"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrUnknownProvider is returned when an OpenID Connect provider is not configured
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	// ErrInvalidOIDCState is returned when an OpenID Connect callback state is unknown, expired or already used
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+	// ErrOIDCEmailNotVerified is returned when a new external identity does not come with a verified email
+	ErrOIDCEmailNotVerified = errors.New("identity provider did not verify the email address")
+	// ErrLinkedIdentityNotFound is returned when no linked identity matches the requested id
+	ErrLinkedIdentityNotFound = errors.New("linked identity not found")
+)
+
+// oidcStateExpiry bounds how long a user has to authenticate with the provider once a login started
+const oidcStateExpiry = 10 * time.Minute
+
+// OIDCService is used by the app to log users in through external OpenID Connect providers
+type OIDCService struct {
+	clients      map[string]*oidc.Client
+	userService  *UserService
+	authService  *AuthService
+	identityRepo *repos.LinkedIdentityRepo
+	tokenRepo    *repos.OneTimeTokenRepo
+}
+
+// NewOIDCService is an exported function used to initialize a new OIDCService struct
+func NewOIDCService(clients map[string]*oidc.Client, userService *UserService, authService *AuthService, iHandler *repos.LinkedIdentityRepo, tHandler *repos.OneTimeTokenRepo) *OIDCService {
+	return &OIDCService{
+		clients,
+		userService,
+		authService,
+		iHandler,
+		tHandler,
+	}
+}
+
+// Providers returns the names of the configured providers
+func (oc *OIDCService) Providers() []string {
+	names := make([]string, 0, len(oc.clients))
+	for name := range oc.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// client returns the Client of a configured provider
+func (oc *OIDCService) client(provider string) (*oidc.Client, error) {
+	client, ok := oc.clients[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return client, nil
+}
+
+// Begin starts an authorization code login with a provider, storing its state, nonce and PKCE verifier and
+// returning the provider url to redirect the user to
+func (oc *OIDCService) Begin(ctx context.Context, provider string) (string, error) {
+	client, err := oc.client(provider)
+	if err != nil {
+		return "", err
+	}
+	state, err := utilities.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := utilities.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewPKCEVerifier()
+	if err != nil {
+		return "", err
+	}
+	_, err = oc.tokenRepo.Handler.InsertOne(&repos.OneTimeTokenRecord{
+		Purpose:   models.OIDCState,
+		TokenHash: utilities.HashToken(state),
+		Data:      map[string]string{"provider": provider, "nonce": nonce, "verifier": verifier},
+		ExpiresAt: time.Now().UTC().Add(oidcStateExpiry),
+	})
+	if err != nil {
+		return "", err
+	}
+	return client.AuthCodeURL(ctx, state, nonce, verifier)
+}
+
+// Callback completes an authorization code login, exchanging the code for an id token and logging in the user
+// its external identity is linked to. New identities are linked to the user with the same verified email, or to
+// a new passwordless member when there is none
+func (oc *OIDCService) Callback(ctx context.Context, provider string, callback *models.OIDCCallback, clientInfo *models.ClientInfo) (*models.Auth, error) {
+	auth := &models.Auth{CreatedAt: time.Now().UTC()}
+	client, err := oc.client(provider)
+	if err != nil {
+		return auth, err
+	}
+	if callback.State == "" || callback.Code == "" {
+		return auth, ErrEmptyToken
+	}
+	stateRec, err := oc.tokenRepo.Consume(utilities.HashToken(callback.State), models.OIDCState)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && stateRec.Data["provider"] != provider) {
+		return auth, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return auth, err
+	}
+	tokens, err := client.Exchange(ctx, callback.Code, stateRec.Data["verifier"])
+	if err != nil {
+		return auth, err
+	}
+	claims, err := client.VerifyIDToken(ctx, tokens.IDToken, stateRec.Data["nonce"])
+	if err != nil {
+		return auth, err
+	}
+	user, err := oc.resolveUser(provider, claims)
+	if err != nil {
+		return auth, err
+	}
+	return oc.authService.startSession(auth, user, clientInfo)
+}
+
+// resolveUser returns the user an external identity is linked to, linking it first when it is new
+func (oc *OIDCService) resolveUser(provider string, claims *oidc.IDTokenClaims) (*models.User, error) {
+	idRec, err := oc.identityRepo.Handler.FindOne(&repos.LinkedIdentityRecord{Provider: provider, Subject: claims.Subject})
+	if err == nil {
+		return oc.linkedUser(idRec, claims)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return oc.link(provider, claims)
+}
+
+// linkedUser returns the user an existing linked identity belongs to, a link to a purged user is removed and the
+// identity is linked again like a new one
+func (oc *OIDCService) linkedUser(idRec *repos.LinkedIdentityRecord, claims *oidc.IDTokenClaims) (*models.User, error) {
+	user, err := oc.userService.FindById(idRec.UserId.Hex())
+	if err == nil {
+		_, err = oc.identityRepo.Handler.UpdateOne(&repos.LinkedIdentityRecord{Id: idRec.Id}, &repos.LinkedIdentityRecord{
+			Email:       claims.Email,
+			LastLoginAt: time.Now().UTC(),
+		})
+		return user, err
+	}
+	if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+	// a deleted user keeps its links until it is purged so that it can be restored
+	if _, err = oc.userService.FindDeletedById(idRec.UserId.Hex()); !errors.Is(err, ErrUserNotFound) {
+		if err == nil {
+			err = ErrUserNotFound
+		}
+		return nil, err
+	}
+	if _, err = oc.identityRepo.Handler.DeleteOne(&repos.LinkedIdentityRecord{Id: idRec.Id}); err != nil {
+		return nil, err
+	}
+	return oc.link(idRec.Provider, claims)
+}
+
+// link links a new external identity to the user with the same verified email, or to a new passwordless member
+func (oc *OIDCService) link(provider string, claims *oidc.IDTokenClaims) (*models.User, error) {
+	// an unverified email could belong to someone else, so it must never be used to link an existing account
+	if !claims.EmailVerified || !utilities.IsValidEmail(claims.Email) {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	user, err := oc.userService.FindByEmail(claims.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		user, err = oc.userService.registerPasswordless(&models.User{
+			Email:     claims.Email,
+			FirstName: claims.GivenName,
+			LastName:  claims.FamilyName,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	idRec, err := repos.NewLinkedIdentityRecord(&models.LinkedIdentity{
+		UserId:      user.Id,
+		Provider:    provider,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, ErrInvalidUserId
+	}
+	if _, err = oc.identityRepo.Handler.InsertOne(idRec); err != nil {
+		return nil, err
+	}
+	return oc.userService.MarkEmailVerified(user.Id)
+}
+
+// FindLinked returns the external identities linked to a user
+func (oc *OIDCService) FindLinked(userId string) ([]*models.LinkedIdentity, error) {
+	idRec, err := repos.NewLinkedIdentityRecord(&models.LinkedIdentity{UserId: userId})
+	if err != nil || idRec.UserId.IsZero() {
+		return nil, ErrInvalidUserId
+	}
+	idRecs, err := oc.identityRepo.Handler.FindMany(idRec)
+	if err != nil {
+		return nil, err
+	}
+	return repos.LoadLinkedIdentityRecords(idRecs), nil
+}
+
+// Unlink removes an external identity linked to a user
+func (oc *OIDCService) Unlink(userId string, id string) error {
+	idRec, err := repos.NewLinkedIdentityRecord(&models.LinkedIdentity{Id: id, UserId: userId})
+	if err != nil || idRec.Id.IsZero() || idRec.UserId.IsZero() {
+		return ErrLinkedIdentityNotFound
+	}
+	if _, err = oc.identityRepo.Handler.FindOne(idRec); errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrLinkedIdentityNotFound
+	} else if err != nil {
+		return err
+	}
+	_, err = oc.identityRepo.Handler.DeleteOne(idRec)
+	return err
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/mailers"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrOrganizationNotFound is returned when no organization matches the requested id
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrInvalidOrganization is returned when an organization is created or updated without a name
+	ErrInvalidOrganization = errors.New("organizations require a name")
+	// ErrInvalidOrgRole is returned when a membership is granted a role that is not MEMBER, ADMIN or ROOT
+	ErrInvalidOrgRole = errors.New("invalid organization role")
+	// ErrNotOrgMember is returned when a user is not a member of the requested organization
+	ErrNotOrgMember = errors.New("not a member of the organization")
+	// ErrOrgMemberExists is returned when an invitation is accepted by a user who already is a member
+	ErrOrgMemberExists = errors.New("already a member of the organization")
+	// ErrLastOrgOwner is returned when the last ROOT member of an organization would be removed or demoted
+	ErrLastOrgOwner = errors.New("an organization requires at least one ROOT member")
+	// ErrInvalidInvitation is returned when an organization invitation token is unknown, expired or already used
+	ErrInvalidInvitation = errors.New("invalid or expired organization invitation")
+	// ErrInvitationMismatch is returned when an invitation is accepted by a user with another email than the invitee
+	ErrInvitationMismatch = errors.New("organization invitation was sent to another email address")
+)
+
+// orgInvitationExpiry returns the configured lifetime of an organization invitation
+func orgInvitationExpiry() time.Duration {
+	if expiry := viper.GetDuration("org_invitation_expiry"); expiry > 0 {
+		return expiry
+	}
+	return 7 * 24 * time.Hour
+}
+
+// validOrgRole returns whether a role can be granted by a membership
+func validOrgRole(role enums.Role) bool {
+	return role >= enums.MEMBER && role <= enums.ROOT
+}
+
+// OrganizationService is used by the app to manage organizations along with their memberships and invitations,
+// membership requests are scoped to the organization of the caller carried by their context
+type OrganizationService struct {
+	orgRepo     *repos.OrganizationRepo
+	memberRepo  *repos.MembershipRepo
+	userService *UserService
+	tokenRepo   *repos.OneTimeTokenRepo
+	mailer      mailers.Mailer
+}
+
+// NewOrganizationService is an exported function used to initialize a new OrganizationService struct
+func NewOrganizationService(oHandler *repos.OrganizationRepo, mHandler *repos.MembershipRepo, userService *UserService, tHandler *repos.OneTimeTokenRepo, mailer mailers.Mailer) *OrganizationService {
+	return &OrganizationService{oHandler, mHandler, userService, tHandler, mailer}
+}
+
+// members returns the memberships DBRepo scoped to the organization of the caller
+func (ogs *OrganizationService) members(ctx context.Context) *databases.DBRepo[*repos.MembershipRecord] {
+	return ogs.memberRepo.Handler.WithContext(ctx)
+}
+
+// Create creates a new organization with its creator as its first ROOT member
+func (ogs *OrganizationService) Create(userId string, org *models.Organization) (*models.Organization, error) {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return org, ErrInvalidOrganization
+	}
+	org.Id = ""
+	org.CreatedBy = userId
+	orgRec, err := repos.NewOrganizationRecord(org)
+	if err != nil || orgRec.CreatedBy.IsZero() {
+		return org, ErrInvalidUserId
+	}
+	if orgRec, err = ogs.orgRepo.Handler.InsertOne(orgRec); err != nil {
+		return org, err
+	}
+	ctx := databases.ContextWithOrganization(context.Background(), orgRec.Id)
+	_, err = ogs.members(ctx).InsertOne(&repos.MembershipRecord{UserId: orgRec.CreatedBy, Role: enums.ROOT})
+	if err != nil {
+		return org, err
+	}
+	created := orgRec.ToRoot()
+	created.Role = enums.ROOT
+	return created, nil
+}
+
+// FindById returns an organization by id
+func (ogs *OrganizationService) FindById(id string) (*models.Organization, error) {
+	orgRec, err := repos.NewOrganizationRecord(&models.Organization{Id: id})
+	if err != nil || orgRec.Id.IsZero() {
+		return nil, ErrOrganizationNotFound
+	}
+	orgRec, err = ogs.orgRepo.Handler.FindOne(orgRec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return orgRec.ToRoot(), nil
+}
+
+// FindByUser returns the organizations a user is a member of along with its role within each of them
+func (ogs *OrganizationService) FindByUser(userId string) ([]*models.Organization, error) {
+	uId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, ErrInvalidUserId
+	}
+	// listing the organizations of a user spans organizations, so it is the one lookup not scoped to the caller
+	mRecs, err := ogs.memberRepo.Handler.FindMany(&repos.MembershipRecord{UserId: uId})
+	if err != nil {
+		return nil, err
+	}
+	orgs := make([]*models.Organization, 0, len(mRecs))
+	for _, mRec := range mRecs {
+		org, err := ogs.FindById(mRec.OrganizationId.Hex())
+		if errors.Is(err, ErrOrganizationNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		org.Role = mRec.Role
+		orgs = append(orgs, org)
+	}
+	return orgs, nil
+}
+
+// Update renames the organization of the caller
+func (ogs *OrganizationService) Update(ctx context.Context, org *models.Organization) (*models.Organization, error) {
+	orgId, ok := databases.OrganizationFromContext(ctx)
+	if !ok || orgId.Hex() != org.Id {
+		return org, ErrNotOrgMember
+	}
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return org, ErrInvalidOrganization
+	}
+	if _, err := ogs.orgRepo.Handler.UpdateOne(&repos.OrganizationRecord{Id: orgId}, &repos.OrganizationRecord{Name: org.Name}); err != nil {
+		return org, err
+	}
+	return ogs.FindById(org.Id)
+}
+
+// membershipOf returns the membership of a user in an organization, ROOT users are granted ROOT in the
+// organizations they are not a member of so that they can support every tenant
+func (ogs *OrganizationService) membershipOf(orgId string, user *models.User) (*models.Membership, error) {
+	org, err := ogs.FindById(orgId)
+	if err != nil {
+		return nil, err
+	}
+	oId, _ := primitive.ObjectIDFromHex(org.Id)
+	membership, err := ogs.Membership(databases.ContextWithOrganization(context.Background(), oId), user.Id)
+	if errors.Is(err, ErrNotOrgMember) && user.Role == enums.ROOT {
+		return &models.Membership{OrganizationId: org.Id, UserId: user.Id, Role: enums.ROOT}, nil
+	}
+	return membership, err
+}
+
+// Membership returns the membership of a user in the organization of the caller
+func (ogs *OrganizationService) Membership(ctx context.Context, userId string) (*models.Membership, error) {
+	uId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, ErrInvalidUserId
+	}
+	mRec, err := ogs.members(ctx).FindOne(&repos.MembershipRecord{UserId: uId})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotOrgMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mRec.ToRoot(), nil
+}
+
+// Members returns the memberships of the organization of the caller
+func (ogs *OrganizationService) Members(ctx context.Context) ([]*models.Membership, error) {
+	mRecs, err := ogs.members(ctx).FindMany(&repos.MembershipRecord{})
+	if err != nil {
+		return nil, err
+	}
+	return repos.LoadMembershipRecords(mRecs), nil
+}
+
+// checkLastOwner returns ErrLastOrgOwner when a membership is the only ROOT membership of the organization of the caller
+func (ogs *OrganizationService) checkLastOwner(ctx context.Context, membership *models.Membership) error {
+	if membership.Role != enums.ROOT {
+		return nil
+	}
+	owners, err := ogs.members(ctx).Count(&repos.MembershipRecord{Role: enums.ROOT})
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOrgOwner
+	}
+	return nil
+}
+
+// UpdateMember changes the role of a member of the organization of the caller
+func (ogs *OrganizationService) UpdateMember(ctx context.Context, userId string, role enums.Role) (*models.Membership, error) {
+	if !validOrgRole(role) {
+		return nil, ErrInvalidOrgRole
+	}
+	membership, err := ogs.Membership(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if role != enums.ROOT {
+		if err = ogs.checkLastOwner(ctx, membership); err != nil {
+			return nil, err
+		}
+	}
+	mRec, err := repos.NewMembershipRecord(&models.Membership{Id: membership.Id})
+	if err != nil {
+		return nil, err
+	}
+	if _, err = ogs.members(ctx).UpdateOne(mRec, &repos.MembershipRecord{Role: role}); err != nil {
+		return nil, err
+	}
+	return ogs.Membership(ctx, userId)
+}
+
+// RemoveMember removes a member from the organization of the caller, tokens already scoped to the organization
+// keep their claims until they expire
+func (ogs *OrganizationService) RemoveMember(ctx context.Context, userId string) error {
+	membership, err := ogs.Membership(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if err = ogs.checkLastOwner(ctx, membership); err != nil {
+		return err
+	}
+	mRec, err := repos.NewMembershipRecord(&models.Membership{Id: membership.Id})
+	if err != nil {
+		return err
+	}
+	_, err = ogs.members(ctx).DeleteOne(mRec)
+	return err
+}
+
+// Invite emails an invitation to join the organization of the caller with the input role, it can be accepted by
+// the user registered with the invited email until org_invitation_expiry
+func (ogs *OrganizationService) Invite(ctx context.Context, inviterId string, invitation *models.OrgInvitation) error {
+	orgId, ok := databases.OrganizationFromContext(ctx)
+	if !ok {
+		return ErrNotOrgMember
+	}
+	email := strings.ToLower(strings.TrimSpace(invitation.Email))
+	if !utilities.IsValidEmail(email) {
+		return ErrInvalidEmail
+	}
+	if invitation.Role.EnumIndex() == 0 {
+		invitation.Role = enums.MEMBER
+	}
+	if !validOrgRole(invitation.Role) {
+		return ErrInvalidOrgRole
+	}
+	org, err := ogs.FindById(orgId.Hex())
+	if err != nil {
+		return err
+	}
+	uId, err := primitive.ObjectIDFromHex(inviterId)
+	if err != nil {
+		return ErrInvalidUserId
+	}
+	token, err := utilities.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	_, err = ogs.tokenRepo.Handler.InsertOne(&repos.OneTimeTokenRecord{
+		UserId:    uId,
+		Purpose:   models.OrgInvitationToken,
+		TokenHash: utilities.HashToken(token),
+		Data:      map[string]string{"organization_id": org.Id, "email": email, "role": invitation.Role.Stringify()},
+		ExpiresAt: time.Now().UTC().Add(orgInvitationExpiry()),
+	})
+	if err != nil {
+		return err
+	}
+	return ogs.mailer.Send(ctx, &mailers.Message{
+		To:      email,
+		Subject: fmt.Sprintf("You are invited to join %s on eventit", org.Name),
+		Body: fmt.Sprintf("Follow the link below to join %s, the invitation expires in %s.\n\n%s",
+			org.Name, orgInvitationExpiry(), tokenLink("org_invitation_url", token)),
+	})
+}
+
+// Accept consumes an organization invitation on behalf of the user it was sent to, making it a member of the
+// organization. The invitation is only consumed once its email is known to match the verified email of the user
+func (ogs *OrganizationService) Accept(userId string, token string) (*models.Membership, error) {
+	if token == "" {
+		return nil, ErrEmptyToken
+	}
+	user, err := ogs.userService.FindById(userId)
+	if err != nil {
+		return nil, err
+	}
+	tokenHash := utilities.HashToken(token)
+	tokenRec, err := ogs.tokenRepo.Handler.FindOne(&repos.OneTimeTokenRecord{TokenHash: tokenHash})
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && tokenRec.Purpose != models.OrgInvitationToken) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(tokenRec.Data["email"], user.Email) {
+		return nil, ErrInvitationMismatch
+	}
+	if !user.EmailVerified() {
+		// whoever registered the address without proving they own it must not claim the invitation
+		return nil, ErrEmailNotVerified
+	}
+	if tokenRec, err = ogs.tokenRepo.Consume(tokenHash, models.OrgInvitationToken); errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidInvitation
+	} else if err != nil {
+		return nil, err
+	}
+	org, err := ogs.FindById(tokenRec.Data["organization_id"])
+	if err != nil {
+		return nil, err
+	}
+	orgId, _ := primitive.ObjectIDFromHex(org.Id)
+	ctx := databases.ContextWithOrganization(context.Background(), orgId)
+	if _, err = ogs.Membership(ctx, user.Id); err == nil {
+		return nil, ErrOrgMemberExists
+	} else if !errors.Is(err, ErrNotOrgMember) {
+		return nil, err
+	}
+	uId, _ := primitive.ObjectIDFromHex(user.Id)
+	mRec, err := ogs.members(ctx).InsertOne(&repos.MembershipRecord{UserId: uId, Role: enums.RoleFromString(tokenRec.Data["role"])})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrOrgMemberExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mRec.ToRoot(), nil
+}
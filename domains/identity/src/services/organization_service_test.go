@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestOrganizationService_Accept(t *testing.T) {
+	db := newTestDB(t)
+	us, mailer := newTestUserService(db)
+	ogs := NewOrganizationService(repos.NewOrganizationRepo(db), repos.NewMembershipRepo(db), us, us.tokenRepo, mailer)
+	owner := createTestUser(t, us, "ann@example.com", enums.MEMBER)
+	invitee := createTestUser(t, us, "bob@example.com", enums.MEMBER)
+	other := createTestUser(t, us, "cid@example.com", enums.MEMBER)
+	org, err := ogs.Create(owner.Id, &models.Organization{Name: "Acme"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	orgId, _ := primitive.ObjectIDFromHex(org.Id)
+	ctx := databases.ContextWithOrganization(context.Background(), orgId)
+	if err = ogs.Invite(ctx, owner.Id, &models.OrgInvitation{Email: invitee.Email, Role: enums.ADMIN}); err != nil {
+		t.Fatalf("Invite() error = %v", err)
+	}
+	token := mailer.token(invitee.Email)
+	// the invitation is kept until its email is verified, it is not burned by whoever registered the address
+	if _, err = ogs.Accept(invitee.Id, token); !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("Accept() by an unverified user error = %v, want %v", err, ErrEmailNotVerified)
+	}
+	if _, err = ogs.Accept(other.Id, token); !errors.Is(err, ErrInvitationMismatch) {
+		t.Errorf("Accept() by another user error = %v, want %v", err, ErrInvitationMismatch)
+	}
+	if _, err = us.MarkEmailVerified(invitee.Id); err != nil {
+		t.Fatalf("MarkEmailVerified() error = %v", err)
+	}
+	membership, err := ogs.Accept(invitee.Id, token)
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	if membership.UserId != invitee.Id || membership.Role != enums.ADMIN {
+		t.Errorf("Accept() = %+v, want an ADMIN membership of user %s", membership, invitee.Id)
+	}
+	if _, err = ogs.Accept(invitee.Id, token); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("second Accept() error = %v, want %v", err, ErrInvalidInvitation)
+	}
+}
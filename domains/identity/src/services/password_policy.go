@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrWeakPassword is returned when a password does not meet the password policy
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+const (
+	// maxPasswordLength bounds the cost of hashing a password
+	maxPasswordLength = 128
+	// breachedPrefixLength is the number of hex characters of a SHA-1 hash the breached list is bucketed by
+	breachedPrefixLength = 5
+	// minIdentityLength is the shortest email local part or username a password is checked against
+	minIdentityLength = 3
+)
+
+// PasswordPolicyError lists every rule of the password policy a password failed
+type PasswordPolicyError struct {
+	Errors []utilities.FieldError
+}
+
+// Error returns the messages of the failed rules
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Message
+	}
+	return ErrWeakPassword.Error() + ": " + strings.Join(messages, ", ")
+}
+
+// Unwrap returns ErrWeakPassword
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// PasswordPolicy validates new passwords against length and character class rules, the identity of their user and
+// an offline list of breached passwords
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int
+	// breached buckets the suffixes of breached SHA-1 password hashes by their prefix, mirroring the k-anonymity
+	// range lookups of online breach services
+	breached map[string]map[string]struct{}
+}
+
+// LoadPasswordPolicy initializes the PasswordPolicy configured by auth_password_min_length and
+// auth_password_min_classes, loading the breached password list from auth_password_breached_list when set
+func LoadPasswordPolicy() (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:  viper.GetInt("auth_password_min_length"),
+		MinClasses: viper.GetInt("auth_password_min_classes"),
+	}
+	if path := viper.GetString("auth_password_breached_list"); path != "" {
+		if err := policy.LoadBreachedList(path); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// LoadBreachedList loads a file of SHA-1 password hashes, one hex encoded hash per line optionally followed by
+// ":<count>" as in the downloadable Pwned Passwords lists
+func (p *PasswordPolicy) LoadBreachedList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	breached := make(map[string]map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		if len(hash) != sha1.Size*2 {
+			return fmt.Errorf("%s:%d: invalid SHA-1 hash %q", path, line, hash)
+		}
+		hash = strings.ToUpper(hash)
+		prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+		if breached[prefix] == nil {
+			breached[prefix] = make(map[string]struct{})
+		}
+		breached[prefix][suffix] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	p.breached = breached
+	return nil
+}
+
+// Breached returns whether a password is in the loaded breached password list
+func (p *PasswordPolicy) Breached(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, ok := p.breached[hash[:breachedPrefixLength]][hash[breachedPrefixLength:]]
+	return ok
+}
+
+// characterClasses counts the classes among lowercase, uppercase, digit and symbol characters a password uses
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// containsIdentity returns whether a password contains the email local part or the username of its user
+func containsIdentity(password string, email string, username string) bool {
+	password = strings.ToLower(password)
+	localPart, _, _ := strings.Cut(email, "@")
+	for _, identity := range []string{localPart, username} {
+		identity = strings.ToLower(strings.TrimSpace(identity))
+		if len(identity) >= minIdentityLength && strings.Contains(password, identity) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a new password of the user with the input email and username, returning a PasswordPolicyError
+// listing every failed rule
+func (p *PasswordPolicy) Validate(password string, email string, username string) error {
+	var fieldErrs []utilities.FieldError
+	fail := func(code string, message string) {
+		fieldErrs = append(fieldErrs, utilities.FieldError{Field: "password", Code: code, Message: message})
+	}
+	length := len([]rune(password))
+	if length < p.MinLength {
+		fail("too_short", fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if length > maxPasswordLength {
+		fail("too_long", fmt.Sprintf("must be at most %d characters long", maxPasswordLength))
+	}
+	if characterClasses(password) < p.MinClasses {
+		fail("too_simple", fmt.Sprintf("must use at least %d of lowercase, uppercase, digit and symbol characters", p.MinClasses))
+	}
+	if containsIdentity(password, email, username) {
+		fail("contains_identity", "must not contain your email or username")
+	}
+	if p.Breached(password) {
+		fail("breached", "has appeared in a data breach, please choose another password")
+	}
+	if len(fieldErrs) > 0 {
+		return &PasswordPolicyError{fieldErrs}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	breached := sha1.Sum([]byte("Tr0ub4dor&3x"))
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	list := hex.EncodeToString(breached[:]) + ":42\n"
+	if err := os.WriteFile(path, []byte(list), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy := &PasswordPolicy{MinLength: 10, MinClasses: 3}
+	if err := policy.LoadBreachedList(path); err != nil {
+		t.Fatal(err)
+	}
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name     string // The name of the test
+		password string // The password being validated
+		want     string // The code of the failed rule, empty when the password is valid
+	}{
+		{"valid", "correct-Horse-battery", ""},
+		{"too short", "Sh0rt!", "too_short"},
+		{"too simple", "alllowercaseletters", "too_simple"},
+		{"contains email", "Attendee-2024-pass", "contains_identity"},
+		{"contains username", "my-Eventer-99", "contains_identity"},
+		{"breached", "Tr0ub4dor&3x", "breached"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "attendee@example.com", "eventer")
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) || !errors.Is(err, ErrWeakPassword) {
+				t.Fatalf("Validate() error = %v, want a PasswordPolicyError", err)
+			}
+			for _, fieldErr := range policyErr.Errors {
+				if fieldErr.Code == tt.want {
+					return
+				}
+			}
+			t.Errorf("Validate() errors = %+v, want code %v", policyErr.Errors, tt.want)
+		})
+	}
+}
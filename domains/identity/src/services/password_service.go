@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/mailers"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidResetToken is returned when a password reset token is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordService is used by the app to manage the password reset flow
+type PasswordService struct {
+	userService *UserService
+	authService *AuthService
+	tokenRepo   *repos.OneTimeTokenRepo
+	mailer      mailers.Mailer
+}
+
+// NewPasswordService is an exported function used to initialize a new PasswordService struct
+func NewPasswordService(userService *UserService, authService *AuthService, tHandler *repos.OneTimeTokenRepo, mailer mailers.Mailer) *PasswordService {
+	return &PasswordService{
+		userService,
+		authService,
+		tHandler,
+		mailer,
+	}
+}
+
+// ForgotPassword emails a single use password reset token to the user with the input email, unknown emails
+// are silently ignored so that the endpoint cannot be used to discover accounts
+func (ps *PasswordService) ForgotPassword(ctx context.Context, email string) error {
+	if email == "" {
+		return ErrEmptyEmail
+	}
+	user, err := ps.userService.FindByEmail(email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := issueOneTimeToken(ps.tokenRepo, user.Id, models.PasswordResetToken, loginTokenExpiry())
+	if err != nil {
+		return err
+	}
+	return ps.mailer.Send(ctx, &mailers.Message{
+		To:      user.Email,
+		Subject: "Reset your eventit password",
+		Body: fmt.Sprintf("Use the code %s or follow the link below to reset your password, it expires in %s.\n\n%s",
+			token, loginTokenExpiry(), tokenLink("auth_password_reset_url", token)),
+	})
+}
+
+// ResetPassword consumes a password reset token, sets the user's new password and revokes its existing sessions
+func (ps *PasswordService) ResetPassword(reset *models.PasswordReset) error {
+	if reset.Token == "" {
+		return ErrEmptyToken
+	}
+	if reset.Password == "" {
+		return ErrEmptyPassword
+	}
+	tokenHash := utilities.HashToken(reset.Token)
+	// the new password is checked before the token is consumed so that a rejected password does not burn it
+	tokenRec, err := ps.tokenRepo.Handler.FindOne(&repos.OneTimeTokenRecord{TokenHash: tokenHash})
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && tokenRec.Purpose != models.PasswordResetToken) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	user, err := ps.userService.FindById(tokenRec.UserId.Hex())
+	if err != nil {
+		return err
+	}
+	if err = ps.userService.policy.Validate(reset.Password, user.Email, user.Username); err != nil {
+		return err
+	}
+	tokenRec, err = ps.tokenRepo.Consume(tokenHash, models.PasswordResetToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	userId := tokenRec.UserId.Hex()
+	if _, err = ps.userService.Update(&models.User{Id: userId, Password: reset.Password}); err != nil {
+		return err
+	}
+	if err = ps.tokenRepo.Invalidate(tokenRec.UserId, models.PasswordResetToken); err != nil {
+		return err
+	}
+	return ps.authService.RevokeSessions(userId, "")
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestPasswordService_ResetPassword(t *testing.T) {
+	db := newTestDB(t)
+	us, mailer := newTestUserService(db)
+	ss := NewSessionService(repos.NewSessionRepo(db), repos.NewRefreshTokenRepo(db))
+	ps := NewPasswordService(us, &AuthService{sessions: ss}, us.tokenRepo, mailer)
+	user := createTestUser(t, us, "ann@example.com", enums.MEMBER)
+	userId, _ := primitive.ObjectIDFromHex(user.Id)
+	session := startTestSession(t, ss, userId)
+	if err := ps.ForgotPassword(context.Background(), user.Email); err != nil {
+		t.Fatalf("ForgotPassword() error = %v", err)
+	}
+	token := mailer.token(user.Email)
+	if token == "" {
+		t.Fatalf("ForgotPassword() did not email a reset token")
+	}
+	// a password rejected by the policy must not burn the token
+	err := ps.ResetPassword(&models.PasswordReset{Token: token, Password: "short"})
+	if err == nil || errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("ResetPassword() with a weak password error = %v, want a policy error", err)
+	}
+	const newPassword = "new-Battery-staple-9"
+	if err = ps.ResetPassword(&models.PasswordReset{Token: token, Password: newPassword}); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if err = ps.ResetPassword(&models.PasswordReset{Token: token, Password: "other-Battery-staple-9"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("second ResetPassword() error = %v, want %v", err, ErrInvalidResetToken)
+	}
+	updated, err := us.FindById(user.Id)
+	if err != nil {
+		t.Fatalf("FindById() error = %v", err)
+	}
+	if _, err = updated.VerifyPassword(newPassword); err != nil {
+		t.Errorf("VerifyPassword() of the new password error = %v", err)
+	}
+	// the sessions started with the old password are revoked
+	if err = ss.CheckSession(context.Background(), "", session); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() of an existing session error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if err = ps.ForgotPassword(context.Background(), "bob@example.com"); err != nil {
+		t.Errorf("ForgotPassword() of an unknown email error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrSessionRevoked is returned when a token belongs to a session that was revoked or expired
+	ErrSessionRevoked = errors.New("session has been revoked")
+	// ErrSessionNotFound is returned when no active session of the user matches the requested id
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+const (
+	// sessionCacheTTL bounds how long a session known to be active is cached, which is also the longest a
+	// revocation performed on another instance can go unnoticed by this one
+	sessionCacheTTL = 10 * time.Second
+	// sessionTouchInterval is the minimum time between two updates of the last seen time of a session
+	sessionTouchInterval = time.Minute
+)
+
+// SessionService is used by the app to manage the persisted login sessions of users
+type SessionService struct {
+	sessionRepo *repos.SessionRepo
+	refresh     *repos.RefreshTokenRepo
+	revoked     *utilities.TTLCache[bool]
+}
+
+// NewSessionService is an exported function used to initialize a new SessionService struct
+func NewSessionService(sHandler *repos.SessionRepo, rtHandler *repos.RefreshTokenRepo) *SessionService {
+	return &SessionService{
+		sHandler,
+		rtHandler,
+		utilities.NewTTLCache[bool](blacklistCacheSize),
+	}
+}
+
+// deviceFromUserAgent returns a coarse description of the kind of device a user agent belongs to
+func deviceFromUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "bot") || strings.Contains(ua, "crawler") || strings.Contains(ua, "spider"):
+		return "bot"
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet"):
+		return "tablet"
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		return "mobile"
+	}
+	return "desktop"
+}
+
+// newSessionRecord returns a new SessionRecord of a user started from the input client
+func newSessionRecord(id primitive.ObjectID, userId primitive.ObjectID, client *models.ClientInfo, twoFactor bool) *repos.SessionRecord {
+	if client == nil {
+		client = &models.ClientInfo{}
+	}
+	return &repos.SessionRecord{
+		Id:        id,
+		UserId:    userId,
+		Device:    deviceFromUserAgent(client.UserAgent),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		TwoFactor: twoFactor,
+		ExpiresAt: time.Now().UTC().Add(auth.RefreshExpiry()),
+	}
+}
+
+// start persists a new session for a login of a user, its id is also the family id of its refresh tokens
+func (ss *SessionService) start(userId primitive.ObjectID, client *models.ClientInfo, twoFactor bool) (primitive.ObjectID, error) {
+	sRec, err := ss.sessionRepo.Handler.InsertOne(newSessionRecord(primitive.NilObjectID, userId, client, twoFactor))
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return sRec.Id, nil
+}
+
+// startImpersonation persists a new session of a user impersonated by an actor, it lasts auth_impersonation_expiry
+// and cannot be extended since no refresh token is issued for it
+func (ss *SessionService) startImpersonation(userId primitive.ObjectID, actorId primitive.ObjectID, client *models.ClientInfo) (primitive.ObjectID, error) {
+	sRec := newSessionRecord(primitive.NilObjectID, userId, client, false)
+	sRec.ActorId = actorId
+	sRec.ExpiresAt = time.Now().UTC().Add(auth.ImpersonationExpiry())
+	sRec, err := ss.sessionRepo.Handler.InsertOne(sRec)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return sRec.Id, nil
+}
+
+// resume extends the session of a refresh token family, returning the organization its tokens are scoped to.
+// Sessions of families started before sessions were persisted are created on their first refresh
+func (ss *SessionService) resume(id primitive.ObjectID, userId primitive.ObjectID, client *models.ClientInfo, twoFactor bool) (primitive.ObjectID, error) {
+	sRec, err := ss.sessionRepo.Handler.FindOne(&repos.SessionRecord{Id: id})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		_, err = ss.sessionRepo.Handler.InsertOne(newSessionRecord(id, userId, client, twoFactor))
+		return primitive.NilObjectID, err
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if !sRec.ToRoot().Active() {
+		return primitive.NilObjectID, ErrSessionRevoked
+	}
+	return sRec.OrganizationId, ss.sessionRepo.Touch(id, client, time.Now().UTC().Add(auth.RefreshExpiry()))
+}
+
+// scope records the organization the access tokens of a session are scoped to, so that refreshes keep it
+func (ss *SessionService) scope(sessionId string, orgId string) error {
+	id, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+	oId := primitive.NilObjectID
+	if orgId != "" {
+		if oId, err = primitive.ObjectIDFromHex(orgId); err != nil {
+			return ErrOrganizationNotFound
+		}
+	}
+	return ss.sessionRepo.SetOrganization(id, oId)
+}
+
+// CheckSession is an auth.TokenCheck rejecting tokens whose session was revoked or expired, lookups are cached
+// in-process for sessionCacheTTL and revocations made by this instance take effect on the next request. Tokens
+// without a session id were issued before sessions were persisted and lapse once they reach auth_jwt_expiry
+func (ss *SessionService) CheckSession(ctx context.Context, tokenString string, claims *auth.AppClaims) error {
+	if claims.ID == "" {
+		return nil
+	}
+	if revoked, ok := ss.revoked.Get(claims.ID); ok {
+		if revoked {
+			return ErrSessionRevoked
+		}
+		return nil
+	}
+	id, err := primitive.ObjectIDFromHex(claims.ID)
+	if err != nil {
+		return ErrSessionRevoked
+	}
+	sRec, err := ss.sessionRepo.Handler.FindOne(&repos.SessionRecord{Id: id})
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	if err != nil || sRec.UserId.Hex() != claims.ProfileId || !sRec.ToRoot().Active() {
+		if claims.ExpiresAt != nil {
+			ss.revoked.Set(claims.ID, true, time.Until(claims.ExpiresAt.Time))
+		}
+		return ErrSessionRevoked
+	}
+	ss.revoked.Set(claims.ID, false, sessionCacheTTL)
+	if time.Since(sRec.LastSeenAt) > sessionTouchInterval {
+		if err = ss.sessionRepo.Touch(id, nil, time.Time{}); err != nil {
+			log.Printf("unable to update the last seen time of session %s: %v", claims.ID, err)
+		}
+	}
+	return nil
+}
+
+// FindByUser returns the active sessions of a user, most recently seen first, flagging the session with the
+// input current id
+func (ss *SessionService) FindByUser(userId string, currentId string) ([]*models.UserSession, error) {
+	uId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, ErrInvalidUserId
+	}
+	sRecs, err := ss.sessionRepo.Handler.FindMany(&repos.SessionRecord{UserId: uId})
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*models.UserSession, 0, len(sRecs))
+	for _, session := range repos.LoadSessionRecords(sRecs) {
+		if session.Active() {
+			session.Current = session.Id == currentId
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// revoke marks sessions matching the filter as revoked along with their refresh tokens
+func (ss *SessionService) revoke(filter *repos.SessionRecord) error {
+	sRecs, err := ss.sessionRepo.Handler.FindMany(filter)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, sRec := range sRecs {
+		if !sRec.RevokedAt.IsZero() {
+			continue
+		}
+		if _, err = ss.sessionRepo.Handler.UpdateOne(&repos.SessionRecord{Id: sRec.Id}, &repos.SessionRecord{RevokedAt: now}); err != nil {
+			return err
+		}
+		if _, err = ss.refresh.Handler.UpdateMany(&repos.RefreshTokenRecord{FamilyId: sRec.Id}, &repos.RefreshTokenRecord{RevokedAt: now}); err != nil {
+			return err
+		}
+		ss.revoked.Set(sRec.Id.Hex(), true, time.Until(now.Add(auth.TokenExpiry())))
+	}
+	return nil
+}
+
+// Revoke revokes a session of a user, its access tokens are rejected from the next request on
+func (ss *SessionService) Revoke(userId string, sessionId string) error {
+	sRec, err := repos.NewSessionRecord(&models.UserSession{Id: sessionId, UserId: userId})
+	if err != nil || sRec.Id.IsZero() || sRec.UserId.IsZero() {
+		return ErrSessionNotFound
+	}
+	found, err := ss.sessionRepo.Handler.FindOne(sRec)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !found.ToRoot().Active()) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ss.revoke(sRec)
+}
+
+// RevokeOthers revokes every session of a user but the session with the input keep id, along with their refresh
+// tokens
+func (ss *SessionService) RevokeOthers(userId string, keepId string) error {
+	uId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return ErrInvalidUserId
+	}
+	keep, err := primitive.ObjectIDFromHex(keepId)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+	sRecs, err := ss.sessionRepo.Handler.FindMany(&repos.SessionRecord{UserId: uId})
+	if err != nil {
+		return err
+	}
+	for _, sRec := range sRecs {
+		if sRec.Id == keep || !sRec.RevokedAt.IsZero() {
+			continue
+		}
+		if err = ss.revoke(&repos.SessionRecord{Id: sRec.Id}); err != nil {
+			return err
+		}
+	}
+	// refresh token families started before sessions were persisted have no session record
+	rtRecs, err := ss.refresh.Handler.FindMany(&repos.RefreshTokenRecord{UserId: uId})
+	if err != nil {
+		return err
+	}
+	revoked := make(map[primitive.ObjectID]bool)
+	for _, rtRec := range rtRecs {
+		if rtRec.FamilyId == keep || !rtRec.RevokedAt.IsZero() || revoked[rtRec.FamilyId] {
+			continue
+		}
+		revoked[rtRec.FamilyId] = true
+		if _, err = ss.refresh.Handler.UpdateMany(&repos.RefreshTokenRecord{FamilyId: rtRec.FamilyId}, &repos.RefreshTokenRecord{RevokedAt: time.Now().UTC()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeAll revokes every session of a user along with all of its refresh tokens
+func (ss *SessionService) RevokeAll(userId string) error {
+	uId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return ErrInvalidUserId
+	}
+	if err = ss.revoke(&repos.SessionRecord{UserId: uId}); err != nil {
+		return err
+	}
+	// refresh token families started before sessions were persisted have no session record
+	_, err = ss.refresh.Handler.UpdateMany(&repos.RefreshTokenRecord{UserId: uId}, &repos.RefreshTokenRecord{RevokedAt: time.Now().UTC()})
+	return err
+}
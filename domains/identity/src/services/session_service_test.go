@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+// newTestSessionService returns a SessionService on an empty in-memory DBClient
+func newTestSessionService(t *testing.T) *SessionService {
+	t.Helper()
+	db := newTestDB(t)
+	return NewSessionService(repos.NewSessionRepo(db), repos.NewRefreshTokenRepo(db))
+}
+
+// startTestSession starts a session of a user, returning the claims of its access tokens
+func startTestSession(t *testing.T, ss *SessionService, userId primitive.ObjectID) *auth.AppClaims {
+	t.Helper()
+	id, err := ss.start(userId, testClient, false)
+	if err != nil {
+		t.Fatalf("start() error = %v", err)
+	}
+	return &auth.AppClaims{ProfileId: userId.Hex(), RegisteredClaims: jwt.RegisteredClaims{ID: id.Hex()}}
+}
+
+func TestSessionService_RevokeOthers(t *testing.T) {
+	ss := newTestSessionService(t)
+	userId, otherId := primitive.NewObjectID(), primitive.NewObjectID()
+	current, revoked := startTestSession(t, ss, userId), startTestSession(t, ss, userId)
+	other := startTestSession(t, ss, otherId)
+	if err := ss.RevokeOthers(userId.Hex(), current.ID); err != nil {
+		t.Fatalf("RevokeOthers() error = %v", err)
+	}
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name    string          // The name of the test
+		claims  *auth.AppClaims // The claims of the checked token
+		wantErr error           // The error we want CheckSession to return
+	}{
+		{"kept session", current, nil},
+		{"other session of the user", revoked, ErrSessionRevoked},
+		{"session of another user", other, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ss.CheckSession(context.Background(), "", tt.claims); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckSession() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionService_Revoke(t *testing.T) {
+	ss := newTestSessionService(t)
+	userId := primitive.NewObjectID()
+	current, revoked := startTestSession(t, ss, userId), startTestSession(t, ss, userId)
+	// the session is cached as active, the revocation must still take effect on the next request
+	if err := ss.CheckSession(context.Background(), "", revoked); err != nil {
+		t.Fatalf("CheckSession() error = %v", err)
+	}
+	if err := ss.Revoke(primitive.NewObjectID().Hex(), revoked.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Revoke() of another user's session error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if err := ss.Revoke(userId.Hex(), revoked.ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if err := ss.CheckSession(context.Background(), "", revoked); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("CheckSession() of the revoked session error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if err := ss.CheckSession(context.Background(), "", current); err != nil {
+		t.Errorf("CheckSession() of the other session error = %v, want nil", err)
+	}
+	sessions, err := ss.FindByUser(userId.Hex(), current.ID)
+	if err != nil {
+		t.Fatalf("FindByUser() error = %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Id != current.ID {
+		t.Errorf("FindByUser() = %d sessions, want only session %s", len(sessions), current.ID)
+	}
+	if err = ss.Revoke(userId.Hex(), revoked.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second Revoke() error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/spf13/viper"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrLoginThrottled is returned when logins are attempted again before the backoff of previous failures elapsed
+	ErrLoginThrottled = errors.New("too many failed login attempts, please try again later")
+	// ErrAccountLocked is returned when logging into an account locked after too many failed attempts
+	ErrAccountLocked = errors.New("account is temporarily locked after too many failed login attempts")
+)
+
+// RetryError wraps ErrLoginThrottled and ErrAccountLocked along with how long the client should wait
+type RetryError struct {
+	Err   error
+	After time.Duration
+}
+
+// Error returns the message of the wrapped error
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter returns how long the client should wait before retrying, rounded up to the second
+func (e *RetryError) RetryAfter() time.Duration {
+	return (e.After + time.Second - 1).Truncate(time.Second)
+}
+
+// loginMaxFailures returns the configured number of failed logins for an email before it is locked
+func loginMaxFailures() int {
+	if n := viper.GetInt("auth_login_max_failures"); n > 0 {
+		return n
+	}
+	return 5
+}
+
+// loginIPMaxFailures returns the configured number of failed logins from a client ip before it is throttled
+func loginIPMaxFailures() int {
+	if n := viper.GetInt("auth_login_ip_max_failures"); n > 0 {
+		return n
+	}
+	return 20
+}
+
+// loginLockoutDuration returns the configured duration of a lockout, which is also how long failures are tracked
+func loginLockoutDuration() time.Duration {
+	if d := viper.GetDuration("auth_login_lockout_duration"); d > 0 {
+		return d
+	}
+	return 15 * time.Minute
+}
+
+// loginBackoffBase returns the configured delay imposed after the first failed login, doubling on each failure
+func loginBackoffBase() time.Duration {
+	if d := viper.GetDuration("auth_login_backoff_base"); d > 0 {
+		return d
+	}
+	return time.Second
+}
+
+// loginThrottle applies exponential backoff and temporary lockouts to failed logins per email and client ip
+type loginThrottle struct {
+	attempts *repos.LoginAttemptRepo
+}
+
+// emailKey returns the attempt key of an email
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipKey returns the attempt key of a client ip
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// backoff returns how long logins are delayed after the input number of consecutive failures
+func backoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	delay := float64(loginBackoffBase()) * math.Pow(2, float64(failures-1))
+	if max := float64(loginLockoutDuration()); delay > max {
+		return loginLockoutDuration()
+	}
+	return time.Duration(delay)
+}
+
+// wait returns a RetryError when logins under a key are locked or still backing off
+func (lt *loginThrottle) wait(key string, lockedErr error) error {
+	rec, err := lt.attempts.Find(key)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if now.Before(rec.LockedUntil) {
+		return &RetryError{Err: lockedErr, After: rec.LockedUntil.Sub(now)}
+	}
+	if next := rec.LastFailureAt.Add(backoff(rec.Failures)); now.Before(next) {
+		return &RetryError{Err: ErrLoginThrottled, After: next.Sub(now)}
+	}
+	return nil
+}
+
+// check returns a RetryError when a login for the input email from the input client ip must not be attempted yet
+func (lt *loginThrottle) check(email string, ip string) error {
+	if ip != "" {
+		if err := lt.wait(ipKey(ip), ErrLoginThrottled); err != nil {
+			return err
+		}
+	}
+	return lt.wait(emailKey(email), ErrAccountLocked)
+}
+
+// fail records a failed login, returning the time until which the email is locked out when it just reached the
+// failure threshold
+func (lt *loginThrottle) fail(email string, ip string) (time.Time, error) {
+	var lockedUntil time.Time
+	if ip != "" {
+		rec, err := lt.attempts.RecordFailure(ipKey(ip), loginLockoutDuration())
+		if err != nil {
+			return lockedUntil, err
+		}
+		if rec.Failures >= loginIPMaxFailures() {
+			if err = lt.attempts.Lock(rec.Key, time.Now().UTC().Add(loginLockoutDuration())); err != nil {
+				return lockedUntil, err
+			}
+		}
+	}
+	rec, err := lt.attempts.RecordFailure(emailKey(email), loginLockoutDuration())
+	if err != nil {
+		return lockedUntil, err
+	}
+	if rec.Failures >= loginMaxFailures() {
+		lockedUntil = time.Now().UTC().Add(loginLockoutDuration())
+		if err = lt.attempts.Lock(rec.Key, lockedUntil); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return lockedUntil, nil
+}
+
+// reset forgets the failed logins of an email
+func (lt *loginThrottle) reset(email string) error {
+	return lt.attempts.Clear(emailKey(email))
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/spf13/viper"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name     string        // The name of the test
+		failures int           // The number of consecutive failures
+		want     time.Duration // The backoff we want
+	}{
+		{"no failures", 0, 0},
+		{"first failure", 1, time.Second},
+		{"doubles", 4, 8 * time.Second},
+		{"capped at the lockout duration", 20, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoff(tt.failures); got != tt.want {
+				t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthService_LoginLockout(t *testing.T) {
+	viper.Set("auth_login_max_failures", 3)
+	t.Cleanup(func() { viper.Set("auth_login_max_failures", 0) })
+	db := newTestDB(t)
+	us, _ := newTestUserService(db)
+	as := &AuthService{userService: us, throttle: &loginThrottle{repos.NewLoginAttemptRepo(db)}}
+	user := createTestUser(t, us, "ann@example.com", enums.MEMBER)
+	for i := 1; i < 3; i++ {
+		if err := as.loginFailed(user.Email, "", user); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("loginFailed() %d error = %v, want %v", i, err, ErrInvalidCredentials)
+		}
+	}
+	var retry *RetryError
+	if err := as.loginFailed(user.Email, "", user); !errors.As(err, &retry) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("loginFailed() at the threshold error = %v, want %v", err, ErrAccountLocked)
+	}
+	if retry.RetryAfter() != 15*time.Minute {
+		t.Errorf("RetryAfter() = %v, want %v", retry.RetryAfter(), 15*time.Minute)
+	}
+	// the lockout applies to every client, not only the one that failed
+	if err := as.throttle.check(user.Email, "198.51.100.1"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("check() of a locked email error = %v, want %v", err, ErrAccountLocked)
+	}
+	locked, err := us.FindById(user.Id)
+	if err != nil {
+		t.Fatalf("FindById() error = %v", err)
+	}
+	if !locked.Locked() {
+		t.Errorf("loginFailed() did not lock the user")
+	}
+	unlocked, err := as.Unlock(user.Id)
+	if err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if unlocked.Locked() {
+		t.Errorf("Unlock() kept the user locked until %v", unlocked.LockedUntil)
+	}
+	if err = as.throttle.check(user.Email, ""); err != nil {
+		t.Errorf("check() after Unlock() error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"time"
+)
+
+// loginTokenLength returns the configured number of characters of an emailed token
+func loginTokenLength() int {
+	if n := viper.GetInt("auth_login_token_length"); n >= 8 {
+		return n
+	}
+	return 8
+}
+
+// loginTokenExpiry returns the configured lifetime of an emailed token
+func loginTokenExpiry() time.Duration {
+	if expiry := viper.GetDuration("auth_login_token_expiry"); expiry > 0 {
+		return expiry
+	}
+	return 11 * time.Minute
+}
+
+// issueOneTimeToken stores the hash of a new single use token for a user and purpose, returning the raw token
+func issueOneTimeToken(tokenRepo *repos.OneTimeTokenRepo, userId string, purpose models.TokenPurpose, expiry time.Duration) (string, error) {
+	uId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return "", ErrInvalidUserId
+	}
+	token, err := utilities.GenerateRandomString(loginTokenLength())
+	if err != nil {
+		return "", err
+	}
+	_, err = tokenRepo.Handler.InsertOne(&repos.OneTimeTokenRecord{
+		UserId:    uId,
+		Purpose:   purpose,
+		TokenHash: utilities.HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(expiry),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// tokenLink appends a token query param to a configured url
+func tokenLink(setting string, token string) string {
+	link, err := url.Parse(viper.GetString(setting))
+	if err != nil {
+		return token
+	}
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+	return link.String()
+}
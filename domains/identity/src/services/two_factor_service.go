@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTwoFactorNotEnrolled is returned when a two factor operation requires an enrollment the user does not have
+	ErrTwoFactorNotEnrolled = errors.New("two factor authentication is not enrolled")
+	// ErrTwoFactorAlreadyEnabled is returned when enrolling a user that already has two factor authentication enabled
+	ErrTwoFactorAlreadyEnabled = errors.New("two factor authentication is already enabled")
+	// ErrInvalidTwoFactorCode is returned when a TOTP or recovery code is invalid or was already used
+	ErrInvalidTwoFactorCode = errors.New("invalid two factor code")
+	// ErrInvalidChallenge is returned when a two factor login challenge is unknown, expired or already used
+	ErrInvalidChallenge = errors.New("invalid or expired two factor challenge")
+)
+
+// recoveryCodeCount is the number of recovery codes issued when two factor authentication is enabled
+const recoveryCodeCount = 10
+
+// TwoFactorService is used by the app to manage TOTP two factor authentication
+type TwoFactorService struct {
+	userService *UserService
+	tfRepo      *repos.TwoFactorRepo
+}
+
+// NewTwoFactorService is an exported function used to initialize a new TwoFactorService struct
+func NewTwoFactorService(userService *UserService, tfHandler *repos.TwoFactorRepo) *TwoFactorService {
+	return &TwoFactorService{userService, tfHandler}
+}
+
+// twoFactorIssuer returns the configured issuer displayed by authenticator apps
+func twoFactorIssuer() string {
+	if issuer := viper.GetString("auth_two_factor_issuer"); issuer != "" {
+		return issuer
+	}
+	return "eventit"
+}
+
+// normalizeRecoveryCode strips the formatting users may add when typing a recovery code
+func normalizeRecoveryCode(code string) string {
+	return strings.ReplaceAll(strings.TrimSpace(code), "-", "")
+}
+
+// generateRecoveryCodes returns a new set of raw recovery codes along with their hashes
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utilities.GenerateRandomString(10)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, utilities.HashToken(code))
+	}
+	return
+}
+
+// find returns the two factor enrollment of a user
+func (ts *TwoFactorService) find(userId string) (*repos.TwoFactorRecord, error) {
+	tfRec, err := repos.NewTwoFactorRecord(&models.TwoFactor{UserId: userId})
+	if err != nil || tfRec.UserId.IsZero() {
+		return nil, ErrInvalidUserId
+	}
+	tfRec, err = ts.tfRepo.Handler.FindOne(tfRec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	return tfRec, err
+}
+
+// Enabled returns whether a user has confirmed its two factor enrollment
+func (ts *TwoFactorService) Enabled(userId string) (bool, error) {
+	tfRec, err := ts.find(userId)
+	if errors.Is(err, ErrTwoFactorNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tfRec.ToRoot().Enabled(), nil
+}
+
+// Enroll generates a new TOTP secret for a user, replacing any unconfirmed enrollment, the enrollment only
+// takes effect once confirmed with a valid code
+func (ts *TwoFactorService) Enroll(userId string) (*models.TwoFactorEnrollment, error) {
+	user, err := ts.userService.FindById(userId)
+	if err != nil {
+		return nil, err
+	}
+	tfRec, err := ts.find(userId)
+	if err == nil {
+		if tfRec.ToRoot().Enabled() {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		if _, err = ts.tfRepo.Handler.DeleteOne(&repos.TwoFactorRecord{Id: tfRec.Id}); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, ErrTwoFactorNotEnrolled) {
+		return nil, err
+	}
+	secret, err := utilities.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	tfRec, err = repos.NewTwoFactorRecord(&models.TwoFactor{UserId: userId, Secret: secret})
+	if err != nil {
+		return nil, ErrInvalidUserId
+	}
+	if _, err = ts.tfRepo.Handler.InsertOne(tfRec); err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    utilities.TOTPURI(twoFactorIssuer(), user.Email, secret),
+	}, nil
+}
+
+// Confirm enables the pending two factor enrollment of a user with a valid TOTP code, returning its recovery codes
+func (ts *TwoFactorService) Confirm(userId string, code string) (*models.TwoFactorEnrollment, error) {
+	tfRec, err := ts.find(userId)
+	if err != nil {
+		return nil, err
+	}
+	if tfRec.ToRoot().Enabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if err = ts.verifyTOTP(tfRec, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	tfRec, err = ts.tfRepo.Handler.UpdateOne(&repos.TwoFactorRecord{Id: tfRec.Id}, &repos.TwoFactorRecord{
+		RecoveryCodes: hashes,
+		EnabledAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorEnrollment{RecoveryCodes: codes, EnabledAt: tfRec.EnabledAt}, nil
+}
+
+// verifyTOTP checks a TOTP code and records its time step as used
+func (ts *TwoFactorService) verifyTOTP(tfRec *repos.TwoFactorRecord, code string) error {
+	step, ok := utilities.ValidateTOTP(tfRec.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	used, err := ts.tfRepo.UseStep(tfRec.Id, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Verify checks a TOTP or recovery code against the enabled two factor enrollment of a user, each code can
+// only be used once
+func (ts *TwoFactorService) Verify(userId string, code string) error {
+	tfRec, err := ts.find(userId)
+	if err != nil {
+		return err
+	}
+	if !tfRec.ToRoot().Enabled() {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err = ts.verifyTOTP(tfRec, code); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		return err
+	}
+	used, err := ts.tfRepo.UseRecoveryCode(tfRec.Id, utilities.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user after verifying one of its codes
+func (ts *TwoFactorService) RegenerateRecoveryCodes(userId string, code string) (*models.TwoFactorEnrollment, error) {
+	if err := ts.Verify(userId, code); err != nil {
+		return nil, err
+	}
+	tfRec, err := ts.find(userId)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = ts.tfRepo.Handler.UpdateOne(&repos.TwoFactorRecord{Id: tfRec.Id}, &repos.TwoFactorRecord{RecoveryCodes: hashes}); err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorEnrollment{RecoveryCodes: codes, EnabledAt: tfRec.EnabledAt}, nil
+}
+
+// Disable removes the two factor enrollment of a user after verifying one of its codes
+func (ts *TwoFactorService) Disable(userId string, code string) error {
+	if err := ts.Verify(userId, code); err != nil {
+		return err
+	}
+	tfRec, err := ts.find(userId)
+	if err != nil {
+		return err
+	}
+	_, err = ts.tfRepo.Handler.DeleteOne(&repos.TwoFactorRecord{Id: tfRec.Id})
+	return err
+}
@@ -3,65 +3,383 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/JECSand/eventit-server/domains/identity/src/models"
 	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/mailers"
 	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/url"
+	"time"
+)
+
+var (
+	// ErrUserNotFound is returned when no user matches the requested filter
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidEmail is returned when an input email address cannot be parsed
+	ErrInvalidEmail = errors.New("invalid email")
+	// ErrInvalidUserId is returned when an input user id is not a valid ObjectID
+	ErrInvalidUserId = errors.New("invalid user id")
+	// ErrEmailTaken is returned when creating or updating a user with an email already in use
+	ErrEmailTaken = errors.New("email is already in use")
+	// ErrInvalidVerificationToken is returned when an email verification token is unknown, expired or already used
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	// ErrVerificationThrottled is returned when a verification email is requested again too soon
+	ErrVerificationThrottled = errors.New("a verification email was sent recently, please try again later")
 )
 
 // UserService is used by the app to manage all user related controllers and functionality
 type UserService struct {
-	userRepo *repos.UserRepo
+	userRepo  *repos.UserRepo
+	tokenRepo *repos.OneTimeTokenRepo
+	mailer    mailers.Mailer
+	policy    *PasswordPolicy
 }
 
 // NewUserService is an exported function used to initialize a new UserService struct
-func NewUserService(uHandler *repos.UserRepo) *UserService {
-	return &UserService{uHandler}
+func NewUserService(uHandler *repos.UserRepo, tHandler *repos.OneTimeTokenRepo, mailer mailers.Mailer, policy *PasswordPolicy) *UserService {
+	return &UserService{uHandler, tHandler, mailer, policy}
 }
 
-func (us *UserService) Create(user *models.User) (*models.User, error) {
+// verifyEmailExpiry returns the configured lifetime of an email verification token
+func verifyEmailExpiry() time.Duration {
+	if expiry := viper.GetDuration("auth_verify_email_expiry"); expiry > 0 {
+		return expiry
+	}
+	return 24 * time.Hour
+}
+
+// verifyEmailResendInterval returns the configured minimum time between two verification emails
+func verifyEmailResendInterval() time.Duration {
+	if interval := viper.GetDuration("auth_verify_email_resend_interval"); interval > 0 {
+		return interval
+	}
+	return time.Minute
+}
+
+// checkEmail verifies that an email is valid and not in use by a user other than the input id
+func (us *UserService) checkEmail(email string, id string) error {
+	if !utilities.IsValidEmail(email) {
+		return ErrInvalidEmail
+	}
+	found, err := us.FindByEmail(email)
+	if err == nil && found.Id != id {
+		return ErrEmailTaken
+	}
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+	return nil
+}
+
+// FindOrRegister returns the user with the input email, signing up a new passwordless member when none exists
+// and auth_login_link_signup allows it
+func (us *UserService) FindOrRegister(email string) (*models.User, error) {
+	if !utilities.IsValidEmail(email) {
+		return nil, ErrInvalidEmail
+	}
+	user, err := us.FindByEmail(email)
+	if !errors.Is(err, ErrUserNotFound) || !viper.GetBool("auth_login_link_signup") {
+		return user, err
+	}
+	return us.registerPasswordless(&models.User{Email: email})
+}
+
+// registerPasswordless signs up a new member without a password, whose identity is proven by a magic link or
+// an external identity provider
+func (us *UserService) registerPasswordless(user *models.User) (*models.User, error) {
+	user.Id = ""
+	user.Password = ""
+	user.Role = enums.MEMBER
 	userRec, err := repos.NewUserRecord(user)
 	if err != nil {
+		return nil, err
+	}
+	userRec, err = us.userRepo.Handler.InsertOne(userRec)
+	if err != nil {
+		return nil, err
+	}
+	return userRec.ToRoot(), nil
+}
+
+func (us *UserService) Create(user *models.User) (*models.User, error) {
+	if err := us.checkEmail(user.Email, ""); err != nil {
+		return user, err
+	}
+	if user.Role.EnumIndex() == 0 {
+		user.Role = enums.MEMBER
+	}
+	// the verification, lockout and deletion timestamps are only ever set by their dedicated flows
+	user.EmailVerifiedAt = time.Time{}
+	user.LockedUntil = time.Time{}
+	user.UnlockedAt = time.Time{}
+	user.DeletedAt = time.Time{}
+	if user.Password == "" {
+		return user, ErrEmptyPassword
+	}
+	if err := us.policy.Validate(user.Password, user.Email, user.Username); err != nil {
+		return user, err
+	}
+	if err := user.HashPassword(); err != nil {
 		return user, err
 	}
+	userRec, err := repos.NewUserRecord(user)
+	if err != nil {
+		return user, ErrInvalidUserId
+	}
 	userRec, err = us.userRepo.Handler.InsertOne(userRec)
 	if err != nil {
 		return user, err
 	}
-	return userRec.ToRoot(), nil
+	created := userRec.ToRoot()
+	if !created.EmailVerified() {
+		if err = us.SendVerification(context.Background(), created); err != nil {
+			log.Printf("unable to send verification email to user %s: %v", created.Id, err)
+		}
+	}
+	return created, nil
 }
 
 func (us *UserService) Update(user *models.User) (*models.User, error) {
+	// the verification, lockout and deletion timestamps are only ever set by their dedicated flows
+	user.EmailVerifiedAt = time.Time{}
+	user.LockedUntil = time.Time{}
+	user.UnlockedAt = time.Time{}
+	user.DeletedAt = time.Time{}
+	emailChanged := false
+	if user.Email != "" || user.Password != "" {
+		current, err := us.FindById(user.Id)
+		if err != nil {
+			return user, err
+		}
+		if user.Email != "" {
+			if err = us.checkEmail(user.Email, user.Id); err != nil {
+				return user, err
+			}
+			emailChanged = current.Email != user.Email
+			current.Email = user.Email
+		}
+		if user.Username != "" {
+			current.Username = user.Username
+		}
+		if user.Password != "" {
+			if err = us.policy.Validate(user.Password, current.Email, current.Username); err != nil {
+				return user, err
+			}
+		}
+	}
+	if user.Password != "" {
+		if err := user.HashPassword(); err != nil {
+			return user, err
+		}
+	}
 	userRec, err := repos.NewUserRecord(user)
 	if err != nil {
-		return user, err
+		return user, ErrInvalidUserId
 	}
 	userRec, err = us.userRepo.Handler.UpdateOne(&repos.UserRecord{Id: userRec.Id}, userRec)
 	if err != nil {
 		return user, err
 	}
-	return user, nil
+	if emailChanged {
+		if err = us.userRepo.ClearEmailVerification(userRec.Id); err != nil {
+			return user, err
+		}
+		// codes sent to the previous email must not verify the new one
+		if err = us.tokenRepo.Invalidate(userRec.Id, models.EmailVerificationToken); err != nil {
+			return user, err
+		}
+	}
+	updated, err := us.FindById(user.Id)
+	if err != nil {
+		return updated, err
+	}
+	if emailChanged {
+		if err = us.SendVerification(context.Background(), updated); err != nil {
+			log.Printf("unable to send verification email to user %s: %v", updated.Id, err)
+		}
+	}
+	return updated, nil
 }
 
-func (us *UserService) DeleteById(id string) error {
-	// TODO ADD LOGIC HERE
-	userRec, err := repos.NewUserRecord(&models.User{Id: id})
+// rehashPassword replaces the password hash of a user with a hash from the configured auth.PasswordHasher, the
+// password was already verified so it is not checked against the password policy again
+func (us *UserService) rehashPassword(id string, password string) error {
+	user := &models.User{Id: id, Password: password}
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+	userRec, err := repos.NewUserRecord(user)
+	if err != nil {
+		return ErrInvalidUserId
+	}
+	_, err = us.userRepo.Handler.UpdateOne(&repos.UserRecord{Id: userRec.Id}, &repos.UserRecord{Password: userRec.Password})
+	return err
+}
+
+// SendVerification emails a new email verification token to a user
+func (us *UserService) SendVerification(ctx context.Context, user *models.User) error {
+	token, err := issueOneTimeToken(us.tokenRepo, user.Id, models.EmailVerificationToken, verifyEmailExpiry())
+	if err != nil {
+		return err
+	}
+	return us.mailer.Send(ctx, &mailers.Message{
+		To:      user.Email,
+		Subject: "Verify your eventit email address",
+		Body: fmt.Sprintf("Use the code %s or follow the link below to verify your email address, it expires in %s.\n\n%s",
+			token, verifyEmailExpiry(), tokenLink("auth_verify_email_url", token)),
+	})
+}
+
+// ResendVerification emails a new verification token to the unverified user with the input email, at most once
+// per auth_verify_email_resend_interval. Unknown and already verified emails are silently ignored alike so that
+// the endpoint cannot be used to discover accounts
+func (us *UserService) ResendVerification(ctx context.Context, email string) error {
+	if email == "" {
+		return ErrEmptyEmail
+	}
+	user, err := us.FindByEmail(email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+	tokenRec, err := repos.NewOneTimeTokenRecord(&models.OneTimeToken{UserId: user.Id, Purpose: models.EmailVerificationToken})
+	if err != nil {
+		return ErrInvalidUserId
+	}
+	sent, err := us.tokenRepo.Handler.FindMany(tokenRec)
 	if err != nil {
 		return err
 	}
+	for _, t := range sent {
+		if time.Since(t.CreatedAt) < verifyEmailResendInterval() {
+			return ErrVerificationThrottled
+		}
+	}
+	return us.SendVerification(ctx, user)
+}
+
+// VerifyEmail consumes an email verification token and marks the email of its user as verified
+func (us *UserService) VerifyEmail(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrEmptyToken
+	}
+	tokenRec, err := us.tokenRepo.Consume(utilities.HashToken(token), models.EmailVerificationToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return us.MarkEmailVerified(tokenRec.UserId.Hex())
+}
+
+// MarkEmailVerified sets the email verification timestamp of a user that has proven it owns its email
+func (us *UserService) MarkEmailVerified(id string) (*models.User, error) {
+	user, err := us.FindById(id)
+	if err != nil {
+		return user, err
+	}
+	if user.EmailVerified() {
+		return user, nil
+	}
+	userRec, err := repos.NewUserRecord(&models.User{Id: id, EmailVerifiedAt: time.Now().UTC()})
+	if err != nil {
+		return user, ErrInvalidUserId
+	}
+	if _, err = us.userRepo.Handler.UpdateOne(&repos.UserRecord{Id: userRec.Id}, userRec); err != nil {
+		return user, err
+	}
+	return us.FindById(id)
+}
+
+func (us *UserService) DeleteById(id string) error {
+	userRec, err := repos.NewUserRecord(&models.User{Id: id})
+	if err != nil || userRec.Id.IsZero() {
+		return ErrInvalidUserId
+	}
 	_, err = us.userRepo.Handler.DeleteOne(userRec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrUserNotFound
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// userDeletedRetention returns the configured time a soft deleted user is kept before it is purged
+func userDeletedRetention() time.Duration {
+	if retention := viper.GetDuration("user_deleted_retention"); retention > 0 {
+		return retention
+	}
+	return 30 * 24 * time.Hour
+}
+
+// FindDeletedById returns a soft deleted user by id
+func (us *UserService) FindDeletedById(id string) (*models.User, error) {
+	userRec, err := repos.NewUserRecord(&models.User{Id: id})
+	if err != nil || userRec.Id.IsZero() {
+		return nil, ErrInvalidUserId
+	}
+	userRec, err = us.userRepo.Handler.WithDeleted().FindOne(userRec)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && userRec.DeletedAt.IsZero()) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return userRec.ToRoot(), nil
+}
+
+// Restore undoes the deletion of a user that was not purged yet, unless its email was taken in the meantime
+func (us *UserService) Restore(id string) (*models.User, error) {
+	deleted, err := us.FindDeletedById(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = us.checkEmail(deleted.Email, deleted.Id); err != nil {
+		return nil, err
+	}
+	userRec, err := repos.NewUserRecord(&models.User{Id: deleted.Id})
+	if err != nil {
+		return nil, ErrInvalidUserId
+	}
+	userRec, err = us.userRepo.Handler.Restore(userRec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return userRec.ToRoot(), nil
+}
+
+// PurgeDeleted permanently removes the users deleted longer than user_deleted_retention ago
+func (us *UserService) PurgeDeleted() (int64, error) {
+	return us.userRepo.Handler.Purge(time.Now().UTC().Add(-userDeletedRetention()))
+}
+
 func (us *UserService) findOne(filter *models.User) (user *models.User, err error) {
 	var userRec *repos.UserRecord
 	userRec, err = repos.NewUserRecord(filter)
 	if err != nil {
+		err = ErrInvalidUserId
 		return
 	}
 	userRec, err = us.userRepo.Handler.FindOne(userRec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = ErrUserNotFound
+	}
 	if err == nil {
 		user = userRec.ToRoot()
 	}
@@ -69,27 +387,38 @@ func (us *UserService) findOne(filter *models.User) (user *models.User, err erro
 }
 
 func (us *UserService) FindById(id string) (user *models.User, err error) {
-	user.Id = id
-	user, err = us.findOne(user)
-	return
+	if !utilities.CheckObjectID(id) {
+		err = ErrInvalidUserId
+		return
+	}
+	return us.findOne(&models.User{Id: id})
 }
 
 func (us *UserService) FindByEmail(email string) (user *models.User, err error) {
 	if ok := utilities.IsValidEmail(email); ok {
-		user.Email = email
-		user, err = us.findOne(user)
-		return
+		return us.findOne(&models.User{Email: email})
 	}
-	err = errors.New("invalid email")
+	err = ErrInvalidEmail
 	return
 }
 
-func (us *UserService) Find(ctx context.Context, user *models.User, pagination *utilities.Pagination) (*models.UsersPage, error) {
+// ParseQuery builds the Query of the filter params of a list of users from a url query
+func (us *UserService) ParseQuery(values url.Values) (*databases.Query, error) {
+	return repos.UserQuerySchema.Parse(values)
+}
+
+// Find returns a page of the users matching the filter and query, soft deleted users are only included when
+// includeDeleted is set. Pages are selected by cursor when the pagination uses cursors and by number otherwise
+func (us *UserService) Find(ctx context.Context, user *models.User, query *databases.Query, pagination *utilities.Pagination, includeDeleted bool) (*models.UsersPage, error) {
 	userRec, err := repos.NewUserRecord(user)
 	if err != nil {
-		return &models.UsersPage{}, err
+		return &models.UsersPage{}, ErrInvalidUserId
 	}
-	count, err := us.userRepo.Handler.Count(userRec)
+	handler := us.userRepo.Handler
+	if includeDeleted {
+		handler = handler.WithDeleted()
+	}
+	count, err := handler.Count(userRec, query)
 	if err != nil {
 		return &models.UsersPage{}, err
 	}
@@ -103,7 +432,21 @@ func (us *UserService) Find(ctx context.Context, user *models.User, pagination *
 			Users:      make([]*models.User, 0),
 		}, nil
 	}
-	userRecs, err := us.userRepo.Handler.PaginatedFind(ctx, userRec, pagination)
+	if pagination.UsesCursor() {
+		userRecs, cursors, err := handler.CursorFind(ctx, userRec, pagination, query)
+		if err != nil {
+			return &models.UsersPage{}, err
+		}
+		return &models.UsersPage{
+			TotalCount: count,
+			Size:       int64(pagination.GetSize()),
+			HasMore:    cursors.Next != "",
+			NextCursor: cursors.Next,
+			PrevCursor: cursors.Prev,
+			Users:      repos.LoadUserRecords(userRecs),
+		}, nil
+	}
+	userRecs, err := handler.PaginatedFind(ctx, userRec, pagination, query)
 	if err != nil {
 		return &models.UsersPage{}, err
 	}
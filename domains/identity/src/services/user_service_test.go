@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/identity/src/models"
+	repos "github.com/JECSand/eventit-server/domains/identity/src/repositories"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/mailers"
+	"github.com/spf13/viper"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPassword = "correct-Horse-battery"
+
+var testClient = &models.ClientInfo{IP: "203.0.113.7", UserAgent: "Mozilla/5.0 (X11; Linux x86_64)"}
+
+// testMailer is a Mailer recording the messages it sends
+type testMailer struct {
+	mu   sync.Mutex
+	sent []*mailers.Message
+}
+
+// Send records a Message
+func (m *testMailer) Send(ctx context.Context, msg *mailers.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// token returns the token linked by the last message sent to an email, or an empty string when there is none
+func (m *testMailer) token(email string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To != email {
+			continue
+		}
+		fields := strings.Fields(m.sent[i].Body)
+		if link, err := url.Parse(fields[len(fields)-1]); err == nil {
+			return link.Query().Get("token")
+		}
+	}
+	return ""
+}
+
+// newTestDB returns an empty in-memory DBClient
+func newTestDB(t *testing.T) databases.DBClient {
+	t.Helper()
+	t.Setenv("ENV", "test")
+	db, err := databases.InitializeNewClient()
+	if err != nil {
+		t.Fatalf("InitializeNewClient() error = %v", err)
+	}
+	return db
+}
+
+// newTestUserService returns a UserService on the input DBClient along with the mailer it sends its emails with
+func newTestUserService(db databases.DBClient) (*UserService, *testMailer) {
+	mailer := &testMailer{}
+	policy := &PasswordPolicy{MinLength: 10, MinClasses: 3}
+	return NewUserService(repos.NewUserRepo(db), repos.NewOneTimeTokenRepo(db), mailer, policy), mailer
+}
+
+// createTestUser creates a user of the input role with testPassword
+func createTestUser(t *testing.T, us *UserService, email string, role enums.Role) *models.User {
+	t.Helper()
+	user, err := us.Create(&models.User{Email: email, Password: testPassword, Role: role})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return user
+}
+
+func TestUserService_Update(t *testing.T) {
+	us, _ := newTestUserService(newTestDB(t))
+	user := createTestUser(t, us, "ann@example.com", enums.MEMBER)
+	createTestUser(t, us, "bob@example.com", enums.MEMBER)
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name    string       // The name of the test
+		input   *models.User // The fields being updated
+		wantErr bool         // whether we want an error
+	}{
+		{"username only", &models.User{Username: "ann"}, false},
+		{"new email", &models.User{Email: "ann.b@example.com"}, false},
+		{"email in use", &models.User{Email: "bob@example.com"}, true},
+		{"weak password", &models.User{Password: "short"}, true},
+		{"password", &models.User{Password: "new-Battery-staple-9"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Id = user.Id
+			password := tt.input.Password
+			updated, err := us.Update(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.input.Username != "" && updated.Username != tt.input.Username {
+				t.Errorf("Update() username = %q, want %q", updated.Username, tt.input.Username)
+			}
+			if tt.input.Email != "" && updated.Email != tt.input.Email {
+				t.Errorf("Update() email = %q, want %q", updated.Email, tt.input.Email)
+			}
+			if password != "" {
+				if _, err = updated.VerifyPassword(password); err != nil {
+					t.Errorf("VerifyPassword() of the new password error = %v", err)
+				}
+			}
+		})
+	}
+	if _, err := us.Update(&models.User{Id: user.Id, Email: "bob@example.com"}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Update() to an email in use error = %v, want %v", err, ErrEmailTaken)
+	}
+}
+
+func TestUserService_VerifyEmail(t *testing.T) {
+	us, mailer := newTestUserService(newTestDB(t))
+	user := createTestUser(t, us, "ann@example.com", enums.MEMBER)
+	if user.EmailVerified() {
+		t.Fatalf("Create() returned a verified email")
+	}
+	// the code sent on signup stops working once the email changes
+	stale := mailer.token(user.Email)
+	if _, err := us.Update(&models.User{Id: user.Id, Email: "bob@example.com"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := us.VerifyEmail(stale); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail() with a code sent to the previous email error = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+	token := mailer.token("bob@example.com")
+	verified, err := us.VerifyEmail(token)
+	if err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	if !verified.EmailVerified() || verified.Email != "bob@example.com" {
+		t.Errorf("VerifyEmail() = %+v, want bob@example.com verified", verified)
+	}
+	if _, err = us.VerifyEmail(token); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("second VerifyEmail() error = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+	// verified emails are ignored like unknown ones
+	sent := len(mailer.sent)
+	if err = us.ResendVerification(context.Background(), "bob@example.com"); err != nil {
+		t.Errorf("ResendVerification() of a verified email error = %v, want nil", err)
+	}
+	if len(mailer.sent) != sent {
+		t.Errorf("ResendVerification() emailed a verified address")
+	}
+	// a verification set by the client is never trusted
+	created, err := us.Create(&models.User{Email: "cid@example.com", Password: testPassword, EmailVerifiedAt: user.CreatedAt})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.EmailVerified() {
+		t.Errorf("Create() kept a client supplied email verification")
+	}
+}
+
+func TestUserService_DeleteById(t *testing.T) {
+	us, _ := newTestUserService(newTestDB(t))
+	user := createTestUser(t, us, "ann@example.com", enums.MEMBER)
+	taken := createTestUser(t, us, "bob@example.com", enums.MEMBER)
+	if err := us.DeleteById(""); !errors.Is(err, ErrInvalidUserId) {
+		t.Errorf("DeleteById() without an id error = %v, want %v", err, ErrInvalidUserId)
+	}
+	if _, err := us.FindDeletedById(user.Id); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("FindDeletedById() of an active user error = %v, want %v", err, ErrUserNotFound)
+	}
+	for _, id := range []string{user.Id, taken.Id} {
+		if err := us.DeleteById(id); err != nil {
+			t.Fatalf("DeleteById() error = %v", err)
+		}
+	}
+	if _, err := us.FindById(user.Id); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("FindById() of a deleted user error = %v, want %v", err, ErrUserNotFound)
+	}
+	if deleted, err := us.FindDeletedById(user.Id); err != nil || deleted.Email != user.Email {
+		t.Errorf("FindDeletedById() = %+v, %v, want user %s", deleted, err, user.Id)
+	}
+	restored, err := us.Restore(user.Id)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if _, err = us.FindById(restored.Id); err != nil {
+		t.Errorf("FindById() of a restored user error = %v", err)
+	}
+	// the email of a deleted user can be signed up again, which blocks its restoration
+	createTestUser(t, us, taken.Email, enums.MEMBER)
+	if _, err = us.Restore(taken.Id); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Restore() of a taken email error = %v, want %v", err, ErrEmailTaken)
+	}
+	if n, err := us.PurgeDeleted(); err != nil || n != 0 {
+		t.Errorf("PurgeDeleted() within the retention = %d, %v, want 0", n, err)
+	}
+	viper.Set("user_deleted_retention", time.Nanosecond)
+	t.Cleanup(func() { viper.Set("user_deleted_retention", 0) })
+	time.Sleep(time.Millisecond)
+	if n, err := us.PurgeDeleted(); err != nil || n != 1 {
+		t.Errorf("PurgeDeleted() past the retention = %d, %v, want 1", n, err)
+	}
+	if _, err = us.FindDeletedById(taken.Id); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("FindDeletedById() of a purged user error = %v, want %v", err, ErrUserNotFound)
+	}
+}
@@ -2,8 +2,10 @@ package auth
 
 import (
 	"context"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
 	"github.com/JECSand/eventit-server/domains/shared/enums"
 	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
 )
 
@@ -15,31 +17,89 @@ const (
 
 // ClaimsFromCtx retrieves the parsed AppClaims from request context.
 func ClaimsFromCtx(ctx context.Context) AppClaims {
-	return ctx.Value(ctxClaims).(AppClaims)
+	if claims, ok := ctx.Value(ctxClaims).(*AppClaims); ok {
+		return *claims
+	}
+	return AppClaims{}
+}
+
+// authenticateRequest resolves the AppClaims of a request from its Auth-Token header, or from its API-Key header
+// for INTEGRATION sessions
+func authenticateRequest(r *http.Request) (*AppClaims, error) {
+	if tokenString := r.Header.Get("Auth-Token"); tokenString != "" || r.Header.Get("API-Key") == "" {
+		return VerifyToken(r.Context(), tokenString)
+	}
+	return ResolveAPIKey(r.Context(), r.Header.Get("API-Key"))
 }
 
 // Authenticator inputs the route handler function along with User roleType to verify User token and permissions
 func Authenticator(roleType enums.Role, next http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
 	var errorObject routers.JWTError
-	decodedToken, err := DecodeJWT(r.Header.Get("Auth-Token"))
+	decodedToken, err := authenticateRequest(r)
 	if err != nil {
 		errorObject.Message = err.Error()
 		routers.RespondWithError(w, http.StatusUnauthorized, errorObject)
 		return
 	}
+	if !decodedToken.TwoFactor && !decodedToken.IsIntegration() && TwoFactorRequired(decodedToken.Role) {
+		// until two factor authentication is completed the requester is limited to member routes, so that
+		// it is still able to enroll
+		if roleType != enums.MEMBER {
+			errorObject.Message = "Two factor authentication required"
+			routers.RespondWithError(w, http.StatusForbidden, errorObject)
+			return
+		}
+		limited := *decodedToken
+		limited.Role = enums.MEMBER
+		decodedToken = &limited
+	}
 	ctx := context.WithValue(r.Context(), ctxClaims, decodedToken)
+	if orgId, err := primitive.ObjectIDFromHex(decodedToken.OrgId); err == nil {
+		// org scoped repositories limit the queries of the request to the organization of the caller
+		ctx = databases.ContextWithOrganization(ctx, orgId)
+	}
 	if roleType == enums.ROOT && decodedToken.Role == enums.ROOT {
 		next.ServeHTTP(w, r.WithContext(ctx))
+		return
 	} else if roleType == enums.ADMIN && decodedToken.Role == enums.ADMIN || decodedToken.Role == enums.ROOT {
 		next.ServeHTTP(w, r.WithContext(ctx))
+		return
 	} else if roleType == enums.MEMBER {
 		next.ServeHTTP(w, r.WithContext(ctx))
+		return
 	}
-	errorObject.Message = "Invalid Token"
-	routers.RespondWithError(w, http.StatusUnauthorized, errorObject)
+	errorObject.Message = "Insufficient Permissions"
+	routers.RespondWithError(w, http.StatusForbidden, errorObject)
 	return
 }
 
+// VerifyEmailMiddleWare is used to verify that an authenticated requester has verified its email whenever the
+// auth_email_verification_policy requires it, it must be wrapped by one of the role middlewares
+func VerifyEmailMiddleWare(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := ClaimsFromCtx(r.Context())
+		if !claims.EmailVerified && !claims.IsIntegration() && EmailVerificationPolicy() != VerifyEmailNever {
+			routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Email address is not verified"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// DenyImpersonation rejects requests authenticated with an impersonation token, it wraps the routes of sensitive
+// actions such as changing credentials that support staff must not perform on behalf of a user. It must be
+// wrapped by one of the role middlewares
+func DenyImpersonation(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := ClaimsFromCtx(r.Context())
+		if claims.IsImpersonated() {
+			routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Not available while impersonating"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
 // VerifyRootMiddleWare is used to verify that the requester is a valid admin
 func VerifyRootMiddleWare(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// TokenCheck inspects a decoded token and returns an error when the token must be rejected,
+// allowing other domains to plug revocation rules into authentication
+type TokenCheck func(ctx context.Context, tokenString string, claims *AppClaims) error
+
+// APIKeyResolver resolves the AppClaims of an INTEGRATION session from the value of an API-Key header
+type APIKeyResolver func(ctx context.Context, apiKey string) (*AppClaims, error)
+
+var (
+	checksMu       sync.RWMutex
+	tokenChecks    []TokenCheck
+	apiKeyResolver APIKeyResolver
+)
+
+// RegisterTokenCheck adds a TokenCheck to be run against every authenticated token
+func RegisterTokenCheck(check TokenCheck) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	tokenChecks = append(tokenChecks, check)
+}
+
+// RegisterAPIKeyResolver sets the APIKeyResolver used to authenticate requests carrying an API-Key header
+func RegisterAPIKeyResolver(resolver APIKeyResolver) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	apiKeyResolver = resolver
+}
+
+// ResolveAPIKey resolves the AppClaims of an API key using the registered APIKeyResolver
+func ResolveAPIKey(ctx context.Context, apiKey string) (*AppClaims, error) {
+	checksMu.RLock()
+	resolver := apiKeyResolver
+	checksMu.RUnlock()
+	if apiKey == "" || resolver == nil {
+		return &AppClaims{}, errors.New("unauthorized")
+	}
+	return resolver(ctx, apiKey)
+}
+
+// RunTokenChecks runs every registered TokenCheck against a decoded token
+func RunTokenChecks(ctx context.Context, tokenString string, claims *AppClaims) error {
+	checksMu.RLock()
+	defer checksMu.RUnlock()
+	for _, check := range tokenChecks {
+		if err := check(ctx, tokenString, claims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyToken decodes a token string and runs every registered TokenCheck against it
+func VerifyToken(ctx context.Context, tokenString string) (*AppClaims, error) {
+	claims, err := DecodeJWT(tokenString)
+	if err != nil {
+		return claims, err
+	}
+	if err = RunTokenChecks(ctx, tokenString, claims); err != nil {
+		return &AppClaims{}, err
+	}
+	return claims, nil
+}
@@ -2,29 +2,44 @@ package auth
 
 import (
 	"errors"
-	"fmt"
 	"github.com/JECSand/eventit-server/domains/shared/enums"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/spf13/viper"
 )
 
-type AppClaims struct {
-	ProfileId string     `json:"profileId,omitempty"`
+// Actor identifies the user acting on behalf of the subject of impersonation claims, as the act claim of RFC 8693
+type Actor struct {
+	ProfileId string     `json:"sub,omitempty"`
 	Role      enums.Role `json:"role,omitempty"`
+}
+
+type AppClaims struct {
+	ProfileId     string            `json:"profileId,omitempty"`
+	Role          enums.Role        `json:"role,omitempty"`
+	SessionType   enums.SessionType `json:"sessionType,omitempty"`
+	EmailVerified bool              `json:"emailVerified,omitempty"`
+	TwoFactor     bool              `json:"twoFactor,omitempty"`
+	Scopes        []string          `json:"scopes,omitempty"`
+	OrgId         string            `json:"orgId,omitempty"`
+	OrgRole       enums.Role        `json:"orgRole,omitempty"`
+	Actor         *Actor            `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IsImpersonated returns whether the claims were issued to a user acting on behalf of their subject
+func (c *AppClaims) IsImpersonated() bool {
+	return c.Actor != nil
+}
+
+// IsIntegration returns whether the claims were resolved from an API key rather than a user token
+func (c *AppClaims) IsIntegration() bool {
+	return c.SessionType == enums.INTEGRATION
+}
+
 func DecodeJWT(tokenString string) (*AppClaims, error) {
 	if tokenString == "" {
 		return &AppClaims{}, errors.New("unauthorized")
 	}
-	secret := viper.GetString("auth_jwt_secret")
-	token, err := jwt.ParseWithClaims(tokenString, &AppClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &AppClaims{}, CurrentKeyring().Keyfunc)
 	if err != nil {
 		//log.Fatal(err)
 		return &AppClaims{}, err
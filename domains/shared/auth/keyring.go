@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyConfig stores the configuration of a single JWT key as loaded from the auth_jwt_keys setting
+type KeyConfig struct {
+	Kid            string `mapstructure:"kid"`
+	Algorithm      string `mapstructure:"algorithm"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+	Secret         string `mapstructure:"secret"`
+	RetireAt       string `mapstructure:"retire_at"`
+}
+
+// SigningKey is a JWT key that can verify tokens, and sign them when it holds a private key or secret
+type SigningKey struct {
+	Kid      string
+	Method   jwt.SigningMethod
+	Private  crypto.PrivateKey
+	Public   crypto.PublicKey
+	RetireAt time.Time
+}
+
+// canSign returns whether the SigningKey holds the material needed to sign tokens
+func (sk *SigningKey) canSign() bool {
+	return sk.Private != nil
+}
+
+// active returns whether the SigningKey may still be used to verify tokens
+func (sk *SigningKey) active() bool {
+	return sk.RetireAt.IsZero() || time.Now().Before(sk.RetireAt)
+}
+
+// JWK is the json web key representation of a public SigningKey
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// PublicKey decodes the public key of an RSA, EC or Ed25519 JWK, such as the ones served by external identity providers
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch j.Kty {
+	case "RSA":
+		n, err := decode(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported jwk curve %q", j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("jwk point is not on its curve")
+		}
+		return pub, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported jwk curve %q", j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 jwk")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported jwk key type %q", j.Kty)
+}
+
+// JWKSet is a json web key set as served from /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Keyring holds the keys used to sign and verify tokens, verifying against any active key so that
+// tokens signed by a previous key remain valid during a rotation window. A Keyring is immutable,
+// keys are rotated by loading a new Keyring and passing it to SetKeyring
+type Keyring struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+	hmac    bool
+}
+
+var (
+	keyringMu     sync.RWMutex
+	globalKeyring *Keyring
+)
+
+// SetKeyring sets the Keyring used to sign and verify tokens
+func SetKeyring(k *Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	globalKeyring = k
+}
+
+// CurrentKeyring returns the configured Keyring, falling back to a HS256 keyring built from auth_jwt_secret
+func CurrentKeyring() *Keyring {
+	keyringMu.RLock()
+	k := globalKeyring
+	keyringMu.RUnlock()
+	if k != nil {
+		return k
+	}
+	return newSecretKeyring(viper.GetString("auth_jwt_secret"))
+}
+
+// newSecretKeyring returns a legacy Keyring signing and verifying kid-less tokens with a shared HMAC secret
+func newSecretKeyring(secret string) *Keyring {
+	sk := &SigningKey{Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)}
+	return &Keyring{signing: sk, keys: map[string]*SigningKey{"": sk}, hmac: true}
+}
+
+// NewKeyring initializes a new Keyring from a set of keys, signing with the key matching signingKid
+func NewKeyring(signingKid string, keys ...*SigningKey) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]*SigningKey)}
+	for _, sk := range keys {
+		if sk.Kid == "" {
+			return nil, errors.New("jwt keys require a kid")
+		}
+		if _, ok := k.keys[sk.Kid]; ok {
+			return nil, fmt.Errorf("duplicate jwt key kid: %s", sk.Kid)
+		}
+		k.keys[sk.Kid] = sk
+	}
+	signing, ok := k.keys[signingKid]
+	if !ok {
+		return nil, fmt.Errorf("signing kid %q is not in the keyring", signingKid)
+	}
+	if !signing.canSign() || !signing.active() {
+		return nil, fmt.Errorf("signing kid %q cannot be used to sign tokens", signingKid)
+	}
+	k.signing = signing
+	return k, nil
+}
+
+// LoadKeyring builds a Keyring from the auth_jwt_keys and auth_jwt_signing_kid settings,
+// returning nil when no keys are configured so that the auth_jwt_secret fallback is used
+func LoadKeyring() (*Keyring, error) {
+	var configs []KeyConfig
+	if err := viper.UnmarshalKey("auth_jwt_keys", &configs); err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	keys := make([]*SigningKey, 0, len(configs))
+	for _, c := range configs {
+		sk, err := loadSigningKey(c)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", c.Kid, err)
+		}
+		keys = append(keys, sk)
+	}
+	return NewKeyring(viper.GetString("auth_jwt_signing_kid"), keys...)
+}
+
+// loadSigningKey reads the PEM files or secret of a KeyConfig into a SigningKey
+func loadSigningKey(c KeyConfig) (*SigningKey, error) {
+	sk := &SigningKey{Kid: c.Kid}
+	if c.RetireAt != "" {
+		retireAt, err := time.Parse(time.RFC3339, c.RetireAt)
+		if err != nil {
+			return nil, err
+		}
+		sk.RetireAt = retireAt
+	}
+	if sk.Method = jwt.GetSigningMethod(c.Algorithm); sk.Method == nil {
+		return nil, fmt.Errorf("unsupported algorithm: %s", c.Algorithm)
+	}
+	var private, public []byte
+	var err error
+	if c.PrivateKeyFile != "" {
+		if private, err = os.ReadFile(c.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.PublicKeyFile != "" {
+		if public, err = os.ReadFile(c.PublicKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	switch sk.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		if private != nil {
+			var key *rsa.PrivateKey
+			if key, err = jwt.ParseRSAPrivateKeyFromPEM(private); err != nil {
+				return nil, err
+			}
+			sk.Private, sk.Public = key, &key.PublicKey
+		} else if public != nil {
+			if sk.Public, err = jwt.ParseRSAPublicKeyFromPEM(public); err != nil {
+				return nil, err
+			}
+		}
+	case *jwt.SigningMethodEd25519:
+		if private != nil {
+			var key crypto.PrivateKey
+			if key, err = jwt.ParseEdPrivateKeyFromPEM(private); err != nil {
+				return nil, err
+			}
+			sk.Private, sk.Public = key, key.(ed25519.PrivateKey).Public()
+		} else if public != nil {
+			if sk.Public, err = jwt.ParseEdPublicKeyFromPEM(public); err != nil {
+				return nil, err
+			}
+		}
+	case *jwt.SigningMethodHMAC:
+		if c.Secret != "" {
+			sk.Private, sk.Public = []byte(c.Secret), []byte(c.Secret)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", c.Algorithm)
+	}
+	if sk.Public == nil {
+		return nil, errors.New("no key material configured")
+	}
+	return sk, nil
+}
+
+// Sign signs a set of claims with the signing key, adding its kid to the token header
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	sk := k.signing
+	token := jwt.NewWithClaims(sk.Method, claims)
+	if !k.hmac {
+		token.Header["kid"] = sk.Kid
+	}
+	return token.SignedString(sk.Private)
+}
+
+// Keyfunc resolves the key used to verify a token from its kid header, ensuring the token algorithm matches the key
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if k.hmac {
+		kid = ""
+	}
+	sk, ok := k.keys[kid]
+	if !ok || !sk.active() {
+		return nil, fmt.Errorf("unknown signing key: %v", token.Header["kid"])
+	}
+	if token.Method.Alg() != sk.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return sk.Public, nil
+}
+
+// JWKS returns the json web key set of every active asymmetric key in the Keyring
+func (k *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(k.keys))}
+	for _, sk := range k.keys {
+		if !sk.active() {
+			continue
+		}
+		switch pub := sk.Public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: sk.Kid,
+				Use: "sig",
+				Alg: sk.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: sk.Kid,
+				Use: "sig",
+				Alg: sk.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
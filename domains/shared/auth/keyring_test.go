@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/golang-jwt/jwt/v5"
+	"testing"
+	"time"
+)
+
+func newTestKeys(t *testing.T) (*SigningKey, *SigningKey) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SigningKey{Kid: "rsa-1", Method: jwt.SigningMethodRS256, Private: rsaKey, Public: &rsaKey.PublicKey},
+		&SigningKey{Kid: "ed-1", Method: jwt.SigningMethodEdDSA, Private: edPriv, Public: edPub}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	defer SetKeyring(nil)
+	rsaKey, edKey := newTestKeys(t)
+	session := &Session{ProfileId: "000000000000000000000001", Role: enums.ADMIN}
+	// sign with the RSA key before rotating to the Ed25519 key
+	oldRing, err := NewKeyring("rsa-1", rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(oldRing)
+	oldToken, err := session.GetToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRing, err := NewKeyring("ed-1", rsaKey, edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(newRing)
+	newToken, err := session.GetToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name    string // The name of the test
+		wantKid string // The kid we want the token header to carry
+		wantErr bool   // whether we want an error.
+		input   string // The input of the test
+	}{
+		{"token from previous key", "rsa-1", false, oldToken},
+		{"token from signing key", "ed-1", false, newToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.ParseWithClaims(tt.input, &AppClaims{}, CurrentKeyring().Keyfunc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Keyfunc() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if kid := token.Header["kid"]; kid != tt.wantKid {
+				t.Errorf("Keyfunc() kid = %v, want %v", kid, tt.wantKid)
+			}
+		})
+	}
+	// once the previous key retires, its tokens are rejected
+	rsaKey.RetireAt = time.Now().Add(-time.Minute)
+	if _, err = DecodeJWT(oldToken); err == nil {
+		t.Errorf("DecodeJWT() accepted a token signed by a retired key")
+	}
+	if keys := CurrentKeyring().JWKS().Keys; len(keys) != 1 || keys[0].Kid != "ed-1" {
+		t.Errorf("JWKS() = %v, want only ed-1", keys)
+	}
+}
+
+func TestKeyring_RejectsAlgorithmMismatch(t *testing.T) {
+	defer SetKeyring(nil)
+	rsaKey, _ := newTestKeys(t)
+	ring, err := NewKeyring("rsa-1", rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(ring)
+	// an HS256 token using the kid of an RSA key must not verify
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, AppClaims{ProfileId: "000000000000000000000001", Role: enums.ROOT})
+	forged.Header["kid"] = "rsa-1"
+	tokenString, err := forged.SignedString([]byte("random"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecodeJWT(tokenString); err == nil {
+		t.Errorf("DecodeJWT() accepted a token with a mismatched algorithm")
+	}
+}
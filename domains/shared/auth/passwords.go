@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrPasswordMismatch is returned when a password does not match a password hash
+	ErrPasswordMismatch = errors.New("password does not match")
+	// ErrUnknownPasswordHash is returned when no PasswordHasher understands the format of a password hash
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+)
+
+// PasswordHasher hashes passwords into a self describing encoding that records the algorithm and its parameters
+type PasswordHasher interface {
+	// Hash returns the encoded hash of a password
+	Hash(password string) (string, error)
+	// Verify returns ErrPasswordMismatch when a password does not match an encoded hash
+	Verify(encoded string, password string) error
+	// Identify returns whether an encoded hash was produced by the algorithm of the PasswordHasher
+	Identify(encoded string) bool
+	// NeedsRehash returns whether an encoded hash of the same algorithm uses other parameters than the PasswordHasher
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2idHasher hashes passwords with argon2id, encoded in the PHC string format
+type Argon2idHasher struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2idHasher initializes an Argon2idHasher with the input cost parameters and 16 byte salts and 32 byte keys
+func NewArgon2idHasher(memory uint32, iterations uint32, parallelism uint8) *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:      memory,
+		Iterations:  iterations,
+		Parallelism: parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Hash returns the encoded argon2id hash of a password
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// decode parses an encoded argon2id hash into its parameters, salt and key
+func (h *Argon2idHasher) decode(encoded string) (params *Argon2idHasher, salt []byte, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+	params = &Argon2idHasher{}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// Verify compares a password with an encoded argon2id hash, using the parameters recorded in the hash
+func (h *Argon2idHasher) Verify(encoded string, password string) error {
+	params, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// Identify returns whether an encoded hash is an argon2id hash
+func (h *Argon2idHasher) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+// NeedsRehash returns whether an encoded argon2id hash uses other parameters than the Argon2idHasher
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := h.decode(encoded)
+	if err != nil {
+		return true
+	}
+	return *params != *h
+}
+
+// BcryptHasher hashes passwords with bcrypt, it is kept to verify the hashes created before argon2id was the default
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash returns the bcrypt hash of a password
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+// Verify compares a password with a bcrypt hash
+func (h *BcryptHasher) Verify(encoded string, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	return err
+}
+
+// Identify returns whether an encoded hash is a bcrypt hash
+func (h *BcryptHasher) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// NeedsRehash returns whether a bcrypt hash uses another cost than the BcryptHasher
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+var (
+	hasherMu     sync.RWMutex
+	globalHasher PasswordHasher
+	// legacyHashers verify hashes whose algorithm is not the configured one, those are always rehashed
+	legacyHashers = []PasswordHasher{&Argon2idHasher{}, &BcryptHasher{}}
+)
+
+// SetPasswordHasher sets the PasswordHasher used to hash new passwords
+func SetPasswordHasher(h PasswordHasher) {
+	hasherMu.Lock()
+	defer hasherMu.Unlock()
+	globalHasher = h
+}
+
+// CurrentPasswordHasher returns the configured PasswordHasher, falling back to argon2id with its default parameters
+func CurrentPasswordHasher() PasswordHasher {
+	hasherMu.RLock()
+	h := globalHasher
+	hasherMu.RUnlock()
+	if h != nil {
+		return h
+	}
+	return NewArgon2idHasher(19456, 2, 1)
+}
+
+// LoadPasswordHasher initializes the PasswordHasher configured by auth_password_hasher along with its parameters
+func LoadPasswordHasher() (PasswordHasher, error) {
+	switch algorithm := viper.GetString("auth_password_hasher"); algorithm {
+	case "", "argon2id":
+		memory, iterations := viper.GetUint32("auth_argon2_memory"), viper.GetUint32("auth_argon2_iterations")
+		parallelism := viper.GetUint("auth_argon2_parallelism")
+		if memory == 0 || iterations == 0 || parallelism == 0 || parallelism > 255 {
+			return nil, errors.New("argon2id requires a positive memory, iterations and a parallelism up to 255")
+		}
+		return NewArgon2idHasher(memory, iterations, uint8(parallelism)), nil
+	case "bcrypt":
+		cost := viper.GetInt("auth_bcrypt_cost")
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return &BcryptHasher{Cost: cost}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", algorithm)
+	}
+}
+
+// HashPassword hashes a password with the configured PasswordHasher
+func HashPassword(password string) (string, error) {
+	return CurrentPasswordHasher().Hash(password)
+}
+
+// VerifyPassword compares a password with an encoded hash of any supported algorithm, returning whether the hash
+// should be replaced because its algorithm or parameters differ from the configured PasswordHasher
+func VerifyPassword(encoded string, password string) (rehash bool, err error) {
+	current := CurrentPasswordHasher()
+	if current.Identify(encoded) {
+		if err = current.Verify(encoded, password); err != nil {
+			return false, err
+		}
+		return current.NeedsRehash(encoded), nil
+	}
+	for _, h := range legacyHashers {
+		if h.Identify(encoded) {
+			if err = h.Verify(encoded, password); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+	return false, ErrUnknownPasswordHash
+}
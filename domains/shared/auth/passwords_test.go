@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	defer SetPasswordHasher(nil)
+	SetPasswordHasher(NewArgon2idHasher(1024, 1, 1))
+	legacy, err := (&BcryptHasher{Cost: 4}).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdated, err := NewArgon2idHasher(512, 1, 1).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name       string // The name of the test
+		encoded    string // The stored password hash
+		password   string // The password being verified
+		wantRehash bool   // whether we want the hash to be replaced
+		wantErr    error  // The error we want, nil when the password matches
+	}{
+		{"current argon2id", current, "correct horse", false, nil},
+		{"outdated argon2id parameters", outdated, "correct horse", true, nil},
+		{"legacy bcrypt", legacy, "correct horse", true, nil},
+		{"argon2id mismatch", current, "battery staple", false, ErrPasswordMismatch},
+		{"bcrypt mismatch", legacy, "battery staple", false, ErrPasswordMismatch},
+		{"unknown format", "plaintext", "plaintext", false, ErrUnknownPasswordHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rehash, err := VerifyPassword(tt.encoded, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if rehash != tt.wantRehash {
+				t.Errorf("VerifyPassword() rehash = %v, want %v", rehash, tt.wantRehash)
+			}
+		})
+	}
+}
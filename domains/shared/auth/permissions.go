@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/JECSand/eventit-server/domains/shared/routers"
+	"github.com/spf13/viper"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// permissionPattern matches permissions such as events:write, orders:* or users, along with the * wildcard
+var permissionPattern = regexp.MustCompile(`^(\*|[a-z_]+(:[a-z_*]+)?)$`)
+
+// ValidPermission returns whether a permission is formatted as resource:action, where a missing or * action
+// grants every action on the resource and a lone * grants everything
+func ValidPermission(permission string) bool {
+	return permissionPattern.MatchString(permission)
+}
+
+// Grants returns whether a granted permission covers a required permission
+func Grants(granted string, required string) bool {
+	if granted == "*" || granted == required {
+		return true
+	}
+	resource, action, _ := strings.Cut(granted, ":")
+	if action == "" || action == "*" {
+		requiredResource, _, _ := strings.Cut(required, ":")
+		return resource == requiredResource
+	}
+	return false
+}
+
+// Permissions maps each role to the permissions it grants
+type Permissions map[enums.Role][]string
+
+// Granted returns whether a role grants a permission
+func (p Permissions) Granted(permission string, role enums.Role) bool {
+	for _, granted := range p[role] {
+		if Grants(granted, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionPolicy maps user roles and organization roles to the permissions they grant, organization roles are
+// kept apart so that administering an organization does not grant platform wide permissions such as users:*
+type PermissionPolicy struct {
+	Roles    Permissions
+	OrgRoles Permissions
+}
+
+// DefaultPermissionPolicy returns the permissions granted when auth_permissions and auth_org_permissions are not configured
+func DefaultPermissionPolicy() *PermissionPolicy {
+	return &PermissionPolicy{
+		Roles: Permissions{
+			enums.MEMBER: {"events:read", "orders:read", "orders:write"},
+			enums.ADMIN:  {"events:*", "orders:*", "users:*", "api_keys:*", "audit:read"},
+			enums.ROOT:   {"*"},
+		},
+		OrgRoles: Permissions{
+			enums.MEMBER: {"events:read", "orders:read", "orders:write"},
+			enums.ADMIN:  {"events:*", "orders:*"},
+			enums.ROOT:   {"events:*", "orders:*"},
+		},
+	}
+}
+
+// Granted returns whether a user role or an organization role grants a permission
+func (p *PermissionPolicy) Granted(permission string, role enums.Role, orgRole enums.Role) bool {
+	return p.Roles.Granted(permission, role) || p.OrgRoles.Granted(permission, orgRole)
+}
+
+// Of returns the sorted permissions granted by a user role and an organization role
+func (p *PermissionPolicy) Of(role enums.Role, orgRole enums.Role) []string {
+	seen := make(map[string]struct{})
+	permissions := make([]string, 0)
+	for _, granted := range append(append([]string{}, p.Roles[role]...), p.OrgRoles[orgRole]...) {
+		if _, ok := seen[granted]; !ok {
+			seen[granted] = struct{}{}
+			permissions = append(permissions, granted)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+var (
+	policyMu     sync.RWMutex
+	globalPolicy *PermissionPolicy
+)
+
+// SetPermissionPolicy sets the role to permission mappings used to authorize requests
+func SetPermissionPolicy(p *PermissionPolicy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	globalPolicy = p
+}
+
+// CurrentPermissionPolicy returns the configured role to permission mappings, falling back to DefaultPermissionPolicy
+func CurrentPermissionPolicy() *PermissionPolicy {
+	policyMu.RLock()
+	p := globalPolicy
+	policyMu.RUnlock()
+	if p != nil {
+		return p
+	}
+	return DefaultPermissionPolicy()
+}
+
+// loadPermissions overrides the permissions of the roles configured by a setting mapping role names to permissions
+func loadPermissions(setting string, permissions Permissions) error {
+	for name, granted := range viper.GetStringMapStringSlice(setting) {
+		role := enums.RoleFromString(strings.ToUpper(name))
+		if role == 0 {
+			return fmt.Errorf("%s: unknown role %q", setting, name)
+		}
+		for _, permission := range granted {
+			if !ValidPermission(permission) {
+				return fmt.Errorf("%s: invalid permission %q of role %s", setting, permission, role.Stringify())
+			}
+		}
+		permissions[role] = granted
+	}
+	return nil
+}
+
+// LoadPermissionPolicy initializes the role to permission mappings configured by auth_permissions for user roles
+// and auth_org_permissions for organization roles. Roles missing from the settings keep their default permissions
+func LoadPermissionPolicy() (*PermissionPolicy, error) {
+	policy := DefaultPermissionPolicy()
+	if err := loadPermissions("auth_permissions", policy.Roles); err != nil {
+		return nil, err
+	}
+	if err := loadPermissions("auth_org_permissions", policy.OrgRoles); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// grantingRoles returns the roles the permissions of the claims derive from, users who must but did not complete
+// two factor authentication are limited to MEMBER permissions like on the role middlewares
+func (c *AppClaims) grantingRoles() (enums.Role, enums.Role) {
+	role := c.Role
+	if !c.TwoFactor && !c.IsIntegration() && TwoFactorRequired(role) {
+		role = enums.MEMBER
+	}
+	return role, c.OrgRole
+}
+
+// Permissions returns the permissions granted to the claims by their role and organization role
+func (c *AppClaims) Permissions() []string {
+	return CurrentPermissionPolicy().Of(c.grantingRoles())
+}
+
+// HasPermission returns whether the claims are granted a permission by their role or organization role, the
+// claims of api keys are further limited to the scopes of their key
+func (c *AppClaims) HasPermission(permission string) bool {
+	role, orgRole := c.grantingRoles()
+	if !CurrentPermissionPolicy().Granted(permission, role, orgRole) {
+		return false
+	}
+	if !c.IsIntegration() {
+		return true
+	}
+	for _, scope := range c.Scopes {
+		if Grants(scope, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission is used to verify that the requester is authenticated and granted a permission
+func RequirePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return VerifyMemberMiddleWare(func(w http.ResponseWriter, r *http.Request) {
+		claims := ClaimsFromCtx(r.Context())
+		if !claims.HasPermission(permission) {
+			routers.RespondWithError(w, http.StatusForbidden, routers.JWTError{Message: "Missing permission " + permission})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
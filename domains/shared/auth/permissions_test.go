@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"testing"
+)
+
+func TestAppClaims_HasPermission(t *testing.T) {
+	defer SetPermissionPolicy(nil)
+	SetPermissionPolicy(&PermissionPolicy{
+		Roles: Permissions{
+			enums.MEMBER: {"events:read"},
+			enums.ADMIN:  {"events:*", "orders"},
+			enums.ROOT:   {"*"},
+		},
+		OrgRoles: Permissions{
+			enums.ADMIN: {"events:*"},
+		},
+	})
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name       string     // The name of the test
+		claims     *AppClaims // The claims of the requester
+		permission string     // The permission being checked
+		want       bool       // whether we want the permission to be granted
+	}{
+		{"exact grant", &AppClaims{Role: enums.MEMBER}, "events:read", true},
+		{"not granted", &AppClaims{Role: enums.MEMBER}, "events:write", false},
+		{"action wildcard", &AppClaims{Role: enums.ADMIN}, "events:write", true},
+		{"resource grant", &AppClaims{Role: enums.ADMIN}, "orders:refund", true},
+		{"other resource", &AppClaims{Role: enums.ADMIN}, "users:read", false},
+		{"root wildcard", &AppClaims{Role: enums.ROOT}, "users:delete", true},
+		{"organization role", &AppClaims{Role: enums.MEMBER, OrgRole: enums.ADMIN}, "events:write", true},
+		{"organization role beyond organization", &AppClaims{Role: enums.MEMBER, OrgRole: enums.ADMIN}, "orders:refund", false},
+		{"api key scope", &AppClaims{Role: enums.ADMIN, SessionType: enums.INTEGRATION, Scopes: []string{"events:*"}}, "events:write", true},
+		{"api key beyond scopes", &AppClaims{Role: enums.ADMIN, SessionType: enums.INTEGRATION, Scopes: []string{"events:read"}}, "orders:refund", false},
+		{"api key beyond role", &AppClaims{Role: enums.MEMBER, SessionType: enums.INTEGRATION, Scopes: []string{"events:*"}}, "events:write", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.HasPermission(tt.permission); got != tt.want {
+				t.Errorf("HasPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
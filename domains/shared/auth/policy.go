@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"github.com/JECSand/eventit-server/domains/shared/enums"
+	"github.com/spf13/viper"
+	"strings"
+)
+
+// VerificationPolicy enumerates when a user is required to have verified its email address
+type VerificationPolicy string
+
+const (
+	// VerifyEmailNever never requires a verified email address
+	VerifyEmailNever VerificationPolicy = "none"
+	// VerifyEmailForPurchase requires a verified email address on routes wrapped by VerifyEmailMiddleWare
+	VerifyEmailForPurchase VerificationPolicy = "purchase"
+	// VerifyEmailForLogin requires a verified email address to log in
+	VerifyEmailForLogin VerificationPolicy = "login"
+)
+
+// EmailVerificationPolicy returns the configured auth_email_verification_policy
+func EmailVerificationPolicy() VerificationPolicy {
+	switch p := VerificationPolicy(viper.GetString("auth_email_verification_policy")); p {
+	case VerifyEmailNever, VerifyEmailForLogin:
+		return p
+	default:
+		return VerifyEmailForPurchase
+	}
+}
+
+// TwoFactorRequired returns whether users of the input role must complete two factor authentication, as
+// configured by auth_two_factor_required_role which sets the lowest role it is required for
+func TwoFactorRequired(role enums.Role) bool {
+	required := enums.RoleFromString(strings.ToUpper(viper.GetString("auth_two_factor_required_role")))
+	return required != 0 && role >= required
+}
@@ -8,16 +8,55 @@ import (
 	"time"
 )
 
+const (
+	defaultTokenExpiry   = 15 * time.Minute
+	defaultRefreshExpiry = time.Hour
+	// defaultImpersonationExpiry is the lifetime of impersonation tokens, which cannot be refreshed
+	defaultImpersonationExpiry = 15 * time.Minute
+)
+
 // Session stores the structured data from a session token for use
 type Session struct {
-	ProfileId string     `json:"profileId,omitempty"`
-	Role      enums.Role `json:"role,omitempty"`
+	Id            string     `json:"sessionId,omitempty"`
+	ProfileId     string     `json:"profileId,omitempty"`
+	Role          enums.Role `json:"role,omitempty"`
+	EmailVerified bool       `json:"emailVerified,omitempty"`
+	TwoFactor     bool       `json:"twoFactor,omitempty"`
+	OrgId         string     `json:"orgId,omitempty"`
+	OrgRole       enums.Role `json:"orgRole,omitempty"`
+	Actor         *Actor     `json:"act,omitempty"`
+	// Expiry overrides the lifetime of the tokens of the session when set, such as for impersonation sessions
+	Expiry time.Duration `json:"-"`
+}
+
+// TokenExpiry returns the configured lifetime of an access token
+func TokenExpiry() time.Duration {
+	if expiry := viper.GetDuration("auth_jwt_expiry"); expiry > 0 {
+		return expiry
+	}
+	return defaultTokenExpiry
+}
+
+// RefreshExpiry returns the configured lifetime of a refresh token
+func RefreshExpiry() time.Duration {
+	if expiry := viper.GetDuration("auth_jwt_refresh_expiry"); expiry > 0 {
+		return expiry
+	}
+	return defaultRefreshExpiry
+}
+
+// ImpersonationExpiry returns the configured lifetime of an impersonation token
+func ImpersonationExpiry() time.Duration {
+	if expiry := viper.GetDuration("auth_impersonation_expiry"); expiry > 0 {
+		return expiry
+	}
+	return defaultImpersonationExpiry
 }
 
 func NewSession(profileId string, role enums.Role) *Session {
 	return &Session{
-		profileId,
-		role,
+		ProfileId: profileId,
+		Role:      role,
 	}
 }
 
@@ -30,8 +69,14 @@ func LoadSession(tokenString string) (*Session, error) {
 		return &Session{}, e
 	}
 	return &Session{
-		ProfileId: c.ProfileId,
-		Role:      c.Role,
+		Id:            c.ID,
+		ProfileId:     c.ProfileId,
+		Role:          c.Role,
+		EmailVerified: c.EmailVerified,
+		TwoFactor:     c.TwoFactor,
+		OrgId:         c.OrgId,
+		OrgRole:       c.OrgRole,
+		Actor:         c.Actor,
 	}, nil
 }
 
@@ -51,22 +96,29 @@ func (s *Session) GetToken() (string, error) {
 		}
 		return "", errors.New(errMsg)
 	}
-	secret := viper.GetString("auth_jwt_secret")
+	expiry := TokenExpiry()
+	if s.Expiry > 0 {
+		expiry = s.Expiry
+	}
 	claims := AppClaims{
-		s.ProfileId,
-		s.Role,
-		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+		ProfileId:     s.ProfileId,
+		Role:          s.Role,
+		EmailVerified: s.EmailVerified,
+		TwoFactor:     s.TwoFactor,
+		OrgId:         s.OrgId,
+		OrgRole:       s.OrgRole,
+		Actor:         s.Actor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
+			// the id of the persisted session, checked on every request so that revoking it takes effect immediately
+			ID: s.Id,
 			//Issuer:    "test",
 			//Subject:   "somebody",
-			//ID:        "1",
 			//Audience:  []string{"somebody_else"},
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	// Sign and get the complete encoded token as a string using the secret
-	return token.SignedString([]byte(secret))
-	// fmt.Println(tokenString, err)
+	// Sign and get the complete encoded token as a string using the keyring's signing key
+	return CurrentKeyring().Sign(claims)
 }
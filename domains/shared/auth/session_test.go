@@ -63,6 +63,21 @@ func Test_LoadSession(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
+	persisted := &Session{Id: "000000000000000000000002", ProfileId: "000000000000000000000001", Role: enums.MEMBER}
+	persistedToken, err := persisted.GetToken()
+	if err != nil {
+		panic(err)
+	}
+	tenant := &Session{ProfileId: "000000000000000000000001", Role: enums.MEMBER, OrgId: "000000000000000000000003", OrgRole: enums.ADMIN}
+	tenantToken, err := tenant.GetToken()
+	if err != nil {
+		panic(err)
+	}
+	impersonated := &Session{ProfileId: "000000000000000000000001", Role: enums.MEMBER, Actor: &Actor{ProfileId: "000000000000000000000004", Role: enums.ADMIN}}
+	impersonatedToken, err := impersonated.GetToken()
+	if err != nil {
+		panic(err)
+	}
 	// Defining our test slice. Each unit test should have the following properties:
 	tests := []struct {
 		name    string   // The name of the test
@@ -77,6 +92,24 @@ func Test_LoadSession(t *testing.T) {
 			false,
 			tokenString,
 		},
+		{
+			"session id as jti",
+			&Session{Id: "000000000000000000000002", ProfileId: "000000000000000000000001", Role: enums.MEMBER},
+			false,
+			persistedToken,
+		},
+		{
+			"organization claims",
+			&Session{ProfileId: "000000000000000000000001", Role: enums.MEMBER, OrgId: "000000000000000000000003", OrgRole: enums.ADMIN},
+			false,
+			tenantToken,
+		},
+		{
+			"actor claim",
+			&Session{ProfileId: "000000000000000000000001", Role: enums.MEMBER, Actor: &Actor{ProfileId: "000000000000000000000004", Role: enums.ADMIN}},
+			false,
+			impersonatedToken,
+		},
 		{
 			"empty token string",
 			&Session{},
@@ -3,10 +3,14 @@ package databases
 import (
 	"context"
 	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -14,19 +18,23 @@ import (
 type dbClient struct {
 	connectionURI string
 	client        *mongo.Client
+	// topologyMu guards the multi-document transaction support of the deployment, detected on first use
+	topologyMu    sync.Mutex
+	topologyKnown bool
+	transactional bool
+	// txMu runs the transactions of a standalone server one at a time
+	txMu sync.Mutex
 }
 
-/*
-// InitializeNewClient returns an initialized DBClient based on the ENV
+// InitializeNewClient returns an initialized DBClient based on the ENV, an in-memory client when ENV is test
 func InitializeNewClient() (DBClient, error) {
 	if os.Getenv("ENV") == "test" {
 		return initializeNewTestClient()
 	}
 	return initializeNewClient()
 }
-*/
 
-// InitializeNewClient is a function that takes a mongoUri string and outputs a connected mongo client for the app to use
+// initializeNewClient is a function that takes a mongoUri string and outputs a connected mongo client for the app to use
 func initializeNewClient() (*dbClient, error) {
 	newDBClient := dbClient{connectionURI: os.Getenv("MONGO_URI")}
 	var err error
@@ -72,3 +80,52 @@ func (db *dbClient) NewDBHandler(collectionName string) *DBRepo[DBRecord] {
 		Collection: col,
 	}
 }
+
+// supportsTransactions returns whether the deployment is a replica set or sharded cluster, standalone servers do
+// not support multi-document transactions
+func (db *dbClient) supportsTransactions(ctx context.Context) (bool, error) {
+	db.topologyMu.Lock()
+	defer db.topologyMu.Unlock()
+	if db.topologyKnown {
+		return db.transactional, nil
+	}
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := db.client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	db.topologyKnown = true
+	db.transactional = hello.SetName != "" || hello.Msg == "isdbgrid"
+	return db.transactional, nil
+}
+
+// WithTransaction runs fn in a multi-document transaction, DBRepos take part in it through WithContext(ctx) and
+// collections by being passed ctx. The transaction commits when fn returns nil and aborts otherwise. Transient
+// transaction errors and unknown commit results are retried by running fn again, so fn must not have effects outside
+// the database. A context already in a transaction runs fn in it, and on a standalone server the transactions run
+// one at a time without rollback
+func (db *dbClient) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+	transactional, err := db.supportsTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !transactional {
+		return serializedTransaction(ctx, &db.txMu, fn)
+	}
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+	opts := options.Transaction().SetReadConcern(readconcern.Snapshot()).SetWriteConcern(writeconcern.Majority())
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	}, opts)
+	return err
+}
@@ -0,0 +1,234 @@
+package databases
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"sync"
+)
+
+var (
+	fallbackSecretOnce sync.Once
+	fallbackSecret     []byte
+)
+
+// cursorSecret returns the key pagination cursors are signed with, configured by pagination_cursor_secret. When it
+// is not configured a random key is used so that cursors only stay valid until the process restarts
+func cursorSecret() []byte {
+	if secret := viper.GetString("pagination_cursor_secret"); secret != "" {
+		return []byte(secret)
+	}
+	fallbackSecretOnce.Do(func() {
+		fallbackSecret = make([]byte, 32)
+		if _, err := rand.Read(fallbackSecret); err != nil {
+			panic(err)
+		}
+	})
+	return fallbackSecret
+}
+
+// toDoc normalizes a document, filter or update into a bson.D holding the same value types a mongo server
+// returns, so that times compare as primitive.DateTime and nested documents as bson.D
+func toDoc(v interface{}) (bson.D, error) {
+	doc := bson.D{}
+	if v == nil {
+		return doc, nil
+	}
+	b, err := bson.Marshal(v)
+	if err != nil {
+		return doc, err
+	}
+	err = bson.Unmarshal(b, &doc)
+	return doc, err
+}
+
+// PageCursors are the opaque cursors of the pages around a page returned by CursorFind, a cursor is empty when
+// there is no page in its direction
+type PageCursors struct {
+	Next string
+	Prev string
+}
+
+// pageCursor is the signed content of a pagination cursor, the sort keys and _id of the record a page starts after
+type pageCursor struct {
+	OrderBy  string `bson:"o"`
+	Backward bool   `bson:"b"`
+	Keys     bson.A `bson:"k"`
+}
+
+// encodeCursor signs a pageCursor into an opaque url safe token
+func encodeCursor(c *pageCursor) (string, error) {
+	payload, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, cursorSecret())
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// decodeCursor verifies the signature of a token and returns the pageCursor it carries
+func decodeCursor(token string) (*pageCursor, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, utilities.ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, utilities.ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, utilities.ErrInvalidCursor
+	}
+	mac := hmac.New(sha256.New, cursorSecret())
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, utilities.ErrInvalidCursor
+	}
+	c := &pageCursor{}
+	if err = bson.Unmarshal(payload, c); err != nil {
+		return nil, utilities.ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// cursorFor returns the cursor of the page starting after a record in the order of a sort spec
+func cursorFor(record DBRecord, spec bson.D, orderBy string, backward bool) (string, error) {
+	doc, err := toDoc(record)
+	if err != nil {
+		return "", err
+	}
+	keys := make(bson.A, len(spec))
+	for i, s := range spec {
+		keys[i], _ = lookup(doc, s.Key)
+	}
+	return encodeCursor(&pageCursor{OrderBy: orderBy, Backward: backward, Keys: keys})
+}
+
+// reverseSpec flips the directions of a sort spec
+func reverseSpec(spec bson.D) bson.D {
+	reversed := make(bson.D, len(spec))
+	for i, s := range spec {
+		reversed[i] = bson.E{Key: s.Key, Value: -s.Value.(int)}
+	}
+	return reversed
+}
+
+// keysetFilter returns the filter of the records sorted after the keys of a cursor in the order of a sort spec,
+// missing fields sort first in ascending order as null does
+func keysetFilter(spec bson.D, keys bson.A) bson.D {
+	clauses := bson.A{}
+	for i, s := range spec {
+		clause := bson.D{}
+		for j := 0; j < i; j++ {
+			clause = append(clause, bson.E{Key: spec[j].Key, Value: keys[j]})
+		}
+		ascending := s.Value.(int) > 0
+		switch {
+		case keys[i] == nil && !ascending:
+			// nothing sorts below null
+			continue
+		case keys[i] == nil:
+			clause = append(clause, bson.E{Key: s.Key, Value: bson.D{{Key: "$ne", Value: nil}}})
+		case ascending:
+			clause = append(clause, bson.E{Key: s.Key, Value: bson.D{{Key: "$gt", Value: keys[i]}}})
+		default:
+			clause = append(clause, bson.E{Key: "$or", Value: bson.A{
+				bson.D{{Key: s.Key, Value: bson.D{{Key: "$lt", Value: keys[i]}}}},
+				bson.D{{Key: s.Key, Value: nil}},
+			}})
+		}
+		clauses = append(clauses, clause)
+	}
+	return bson.D{{Key: "$or", Value: clauses}}
+}
+
+// CursorFind is used to get a page of dbModels from the db with custom filter narrowed by optional queries, seeking
+// past the cursor of the Pagination instead of skipping records. Pages are ordered by the orderBy of the Pagination,
+// which the cursor must have been issued for
+func (h *DBRepo[T]) CursorFind(ctx context.Context, filter T, pagination *utilities.Pagination, queries ...*Query) ([]T, *PageCursors, error) {
+	m := make([]T, 0, pagination.GetSize())
+	cursors := &PageCursors{}
+	f, err := h.filter(filter, queries...)
+	if err != nil {
+		return m, cursors, err
+	}
+	spec, err := sortSpec(filter, pagination)
+	if err != nil {
+		return m, cursors, err
+	}
+	querySpec := spec
+	backward := false
+	if pagination.GetCursor() != "" {
+		c, err := decodeCursor(pagination.GetCursor())
+		if err != nil {
+			return m, cursors, err
+		}
+		if c.OrderBy != pagination.GetOrderBy() || len(c.Keys) != len(spec) {
+			return m, cursors, fmt.Errorf("%w: issued for another orderBy", utilities.ErrInvalidCursor)
+		}
+		if backward = c.Backward; backward {
+			querySpec = reverseSpec(spec)
+		}
+		if len(f) > 0 {
+			f = bson.D{{Key: "$and", Value: bson.A{f, keysetFilter(querySpec, c.Keys)}}}
+		} else {
+			f = keysetFilter(querySpec, c.Keys)
+		}
+	}
+	// one more record than the page size is read to learn whether another page follows
+	limit := int64(pagination.GetSize() + 1)
+	cur, err := h.Collection.Find(ctx, f, &options.FindOptions{
+		Limit: &limit,
+		Sort:  querySpec,
+	})
+	if err != nil {
+		return m, cursors, err
+	}
+	cursor := checkCursorENV(cur)
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var md T
+		if err = cursor.Decode(&md); err != nil {
+			return nil, cursors, err
+		}
+		if err = md.PostProcess(); err != nil {
+			return m, cursors, err
+		}
+		m = append(m, md)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, cursors, err
+	}
+	more := len(m) > pagination.GetSize()
+	if more {
+		m = m[:pagination.GetSize()]
+	}
+	if backward {
+		for i, j := 0, len(m)-1; i < j; i, j = i+1, j-1 {
+			m[i], m[j] = m[j], m[i]
+		}
+	}
+	if len(m) > 0 {
+		if more || backward {
+			if cursors.Next, err = cursorFor(m[len(m)-1], spec, pagination.GetOrderBy(), false); err != nil {
+				return nil, cursors, err
+			}
+		}
+		if (backward && more) || (!backward && pagination.GetCursor() != "") {
+			if cursors.Prev, err = cursorFor(m[0], spec, pagination.GetOrderBy(), true); err != nil {
+				return nil, cursors, err
+			}
+		}
+	}
+	return m, cursors, nil
+}
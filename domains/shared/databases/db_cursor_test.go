@@ -0,0 +1,112 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/shared/utilities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cursorTestRecord is a minimal DBRecord used to exercise CursorFind
+type cursorTestRecord struct {
+	Id   primitive.ObjectID `bson:"_id,omitempty"`
+	Name string             `bson:"name,omitempty"`
+	Rank int                `bson:"rank,omitempty"`
+}
+
+func (r *cursorTestRecord) ToDoc() (bson.D, error)       { return toDoc(r) }
+func (r *cursorTestRecord) BsonFilter() (bson.D, error)  { return bson.D{}, nil }
+func (r *cursorTestRecord) BsonUpdate() (bson.D, error)  { return bson.D{}, nil }
+func (r *cursorTestRecord) BsonLoad(doc bson.D) error    { return nil }
+func (r *cursorTestRecord) AddTimeStamps(newRecord bool) {}
+func (r *cursorTestRecord) AddObjectID()                 { r.Id = primitive.NewObjectID() }
+func (r *cursorTestRecord) PostProcess() error           { return nil }
+func (r *cursorTestRecord) GetID() interface{}           { return r.Id }
+func (r *cursorTestRecord) Update(doc interface{}) error { return nil }
+func (r *cursorTestRecord) Match(doc interface{}) bool   { return false }
+func (r *cursorTestRecord) SortFields() []string         { return []string{"name", "rank"} }
+
+// names returns the names of the input records
+func names(recs []*cursorTestRecord) string {
+	n := make([]string, len(recs))
+	for i, r := range recs {
+		n[i] = r.Name
+	}
+	return strings.Join(n, ",")
+}
+
+func TestDBRepo_CursorFind(t *testing.T) {
+	client, _ := initializeNewTestClient()
+	repo := &DBRepo[*cursorTestRecord]{DB: client, Collection: client.GetCollection("cursors")}
+	for i, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		// ranks repeat so that _id breaks the ties
+		if _, err := repo.InsertOne(&cursorTestRecord{Name: name, Rank: i / 2}); err != nil {
+			t.Fatalf("InsertOne() error = %v", err)
+		}
+	}
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name    string   // The name of the test
+		orderBy string   // The orderBy of the pages
+		want    []string // The names of the records of each page we want when following next_cursor
+	}{
+		{"default order", "", []string{"a,b,c", "d,e,f", "g"}},
+		{"descending with ties", "-rank", []string{"g,e,f", "c,d,a", "b"}},
+		{"descending name", "-name", []string{"g,f,e", "d,c,b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pagination := &utilities.Pagination{Size: 3, OrderBy: tt.orderBy}
+			pagination.SetCursor("")
+			var pages []string
+			var prevs []string
+			for {
+				recs, cursors, err := repo.CursorFind(context.Background(), &cursorTestRecord{}, pagination)
+				if err != nil {
+					t.Fatalf("CursorFind() error = %v", err)
+				}
+				pages = append(pages, names(recs))
+				prevs = append(prevs, cursors.Prev)
+				if cursors.Next == "" {
+					break
+				}
+				pagination.SetCursor(cursors.Next)
+			}
+			if !reflect.DeepEqual(pages, tt.want) {
+				t.Fatalf("CursorFind() pages = %v, want %v", pages, tt.want)
+			}
+			// walking back from the last page returns the previous pages
+			for i := len(pages) - 1; i > 0; i-- {
+				pagination.SetCursor(prevs[i])
+				recs, _, err := repo.CursorFind(context.Background(), &cursorTestRecord{}, pagination)
+				if err != nil {
+					t.Fatalf("CursorFind() error = %v", err)
+				}
+				if got := names(recs); got != pages[i-1] {
+					t.Errorf("CursorFind() previous page = %v, want %v", got, pages[i-1])
+				}
+			}
+		})
+	}
+	t.Run("cursor of another orderBy", func(t *testing.T) {
+		pagination := &utilities.Pagination{Size: 3, OrderBy: "name"}
+		pagination.SetCursor("")
+		_, cursors, _ := repo.CursorFind(context.Background(), &cursorTestRecord{}, pagination)
+		pagination.OrderBy = "-name"
+		pagination.SetCursor(cursors.Next)
+		if _, _, err := repo.CursorFind(context.Background(), &cursorTestRecord{}, pagination); !errors.Is(err, utilities.ErrInvalidCursor) {
+			t.Errorf("CursorFind() error = %v, want %v", err, utilities.ErrInvalidCursor)
+		}
+	})
+	t.Run("forged cursor", func(t *testing.T) {
+		pagination := &utilities.Pagination{Size: 3}
+		pagination.SetCursor("eyJ9.c2ln")
+		if _, _, err := repo.CursorFind(context.Background(), &cursorTestRecord{}, pagination); !errors.Is(err, utilities.ErrInvalidCursor) {
+			t.Errorf("CursorFind() error = %v, want %v", err, utilities.ErrInvalidCursor)
+		}
+	})
+}
@@ -0,0 +1,316 @@
+package databases
+
+import (
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned when the query params of a list request filter on a field or with an operator the
+// resource does not support, or with a malformed value
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query is a typed mongo filter built from conditions on the fields of a record, the conditions are matched along
+// with the filter of the record passed to the DBRepo. A nil or empty Query matches every record
+type Query struct {
+	doc bson.D
+}
+
+// Bson returns the bson filter of the Query
+func (q *Query) Bson() bson.D {
+	if q == nil || q.doc == nil {
+		return bson.D{}
+	}
+	return q.doc
+}
+
+// IsEmpty returns whether the Query has no conditions
+func (q *Query) IsEmpty() bool {
+	return len(q.Bson()) == 0
+}
+
+// condition returns a Query applying an operator to a field
+func condition(field string, op string, value interface{}) *Query {
+	return &Query{doc: bson.D{{Key: field, Value: bson.D{{Key: op, Value: value}}}}}
+}
+
+// Eq matches the records whose field equals a value, arrays match when they contain it
+func Eq(field string, value interface{}) *Query {
+	return condition(field, "$eq", value)
+}
+
+// Ne matches the records whose field does not equal a value, records missing the field included
+func Ne(field string, value interface{}) *Query {
+	return condition(field, "$ne", value)
+}
+
+// In matches the records whose field equals one of the values
+func In(field string, values ...interface{}) *Query {
+	return condition(field, "$in", bson.A(values))
+}
+
+// Gt matches the records whose field is greater than a value
+func Gt(field string, value interface{}) *Query {
+	return condition(field, "$gt", value)
+}
+
+// Gte matches the records whose field is greater than or equal to a value
+func Gte(field string, value interface{}) *Query {
+	return condition(field, "$gte", value)
+}
+
+// Lt matches the records whose field is less than a value
+func Lt(field string, value interface{}) *Query {
+	return condition(field, "$lt", value)
+}
+
+// Lte matches the records whose field is less than or equal to a value
+func Lte(field string, value interface{}) *Query {
+	return condition(field, "$lte", value)
+}
+
+// Range matches the records whose field lies between two inclusive bounds, a nil bound leaves its side open
+func Range(field string, min interface{}, max interface{}) *Query {
+	ops := bson.D{}
+	if min != nil {
+		ops = append(ops, bson.E{Key: "$gte", Value: min})
+	}
+	if max != nil {
+		ops = append(ops, bson.E{Key: "$lte", Value: max})
+	}
+	if len(ops) == 0 {
+		return &Query{}
+	}
+	return &Query{doc: bson.D{{Key: field, Value: ops}}}
+}
+
+// Regex matches the records whose field matches a regular expression with mongo regex options such as i
+func Regex(field string, pattern string, options string) *Query {
+	return &Query{doc: bson.D{{Key: field, Value: bson.D{{Key: "$regex", Value: pattern}, {Key: "$options", Value: options}}}}}
+}
+
+// Prefix matches the records whose field starts with a string regardless of case, the string is matched literally
+func Prefix(field string, prefix string) *Query {
+	return Regex(field, "^"+regexp.QuoteMeta(prefix), "i")
+}
+
+// Exists matches the records that have, or that are missing, a field
+func Exists(field string, exists bool) *Query {
+	return condition(field, "$exists", exists)
+}
+
+// logical combines the non-empty queries with a logical operator
+func logical(op string, queries []*Query) *Query {
+	clauses := bson.A{}
+	for _, q := range queries {
+		if !q.IsEmpty() {
+			clauses = append(clauses, q.Bson())
+		}
+	}
+	switch len(clauses) {
+	case 0:
+		return &Query{}
+	case 1:
+		return &Query{doc: clauses[0].(bson.D)}
+	}
+	return &Query{doc: bson.D{{Key: op, Value: clauses}}}
+}
+
+// And matches the records matching every query, empty queries are ignored
+func And(queries ...*Query) *Query {
+	return logical("$and", queries)
+}
+
+// Or matches the records matching any of the queries, empty queries are ignored
+func Or(queries ...*Query) *Query {
+	return logical("$or", queries)
+}
+
+// withQueries adds the conditions of queries to a bson filter
+func withQueries(f bson.D, queries []*Query) bson.D {
+	q := And(queries...)
+	if q.IsEmpty() {
+		return f
+	}
+	if len(f) == 0 {
+		return q.Bson()
+	}
+	return bson.D{{Key: "$and", Value: bson.A{f, q.Bson()}}}
+}
+
+// QueryOp is an operator of the query params of a list request, sent as field[op]=value
+type QueryOp string
+
+const (
+	OpEq     QueryOp = "eq"
+	OpNe     QueryOp = "ne"
+	OpIn     QueryOp = "in"
+	OpGt     QueryOp = "gt"
+	OpGte    QueryOp = "gte"
+	OpLt     QueryOp = "lt"
+	OpLte    QueryOp = "lte"
+	OpPrefix QueryOp = "prefix"
+	OpExists QueryOp = "exists"
+)
+
+// QueryParam maps a query param to the field of a record it filters, along with the parser of its values and
+// the operators it supports besides eq
+type QueryParam struct {
+	Field string
+	Parse func(value string) (interface{}, error)
+	Ops   []QueryOp
+}
+
+// allows returns whether the QueryParam supports an operator
+func (p QueryParam) allows(op QueryOp) bool {
+	if op == OpEq {
+		return true
+	}
+	for _, allowed := range p.Ops {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parse converts a raw query param value with the parser of the QueryParam
+func (p QueryParam) parse(name string, raw string) (interface{}, error) {
+	if p.Parse == nil {
+		return raw, nil
+	}
+	v, err := p.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s %q", ErrInvalidQuery, name, raw)
+	}
+	return v, nil
+}
+
+// query returns the Query of an operator applied to a raw query param value
+func (p QueryParam) query(name string, op QueryOp, raw string) (*Query, error) {
+	switch op {
+	case OpIn:
+		var values []interface{}
+		for _, part := range strings.Split(raw, ",") {
+			v, err := p.parse(name, strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return In(p.Field, values...), nil
+	case OpPrefix:
+		return Prefix(p.Field, raw), nil
+	case OpExists:
+		exists, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s[exists] %q", ErrInvalidQuery, name, raw)
+		}
+		return Exists(p.Field, exists), nil
+	}
+	v, err := p.parse(name, raw)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case OpNe:
+		return Ne(p.Field, v), nil
+	case OpGt:
+		return Gt(p.Field, v), nil
+	case OpGte:
+		return Gte(p.Field, v), nil
+	case OpLt:
+		return Lt(p.Field, v), nil
+	case OpLte:
+		return Lte(p.Field, v), nil
+	}
+	return Eq(p.Field, v), nil
+}
+
+// QuerySchema declares the query params a resource may be filtered by, params it does not declare are left to
+// other uses such as pagination. The search param matches the Search fields by prefix
+type QuerySchema struct {
+	Params map[string]QueryParam
+	Search []string
+}
+
+// Parse builds the Query of the filter params of a url query, given as field=value or field[op]=value, where in
+// takes comma separated values. Params filtering by the same field are combined
+func (s QuerySchema) Parse(values url.Values) (*Query, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	queries := make([]*Query, 0)
+	for _, key := range keys {
+		name, op := key, OpEq
+		if open := strings.IndexByte(key, '['); open > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:open], QueryOp(key[open+1:len(key)-1])
+		}
+		if name == "search" && len(s.Search) > 0 {
+			if op != OpEq {
+				return nil, fmt.Errorf("%w: search does not support %s", ErrInvalidQuery, op)
+			}
+			for _, raw := range values[key] {
+				if raw == "" {
+					continue
+				}
+				matches := make([]*Query, len(s.Search))
+				for i, field := range s.Search {
+					matches[i] = Prefix(field, raw)
+				}
+				queries = append(queries, Or(matches...))
+			}
+			continue
+		}
+		param, ok := s.Params[name]
+		if !ok {
+			continue
+		}
+		if !param.allows(op) {
+			return nil, fmt.Errorf("%w: %s does not support %s", ErrInvalidQuery, name, op)
+		}
+		for _, raw := range values[key] {
+			if raw == "" {
+				continue
+			}
+			q, err := param.query(name, op, raw)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, q)
+		}
+	}
+	return And(queries...), nil
+}
+
+// IntValue parses an integer query param value
+func IntValue(value string) (interface{}, error) {
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// BoolValue parses a boolean query param value
+func BoolValue(value string) (interface{}, error) {
+	return strconv.ParseBool(value)
+}
+
+// TimeValue parses an RFC 3339 timestamp or a 2006-01-02 date query param value
+func TimeValue(value string) (interface{}, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// ObjectIDValue parses a hex ObjectID query param value
+func ObjectIDValue(value string) (interface{}, error) {
+	return primitive.ObjectIDFromHex(value)
+}
@@ -0,0 +1,85 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestQuerySchema_Parse(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	col := newTestCollection(t,
+		bson.D{{Key: "name", Value: "Ann.B"}, {Key: "age", Value: 31}, {Key: "created_at", Value: day}},
+		bson.D{{Key: "name", Value: "bob"}, {Key: "age", Value: 25}, {Key: "city", Value: "annecy"}, {Key: "created_at", Value: day.AddDate(0, 0, 1)}},
+		bson.D{{Key: "name", Value: "annie"}, {Key: "age", Value: 40}, {Key: "created_at", Value: day.AddDate(0, 0, 2)}},
+	)
+	schema := QuerySchema{
+		Params: map[string]QueryParam{
+			"name":       {Field: "name", Ops: []QueryOp{OpIn, OpPrefix}},
+			"age":        {Field: "age", Parse: IntValue, Ops: []QueryOp{OpNe, OpGt, OpLte}},
+			"city":       {Field: "city", Ops: []QueryOp{OpExists}},
+			"created_at": {Field: "created_at", Parse: TimeValue, Ops: []QueryOp{OpGte, OpLt}},
+		},
+		Search: []string{"name", "city"},
+	}
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name    string   // The name of the test
+		query   string   // The url query being parsed
+		want    []string // The names of the documents we want the Query to match
+		wantErr bool     // whether we want an error
+	}{
+		{"no filter params", "page=2&orderBy=name", []string{"Ann.B", "bob", "annie"}, false},
+		{"equality", "name=bob", []string{"bob"}, false},
+		{"parsed equality", "age=40", []string{"annie"}, false},
+		{"in", "name[in]=bob,annie", []string{"bob", "annie"}, false},
+		{"prefix ignores case", "name[prefix]=an", []string{"Ann.B", "annie"}, false},
+		{"prefix is literal", "name[prefix]=ann.", []string{"Ann.B"}, false},
+		{"range", "age[gt]=25&age[lte]=40", []string{"Ann.B", "annie"}, false},
+		{"ne", "age[ne]=31", []string{"bob", "annie"}, false},
+		{"exists", "city[exists]=false", []string{"Ann.B", "annie"}, false},
+		{"dates", "created_at[gte]=2024-05-02&created_at[lt]=2024-05-03T00:00:00Z", []string{"bob"}, false},
+		{"search", "search=ANN", []string{"Ann.B", "bob", "annie"}, false},
+		{"search and filter", "search=ann&age[lte]=31", []string{"Ann.B", "bob"}, false},
+		{"empty values skipped", "name=&age=", []string{"Ann.B", "bob", "annie"}, false},
+		{"unsupported operator", "name[gt]=a", nil, true},
+		{"unknown operator", "age[where]=1", nil, true},
+		{"malformed value", "age[gt]=old", nil, true},
+		{"malformed in value", "age[in]=1,x", nil, true},
+		{"malformed exists", "city[exists]=maybe", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			q, err := schema.Parse(values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Errorf("Parse() error = %v, want %v", err, ErrInvalidQuery)
+				}
+				return
+			}
+			cur, err := col.Find(context.Background(), withQueries(bson.D{}, []*Query{q}))
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			var got []bson.M
+			if err = cur.All(context.Background(), &got); err != nil {
+				t.Fatalf("All() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Parse() matched %d documents, want %d", len(got), len(tt.want))
+			}
+			for i, doc := range got {
+				if doc["name"] != tt.want[i] {
+					t.Errorf("Parse() match %d = %v, want %v", i, doc["name"], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -2,28 +2,114 @@ package databases
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/JECSand/eventit-server/domains/shared/utilities"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"sync"
 	"time"
 )
 
+// deletedAtKey is the field recording when a record of a soft deleting DBRepo was deleted
+const deletedAtKey = "deleted_at"
+
+// ErrEmptyFilter is returned when a write is filtered by a record without any field set, which would otherwise
+// match an arbitrary record or every record of the collection
+var ErrEmptyFilter = errors.New("a non-empty filter is required")
+
 // DBRepo is a Generic type struct for organizing dbModel methods
 type DBRepo[T DBRecord] struct {
 	DB         DBClient
 	Collection DBCollection
+	// SoftDelete makes DeleteOne and DeleteMany set deleted_at instead of removing records, deleted records are
+	// excluded from reads and updates unless the DBRepo is returned by WithDeleted
+	SoftDelete bool
+	// OrgScoped marks collections of records owned by an organization, the DBRepos returned by WithContext only
+	// read, update and delete the records of the organization of the caller and assign it to inserted records
+	OrgScoped      bool
+	includeDeleted bool
+	tenant         bool
+	orgId          primitive.ObjectID
+	ctx            context.Context
+}
+
+// WithDeleted returns a copy of the DBRepo whose reads and updates include soft deleted records
+func (h *DBRepo[T]) WithDeleted() *DBRepo[T] {
+	c := *h
+	c.includeDeleted = true
+	return &c
+}
+
+// WithContext returns a copy of the DBRepo whose operations run under the context, joining the transaction of a
+// context passed to WithTransaction. Org scoped copies are limited to the organization of the caller carried by the
+// context, a context without an organization matches no records
+func (h *DBRepo[T]) WithContext(ctx context.Context) *DBRepo[T] {
+	c := *h
+	c.tenant = h.OrgScoped
+	c.orgId, _ = OrganizationFromContext(ctx)
+	c.ctx = ctx
+	return &c
+}
+
+// context returns the context of an operation of the DBRepo, derived from the context of WithContext if any
+func (h *DBRepo[T]) context(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx := h.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// scope excludes soft deleted records and the records of other organizations from a bson filter
+func (h *DBRepo[T]) scope(f bson.D) bson.D {
+	f = h.scopeOrganization(f)
+	if h.SoftDelete && !h.includeDeleted {
+		// a null match covers both records that never had the field and records restored by unsetting it
+		f = append(f, bson.E{Key: deletedAtKey, Value: nil})
+	}
+	return f
+}
+
+// scopeOrganization limits a bson filter to the organization of the caller of a DBRepo returned by WithContext
+func (h *DBRepo[T]) scopeOrganization(f bson.D) bson.D {
+	if h.tenant {
+		f = append(f, bson.E{Key: organizationKey, Value: h.orgId})
+	}
+	return f
+}
+
+// filter returns the scoped bson filter of a record along with the conditions of queries
+func (h *DBRepo[T]) filter(filter T, queries ...*Query) (bson.D, error) {
+	f, err := filter.BsonFilter()
+	if err != nil {
+		return f, err
+	}
+	return withQueries(h.scope(f), queries), nil
+}
+
+// writeFilter returns the scoped bson filter of a record for a write, failing when the record sets no field
+func (h *DBRepo[T]) writeFilter(op string, filter T) (bson.D, error) {
+	f, err := filter.BsonFilter()
+	if err != nil {
+		return f, err
+	}
+	if len(f) == 0 {
+		return f, fmt.Errorf("%s: %w", op, ErrEmptyFilter)
+	}
+	return h.scope(f), nil
 }
 
 // FindOne is used to get a dbModel from the db with custom filter
 func (h *DBRepo[T]) FindOne(filter T) (T, error) {
 	var m T
-	f, err := filter.BsonFilter()
+	f, err := h.filter(filter)
 	if err != nil {
 		return filter, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := h.context(30 * time.Second)
 	defer cancel()
 	err = h.Collection.FindOne(ctx, f).Decode(&m)
 	if err != nil {
@@ -40,14 +126,14 @@ func (h *DBRepo[T]) FindOneAsync(tCh chan T, eCh chan error, filter T, wg *sync.
 	eCh <- err
 }
 
-// FindMany is used to get a slice of dbModels from the db with custom filter
-func (h *DBRepo[T]) FindMany(filter T) ([]T, error) {
+// FindMany is used to get a slice of dbModels from the db with custom filter, narrowed by optional queries
+func (h *DBRepo[T]) FindMany(filter T, queries ...*Query) ([]T, error) {
 	var m []T
-	f, err := filter.BsonFilter()
+	f, err := h.filter(filter, queries...)
 	if err != nil {
 		return m, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := h.context(30 * time.Second)
 	defer cancel()
 	var cur *mongo.Cursor
 	if len(f) > 0 {
@@ -72,10 +158,43 @@ func (h *DBRepo[T]) FindMany(filter T) ([]T, error) {
 	return m, nil
 }
 
-// PaginatedFind is used to get a slice of dbModels from the db with custom filter
-func (h *DBRepo[T]) PaginatedFind(ctx context.Context, filter T, pagination *utilities.Pagination) ([]T, error) {
+// sortSpec translates the orderBy of a Pagination into a mongo sort, rejecting the fields missing from the
+// SortFields of the record. Records are ordered by _id last so that pages are stable
+func sortSpec(record DBRecord, pagination *utilities.Pagination) (bson.D, error) {
+	fields, err := pagination.GetSortFields()
+	if err != nil {
+		return nil, err
+	}
+	spec := make(bson.D, 0, len(fields)+1)
+	for _, field := range fields {
+		sortable := false
+		for _, allowed := range record.SortFields() {
+			if field.Field == allowed {
+				sortable = true
+				break
+			}
+		}
+		if !sortable {
+			return nil, fmt.Errorf("%w: cannot order by %s", utilities.ErrInvalidOrderBy, field.Field)
+		}
+		direction := 1
+		if field.Descending {
+			direction = -1
+		}
+		spec = append(spec, bson.E{Key: field.Field, Value: direction})
+	}
+	return append(spec, bson.E{Key: "_id", Value: 1}), nil
+}
+
+// PaginatedFind is used to get a slice of dbModels from the db with custom filter narrowed by optional queries,
+// ordered by the orderBy of the Pagination
+func (h *DBRepo[T]) PaginatedFind(ctx context.Context, filter T, pagination *utilities.Pagination, queries ...*Query) ([]T, error) {
 	var m []T
-	f, err := filter.BsonFilter()
+	f, err := h.filter(filter, queries...)
+	if err != nil {
+		return m, err
+	}
+	sort, err := sortSpec(filter, pagination)
 	if err != nil {
 		return m, err
 	}
@@ -86,11 +205,13 @@ func (h *DBRepo[T]) PaginatedFind(ctx context.Context, filter T, pagination *uti
 		cur, err = h.Collection.Find(ctx, f, &options.FindOptions{
 			Limit: &limit,
 			Skip:  &skip,
+			Sort:  sort,
 		})
 	} else {
 		cur, err = h.Collection.Find(ctx, bson.M{}, &options.FindOptions{
 			Limit: &limit,
 			Skip:  &skip,
+			Sort:  sort,
 		})
 	}
 	if err != nil {
@@ -116,20 +237,23 @@ func (h *DBRepo[T]) PaginatedFind(ctx context.Context, filter T, pagination *uti
 	return m, nil
 }
 
-// Count Function to update a dbModel from datasource with custom filter and update model
-func (h *DBRepo[T]) Count(filter T) (int64, error) {
-	f, err := filter.BsonFilter()
+// Count returns the number of dbModels matching a custom filter narrowed by optional queries
+func (h *DBRepo[T]) Count(filter T, queries ...*Query) (int64, error) {
+	f, err := h.filter(filter, queries...)
 	if err != nil {
 		return 0, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := h.context(30 * time.Second)
 	defer cancel()
+	if len(f) == 0 {
+		return h.Collection.CountDocuments(ctx, bson.D{})
+	}
 	return h.Collection.CountDocuments(ctx, f)
 }
 
 // UpdateOne Function to update a dbModel from datasource with custom filter and update model
 func (h *DBRepo[T]) UpdateOne(filter T, m T) (T, error) {
-	f, err := filter.BsonFilter()
+	f, err := h.writeFilter("UpdateOne", filter)
 	if err != nil {
 		return m, err
 	}
@@ -138,21 +262,45 @@ func (h *DBRepo[T]) UpdateOne(filter T, m T) (T, error) {
 	if err != nil {
 		return m, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := h.context(30 * time.Second)
 	defer cancel()
 	_, err = h.Collection.UpdateOne(ctx, f, update)
+	// the update model only holds the fields being set, so it is not validated like a stored record
+	return m, err
+}
+
+// UpdateMany Function to update every dbModel from datasource matching a custom filter with an update model
+func (h *DBRepo[T]) UpdateMany(filter T, m T) (int64, error) {
+	f, err := h.writeFilter("UpdateMany", filter)
 	if err != nil {
-		return m, err
+		return 0, err
 	}
-	err = m.PostProcess()
-	return m, err
+	m.AddTimeStamps(false)
+	update, err := m.BsonUpdate()
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := h.context(30 * time.Second)
+	defer cancel()
+	res, err := h.Collection.UpdateMany(ctx, f, update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
 }
 
 // InsertOne adds a new dbModel record to a collection
 func (h *DBRepo[T]) InsertOne(m T) (T, error) {
+	if h.tenant {
+		rec, ok := any(m).(OrgRecord)
+		if !ok || h.orgId.IsZero() {
+			return m, ErrNoOrganization
+		}
+		rec.SetOrganizationID(h.orgId)
+	}
 	m.AddTimeStamps(true)
 	m.AddObjectID()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := h.context(10 * time.Second)
 	defer cancel()
 	_, err := h.Collection.InsertOne(ctx, m)
 	if err != nil {
@@ -162,32 +310,112 @@ func (h *DBRepo[T]) InsertOne(m T) (T, error) {
 	return m, err
 }
 
-// DeleteOne adds a new dbModel record to a collection
-func (h *DBRepo[T]) DeleteOne(filter T) (T, error) { //TODO: to be replaced with "soft delete"
+// DeleteOne deletes the dbModel matching a custom filter, returning it. Soft deleting DBRepos set its deleted_at
+// instead of removing it
+func (h *DBRepo[T]) DeleteOne(filter T) (T, error) {
 	var m T
-	f, err := filter.BsonFilter()
+	f, err := h.writeFilter("DeleteOne", filter)
 	if err != nil {
 		return m, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := h.context(10 * time.Second)
 	defer cancel()
+	if h.SoftDelete {
+		now := time.Now().UTC()
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: deletedAtKey, Value: now}, {Key: "updated_at", Value: now}}}}
+		err = h.Collection.FindOneAndUpdate(ctx, f, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&m)
+		return m, err
+	}
 	err = h.Collection.FindOneAndDelete(ctx, f).Decode(&m)
 	return m, err
 }
 
-// DeleteMany adds a new dbModel record to a collection
-func (h *DBRepo[T]) DeleteMany(filter T) (T, error) { //TODO: to be replaced with "soft delete"
+// DeleteMany deletes every dbModel matching a custom filter. Soft deleting DBRepos set their deleted_at instead
+// of removing them
+func (h *DBRepo[T]) DeleteMany(filter T) (T, error) {
 	var m T
-	f, err := filter.BsonFilter()
+	f, err := h.writeFilter("DeleteMany", filter)
 	if err != nil {
 		return m, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := h.context(10 * time.Second)
 	defer cancel()
+	if h.SoftDelete {
+		now := time.Now().UTC()
+		update := bson.D{{Key: "$set", Value: bson.D{{Key: deletedAtKey, Value: now}, {Key: "updated_at", Value: now}}}}
+		_, err = h.Collection.UpdateMany(ctx, f, update)
+		return filter, err
+	}
 	_, err = h.Collection.DeleteMany(ctx, f)
 	return filter, err
 }
 
+// Restore undoes the soft delete of the dbModel matching a custom filter, returning it
+func (h *DBRepo[T]) Restore(filter T) (T, error) {
+	var m T
+	if !h.SoftDelete {
+		return m, errors.New("Restore requires a soft deleting DBRepo")
+	}
+	f, err := filter.BsonFilter()
+	if err != nil {
+		return m, err
+	}
+	if len(f) == 0 {
+		return m, fmt.Errorf("Restore: %w", ErrEmptyFilter)
+	}
+	f = append(h.scopeOrganization(f), bson.E{Key: deletedAtKey, Value: bson.D{{Key: "$ne", Value: nil}}})
+	update := bson.D{
+		{Key: "$unset", Value: bson.D{{Key: deletedAtKey, Value: ""}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now().UTC()}}},
+	}
+	ctx, cancel := h.context(10 * time.Second)
+	defer cancel()
+	err = h.Collection.FindOneAndUpdate(ctx, f, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&m)
+	return m, err
+}
+
+// Purge permanently removes the soft deleted dbModels that were deleted before the input time, returning how
+// many were removed
+func (h *DBRepo[T]) Purge(deletedBefore time.Time) (int64, error) {
+	if !h.SoftDelete {
+		return 0, errors.New("Purge requires a soft deleting DBRepo")
+	}
+	ctx, cancel := h.context(30 * time.Second)
+	defer cancel()
+	res, err := h.Collection.DeleteMany(ctx, bson.D{{Key: deletedAtKey, Value: bson.D{{Key: "$lt", Value: deletedBefore}}}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// indexer is implemented by DBCollection types that support index management
+type indexer interface {
+	Indexes() mongo.IndexView
+}
+
+// indexRegistrar is implemented by DBCollection types that enforce indexes themselves instead of through an
+// IndexView, like the in-memory test collections
+type indexRegistrar interface {
+	createIndex(model mongo.IndexModel) error
+}
+
+// EnsureIndex creates an index on the collection, it is a no-op for collections without index support. The
+// in-memory test collections only record unique indexes to enforce them
+func (h *DBRepo[T]) EnsureIndex(model mongo.IndexModel) error {
+	if col, ok := h.Collection.(indexRegistrar); ok {
+		return col.createIndex(model)
+	}
+	col, ok := h.Collection.(indexer)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := col.Indexes().CreateOne(ctx, model)
+	return err
+}
+
 // newRoutine returns a new Routine for executing ASYNC DB statements
 func (h *DBRepo[T]) newRoutine() *dbRoutine[T] {
 	return &dbRoutine[T]{handler: h}
@@ -0,0 +1,164 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+	"time"
+)
+
+// softTestRecord is a minimal DBRecord filtered by its id and name, used to exercise soft deleting DBRepos
+type softTestRecord struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name,omitempty"`
+	DeletedAt time.Time          `bson:"deleted_at,omitempty"`
+}
+
+func (r *softTestRecord) ToDoc() (bson.D, error) { return toDoc(r) }
+func (r *softTestRecord) BsonFilter() (bson.D, error) {
+	f := bson.D{}
+	if !r.Id.IsZero() {
+		f = append(f, bson.E{Key: "_id", Value: r.Id})
+	}
+	if r.Name != "" {
+		f = append(f, bson.E{Key: "name", Value: r.Name})
+	}
+	return f, nil
+}
+func (r *softTestRecord) BsonUpdate() (bson.D, error) {
+	return bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: r.Name}}}}, nil
+}
+func (r *softTestRecord) BsonLoad(doc bson.D) error    { return nil }
+func (r *softTestRecord) AddTimeStamps(newRecord bool) {}
+func (r *softTestRecord) AddObjectID()                 { r.Id = primitive.NewObjectID() }
+func (r *softTestRecord) PostProcess() error           { return nil }
+func (r *softTestRecord) GetID() interface{}           { return r.Id }
+func (r *softTestRecord) Update(doc interface{}) error { return nil }
+func (r *softTestRecord) Match(doc interface{}) bool   { return false }
+func (r *softTestRecord) SortFields() []string         { return []string{"name"} }
+
+// newSoftTestRepo returns a soft deleting DBRepo on the in-memory client holding records of the input names
+func newSoftTestRepo(t *testing.T, names ...string) *DBRepo[*softTestRecord] {
+	t.Helper()
+	client, _ := initializeNewTestClient()
+	repo := &DBRepo[*softTestRecord]{DB: client, Collection: client.GetCollection("soft"), SoftDelete: true}
+	for _, name := range names {
+		if _, err := repo.InsertOne(&softTestRecord{Name: name}); err != nil {
+			t.Fatalf("InsertOne() error = %v", err)
+		}
+	}
+	return repo
+}
+
+func TestDBRepo_SoftDelete(t *testing.T) {
+	repo := newSoftTestRepo(t, "ann", "bob", "cid")
+	if _, err := repo.DeleteOne(&softTestRecord{Name: "ann"}); err != nil {
+		t.Fatalf("DeleteOne() error = %v", err)
+	}
+	if _, err := repo.FindOne(&softTestRecord{Name: "ann"}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("FindOne() of a deleted record error = %v, want %v", err, mongo.ErrNoDocuments)
+	}
+	if recs, _ := repo.FindMany(&softTestRecord{}); len(recs) != 2 {
+		t.Errorf("FindMany() returned %d records, want 2", len(recs))
+	}
+	if count, _ := repo.Count(&softTestRecord{}); count != 2 {
+		t.Errorf("Count() = %d, want 2", count)
+	}
+	if count, _ := repo.WithDeleted().Count(&softTestRecord{}); count != 3 {
+		t.Errorf("WithDeleted().Count() = %d, want 3", count)
+	}
+	deleted, err := repo.WithDeleted().FindOne(&softTestRecord{Name: "ann"})
+	if err != nil || deleted.DeletedAt.IsZero() {
+		t.Fatalf("WithDeleted().FindOne() = %+v, %v, want the deleted record", deleted, err)
+	}
+	if _, err = repo.UpdateOne(&softTestRecord{Name: "ann"}, &softTestRecord{Name: "ann2"}); err != nil {
+		t.Fatalf("UpdateOne() error = %v", err)
+	}
+	if _, err = repo.WithDeleted().FindOne(&softTestRecord{Name: "ann2"}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("UpdateOne() updated a deleted record")
+	}
+	restored, err := repo.Restore(&softTestRecord{Id: deleted.Id})
+	if err != nil || !restored.DeletedAt.IsZero() {
+		t.Fatalf("Restore() = %+v, %v, want the restored record", restored, err)
+	}
+	if _, err = repo.FindOne(&softTestRecord{Name: "ann"}); err != nil {
+		t.Errorf("FindOne() of a restored record error = %v", err)
+	}
+	if _, err = repo.Restore(&softTestRecord{Name: "bob"}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("Restore() of a record that is not deleted error = %v, want %v", err, mongo.ErrNoDocuments)
+	}
+}
+
+func TestDBRepo_Purge(t *testing.T) {
+	repo := newSoftTestRepo(t, "ann", "bob", "cid")
+	for _, name := range []string{"ann", "bob"} {
+		if _, err := repo.DeleteOne(&softTestRecord{Name: name}); err != nil {
+			t.Fatalf("DeleteOne() error = %v", err)
+		}
+	}
+	// ann was deleted past the retention cutoff
+	_, err := repo.Collection.UpdateOne(context.Background(), bson.D{{Key: "name", Value: "ann"}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: deletedAtKey, Value: time.Now().UTC().Add(-48 * time.Hour)}}}})
+	if err != nil {
+		t.Fatalf("UpdateOne() error = %v", err)
+	}
+	purged, err := repo.Purge(time.Now().UTC().Add(-24 * time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("Purge() = %d, %v, want 1", purged, err)
+	}
+	if _, err = repo.WithDeleted().FindOne(&softTestRecord{Name: "ann"}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("FindOne() of a purged record error = %v, want %v", err, mongo.ErrNoDocuments)
+	}
+	if _, err = repo.WithDeleted().FindOne(&softTestRecord{Name: "bob"}); err != nil {
+		t.Errorf("Purge() removed a record deleted within the retention: %v", err)
+	}
+	if count, _ := repo.WithDeleted().Count(&softTestRecord{}); count != 2 {
+		t.Errorf("WithDeleted().Count() = %d, want 2", count)
+	}
+}
+
+func TestDBRepo_EmptyFilter(t *testing.T) {
+	repo := newSoftTestRepo(t, "ann", "bob")
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name  string                        // The name of the test
+		write func(r *softTestRecord) error // The write filtered by the input record
+	}{
+		{"UpdateOne", func(r *softTestRecord) error {
+			_, err := repo.UpdateOne(r, &softTestRecord{Name: "changed"})
+			return err
+		}},
+		{"UpdateMany", func(r *softTestRecord) error {
+			_, err := repo.UpdateMany(r, &softTestRecord{Name: "changed"})
+			return err
+		}},
+		{"DeleteOne", func(r *softTestRecord) error {
+			_, err := repo.DeleteOne(r)
+			return err
+		}},
+		{"DeleteMany", func(r *softTestRecord) error {
+			_, err := repo.DeleteMany(r)
+			return err
+		}},
+		{"Restore", func(r *softTestRecord) error {
+			_, err := repo.Restore(r)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(&softTestRecord{}); !errors.Is(err, ErrEmptyFilter) {
+				t.Errorf("%s() error = %v, want %v", tt.name, err, ErrEmptyFilter)
+			}
+		})
+	}
+	if count, _ := repo.Count(&softTestRecord{Name: "changed"}); count != 0 {
+		t.Errorf("an empty filter changed %d records", count)
+	}
+	if count, _ := repo.Count(&softTestRecord{}); count != 2 {
+		t.Errorf("Count() = %d, want 2", count)
+	}
+}
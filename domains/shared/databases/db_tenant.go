@@ -0,0 +1,38 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNoOrganization is returned when a record is inserted into an org scoped DBRepo without an organization
+var ErrNoOrganization = errors.New("an organization is required to insert into an org scoped collection")
+
+// organizationKey is the field recording the organization owning a record of an org scoped DBRepo
+const organizationKey = "organization_id"
+
+type ctxKey int
+
+const (
+	ctxOrganization ctxKey = iota
+	ctxTransaction
+	ctxUndoLog
+)
+
+// OrgRecord is implemented by DBRecord types owned by an organization, so that org scoped DBRepos can assign
+// the organization of the caller to the records they insert
+type OrgRecord interface {
+	SetOrganizationID(id primitive.ObjectID)
+}
+
+// ContextWithOrganization returns a copy of a context carrying the id of the organization of the caller
+func ContextWithOrganization(ctx context.Context, orgId primitive.ObjectID) context.Context {
+	return context.WithValue(ctx, ctxOrganization, orgId)
+}
+
+// OrganizationFromContext returns the id of the organization of the caller carried by a context
+func OrganizationFromContext(ctx context.Context) (primitive.ObjectID, bool) {
+	orgId, ok := ctx.Value(ctxOrganization).(primitive.ObjectID)
+	return orgId, ok && !orgId.IsZero()
+}
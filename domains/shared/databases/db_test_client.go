@@ -0,0 +1,552 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrBucketsNotSupported is returned when a gridfs bucket is requested from the in-memory test client
+var ErrBucketsNotSupported = errors.New("gridfs buckets are not supported by the test client")
+
+// testDBClient is an in-memory DBClient used by tests and local development when ENV is test
+type testDBClient struct {
+	mu          sync.Mutex
+	txMu        sync.Mutex
+	collections map[string]*testDBCollection
+}
+
+// initializeNewTestClient returns an empty in-memory client
+func initializeNewTestClient() (*testDBClient, error) {
+	return &testDBClient{collections: make(map[string]*testDBCollection)}, nil
+}
+
+// Connect is a no-op for the in-memory client
+func (db *testDBClient) Connect() error {
+	return nil
+}
+
+// Close is a no-op for the in-memory client, its collections are kept until it is released
+func (db *testDBClient) Close() error {
+	return nil
+}
+
+// GetBucket always fails since the in-memory client does not store files
+func (db *testDBClient) GetBucket(bucketName string) (*gridfs.Bucket, error) {
+	return nil, ErrBucketsNotSupported
+}
+
+// GetCollection returns the in-memory collection of the input name, creating it on first use
+func (db *testDBClient) GetCollection(collectionName string) DBCollection {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	col, ok := db.collections[collectionName]
+	if !ok {
+		col = &testDBCollection{name: collectionName, unique: [][]string{{"_id"}}}
+		db.collections[collectionName] = col
+	}
+	return col
+}
+
+// NewDBHandler returns a new DBHandler generic interface
+func (db *testDBClient) NewDBHandler(collectionName string) *DBRepo[DBRecord] {
+	col := db.GetCollection(collectionName)
+	return &DBRepo[DBRecord]{
+		DB:         db,
+		Collection: col,
+	}
+}
+
+// testUndo is the state of a document before a write of a transaction of the in-memory client, before is nil
+// when the write inserted the document
+type testUndo struct {
+	col    *testDBCollection
+	id     interface{}
+	before bson.D
+}
+
+// testTransaction is the undo log of a transaction of the in-memory client, recording the documents changed by
+// its writes so that only those are rolled back when it fails
+type testTransaction struct {
+	mu   sync.Mutex
+	undo []testUndo
+}
+
+// transactionFromContext returns the transaction of the in-memory client carried by a context, or nil outside of
+// transactions
+func transactionFromContext(ctx context.Context) *testTransaction {
+	tx, _ := ctx.Value(ctxUndoLog).(*testTransaction)
+	return tx
+}
+
+// record logs the state of a document before it is written, it is a no-op outside of transactions
+func (tx *testTransaction) record(col *testDBCollection, id interface{}, before bson.D) {
+	if tx == nil {
+		return
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.undo = append(tx.undo, testUndo{col: col, id: id, before: before})
+}
+
+// rollback restores the documents written by the transaction in the reverse order of its writes
+func (tx *testTransaction) rollback() {
+	tx.mu.Lock()
+	undo := tx.undo
+	tx.undo = nil
+	tx.mu.Unlock()
+	for i := len(undo) - 1; i >= 0; i-- {
+		u := undo[i]
+		u.col.mu.Lock()
+		pos := -1
+		for j, doc := range u.col.docs {
+			if id, _ := lookup(doc, "_id"); valuesEqual(id, u.id) {
+				pos = j
+				break
+			}
+		}
+		switch {
+		case u.before == nil && pos >= 0:
+			u.col.remove([]int{pos}, nil)
+		case u.before != nil && pos >= 0:
+			u.col.docs[pos] = u.before
+		case u.before != nil:
+			u.col.docs = append(u.col.docs, u.before)
+		}
+		u.col.mu.Unlock()
+	}
+}
+
+// WithTransaction runs the transactions of the in-memory client one at a time, rolling back the writes of fn when
+// it fails. Only the writes made through the context passed to fn are rolled back, like in a mongo transaction
+func (db *testDBClient) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+	return serializedTransaction(ctx, &db.txMu, func(ctx context.Context) error {
+		tx := &testTransaction{}
+		if err := fn(context.WithValue(ctx, ctxUndoLog, tx)); err != nil {
+			tx.rollback()
+			return err
+		}
+		return nil
+	})
+}
+
+// testDBCollection is an in-memory DBCollection storing its documents as normalized bson.D. It enforces the
+// unique indexes created through DBRepo.EnsureIndex but ignores the others, TTL indexes included
+type testDBCollection struct {
+	mu     sync.RWMutex
+	name   string
+	docs   []bson.D
+	unique [][]string
+}
+
+// createIndex records the keys of a unique index so that later writes are checked against it
+func (c *testDBCollection) createIndex(model mongo.IndexModel) error {
+	if model.Options == nil || model.Options.Unique == nil || !*model.Options.Unique {
+		return nil
+	}
+	keys, err := toDoc(model.Keys)
+	if err != nil {
+		return err
+	}
+	index := make([]string, 0, len(keys))
+	for _, k := range keys {
+		index = append(index, k.Key)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unique = append(c.unique, index)
+	return nil
+}
+
+// checkUnique returns a duplicate key error when a document collides with another document on a unique index,
+// skip is the position of the document being replaced or -1 for inserts
+func (c *testDBCollection) checkUnique(doc bson.D, skip int) error {
+	for _, index := range c.unique {
+		for i, other := range c.docs {
+			if i == skip {
+				continue
+			}
+			duplicate := true
+			for _, key := range index {
+				a, _ := lookup(doc, key)
+				b, _ := lookup(other, key)
+				if !valuesEqual(a, b) {
+					duplicate = false
+					break
+				}
+			}
+			if duplicate {
+				return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+					Code:    11000,
+					Message: fmt.Sprintf("E11000 duplicate key error collection: %s index: %s", c.name, strings.Join(index, "_")),
+				}}}
+			}
+		}
+	}
+	return nil
+}
+
+// insert adds a normalized document to the collection, generating its _id when missing
+func (c *testDBCollection) insert(doc bson.D, tx *testTransaction) (interface{}, error) {
+	id, ok := lookup(doc, "_id")
+	if !ok {
+		id = primitive.NewObjectID()
+		doc = append(bson.D{{Key: "_id", Value: id}}, doc...)
+	}
+	if err := c.checkUnique(doc, -1); err != nil {
+		return nil, err
+	}
+	c.docs = append(c.docs, doc)
+	tx.record(c, id, nil)
+	return id, nil
+}
+
+// matching returns the positions of the documents matching a filter, ordered by a sort specification
+func (c *testDBCollection) matching(filter interface{}, sortBy interface{}) ([]int, error) {
+	f, err := toDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := toDoc(sortBy)
+	if err != nil {
+		return nil, err
+	}
+	var found []int
+	for i, doc := range c.docs {
+		ok, err := matches(doc, f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = append(found, i)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return compareDocs(c.docs[found[i]], c.docs[found[j]], spec) < 0
+	})
+	return found, nil
+}
+
+// remove deletes the documents at the input positions
+func (c *testDBCollection) remove(positions []int, tx *testTransaction) {
+	removed := make(map[int]bool, len(positions))
+	for _, i := range positions {
+		removed[i] = true
+		id, _ := lookup(c.docs[i], "_id")
+		tx.record(c, id, c.docs[i])
+	}
+	kept := c.docs[:0]
+	for i, doc := range c.docs {
+		if !removed[i] {
+			kept = append(kept, doc)
+		}
+	}
+	c.docs = kept
+}
+
+// replace applies a normalized update to the document at the input position, returning the updated document and
+// whether it changed
+func (c *testDBCollection) replace(i int, update bson.D, tx *testTransaction) (bson.D, bool, error) {
+	updated, err := applyUpdate(cloneDoc(c.docs[i]), update, false)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = c.checkUnique(updated, i); err != nil {
+		return nil, false, err
+	}
+	if reflect.DeepEqual(c.docs[i], updated) {
+		return updated, false, nil
+	}
+	id, _ := lookup(c.docs[i], "_id")
+	tx.record(c, id, c.docs[i])
+	c.docs[i] = updated
+	return updated, true, nil
+}
+
+// upsert inserts the document described by the equality conditions of a filter with an update applied to it
+func (c *testDBCollection) upsert(filter interface{}, update bson.D, tx *testTransaction) (bson.D, interface{}, error) {
+	f, err := toDoc(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc := bson.D{}
+	for _, e := range f {
+		if strings.HasPrefix(e.Key, "$") {
+			continue
+		}
+		if !isOperatorDoc(e.Value) {
+			doc = setPath(doc, e.Key, cloneValue(e.Value))
+		} else if eq, ok := lookup(e.Value.(bson.D), "$eq"); ok {
+			doc = setPath(doc, e.Key, cloneValue(eq))
+		}
+	}
+	if doc, err = applyUpdate(doc, update, true); err != nil {
+		return nil, nil, err
+	}
+	id, err := c.insert(doc, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.docs[len(c.docs)-1], id, nil
+}
+
+// update applies an update to the first or every document matching a filter, upserting when nothing matched
+func (c *testDBCollection) update(filter interface{}, update interface{}, many bool, upsert bool, tx *testTransaction) (*mongo.UpdateResult, error) {
+	u, err := toDoc(update)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found, err := c.matching(filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := &mongo.UpdateResult{}
+	for _, i := range found {
+		_, changed, err := c.replace(i, u, tx)
+		if err != nil {
+			return res, err
+		}
+		res.MatchedCount++
+		if changed {
+			res.ModifiedCount++
+		}
+		if !many {
+			break
+		}
+	}
+	if res.MatchedCount == 0 && upsert {
+		_, id, err := c.upsert(filter, u, tx)
+		if err != nil {
+			return res, err
+		}
+		res.UpsertedCount = 1
+		res.UpsertedID = id
+	}
+	return res, nil
+}
+
+// singleResult returns a mongo.SingleResult decoding the input document, or failing with ErrNoDocuments when nil
+func singleResult(doc bson.D, err error) *mongo.SingleResult {
+	if err == nil && doc == nil {
+		err = mongo.ErrNoDocuments
+	}
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
+	}
+	return mongo.NewSingleResultFromDocument(doc, nil, nil)
+}
+
+// InsertOne adds a document to the collection
+func (c *testDBCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	doc, err := toDoc(document)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, err := c.insert(doc, transactionFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+// InsertMany adds documents to the collection in order, stopping at the first failure
+func (c *testDBCollection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := &mongo.InsertManyResult{}
+	for _, document := range documents {
+		doc, err := toDoc(document)
+		if err != nil {
+			return res, err
+		}
+		id, err := c.insert(doc, transactionFromContext(ctx))
+		if err != nil {
+			return res, err
+		}
+		res.InsertedIDs = append(res.InsertedIDs, id)
+	}
+	return res, nil
+}
+
+// DeleteOne removes the first document matching a filter
+func (c *testDBCollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found, err := c.matching(filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) > 1 {
+		found = found[:1]
+	}
+	c.remove(found, transactionFromContext(ctx))
+	return &mongo.DeleteResult{DeletedCount: int64(len(found))}, nil
+}
+
+// DeleteMany removes every document matching a filter
+func (c *testDBCollection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found, err := c.matching(filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.remove(found, transactionFromContext(ctx))
+	return &mongo.DeleteResult{DeletedCount: int64(len(found))}, nil
+}
+
+// FindOneAndDelete removes the first document matching a filter in the order of the sort option, returning it
+func (c *testDBCollection) FindOneAndDelete(ctx context.Context, filter interface{}, opts ...*options.FindOneAndDeleteOptions) *mongo.SingleResult {
+	o := options.MergeFindOneAndDeleteOptions(opts...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found, err := c.matching(filter, o.Sort)
+	if err != nil || len(found) == 0 {
+		return singleResult(nil, err)
+	}
+	doc := c.docs[found[0]]
+	c.remove(found[:1], transactionFromContext(ctx))
+	return singleResult(doc, nil)
+}
+
+// FindOneAndUpdate updates the first document matching a filter in the order of the sort option, returning it as
+// it was before the update unless the ReturnDocument option is After
+func (c *testDBCollection) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	o := options.MergeFindOneAndUpdateOptions(opts...)
+	after := o.ReturnDocument != nil && *o.ReturnDocument == options.After
+	u, err := toDoc(update)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found, err := c.matching(filter, o.Sort)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	if len(found) == 0 {
+		if o.Upsert == nil || !*o.Upsert {
+			return singleResult(nil, nil)
+		}
+		doc, _, err := c.upsert(filter, u, transactionFromContext(ctx))
+		if err != nil || !after {
+			return singleResult(nil, err)
+		}
+		return singleResult(doc, nil)
+	}
+	before := c.docs[found[0]]
+	updated, _, err := c.replace(found[0], u, transactionFromContext(ctx))
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	if after {
+		return singleResult(updated, nil)
+	}
+	return singleResult(before, nil)
+}
+
+// UpdateOne updates the first document matching a filter
+func (c *testDBCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	o := options.MergeUpdateOptions(opts...)
+	return c.update(filter, update, false, o.Upsert != nil && *o.Upsert, transactionFromContext(ctx))
+}
+
+// UpdateMany updates every document matching a filter
+func (c *testDBCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	o := options.MergeUpdateOptions(opts...)
+	return c.update(filter, update, true, o.Upsert != nil && *o.Upsert, transactionFromContext(ctx))
+}
+
+// UpdateByID updates the document of the input _id
+func (c *testDBCollection) UpdateByID(ctx context.Context, id interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return c.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update, opts...)
+}
+
+// Find returns a cursor over the documents matching a filter, honoring the sort, skip and limit options
+func (c *testDBCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (cur *mongo.Cursor, err error) {
+	o := options.MergeFindOptions(opts...)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	found, err := c.matching(filter, o.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if o.Skip != nil && *o.Skip > 0 {
+		if *o.Skip >= int64(len(found)) {
+			found = nil
+		} else {
+			found = found[*o.Skip:]
+		}
+	}
+	if o.Limit != nil && *o.Limit != 0 {
+		limit := *o.Limit
+		if limit < 0 {
+			limit = -limit
+		}
+		if limit < int64(len(found)) {
+			found = found[:limit]
+		}
+	}
+	docs := make([]interface{}, len(found))
+	for j, i := range found {
+		docs[j] = c.docs[i]
+	}
+	return mongo.NewCursorFromDocuments(docs, nil, nil)
+}
+
+// FindOne returns the first document matching a filter, honoring the sort and skip options
+func (c *testDBCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	o := options.MergeFindOneOptions(opts...)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	found, err := c.matching(filter, o.Sort)
+	if err != nil {
+		return singleResult(nil, err)
+	}
+	skip := int64(0)
+	if o.Skip != nil {
+		skip = *o.Skip
+	}
+	if skip >= int64(len(found)) {
+		return singleResult(nil, nil)
+	}
+	return singleResult(c.docs[found[skip]], nil)
+}
+
+// CountDocuments counts the documents matching a filter, honoring the skip and limit options
+func (c *testDBCollection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	o := options.MergeCountOptions(opts...)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	found, err := c.matching(filter, nil)
+	if err != nil {
+		return 0, err
+	}
+	count := int64(len(found))
+	if o.Skip != nil {
+		count -= *o.Skip
+	}
+	if o.Limit != nil && *o.Limit > 0 && *o.Limit < count {
+		count = *o.Limit
+	}
+	if count < 0 {
+		count = 0
+	}
+	return count, nil
+}
@@ -0,0 +1,130 @@
+package databases
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"testing"
+	"time"
+)
+
+// newTestCollection returns an in-memory collection holding the input documents
+func newTestCollection(t *testing.T, docs ...bson.D) *testDBCollection {
+	t.Helper()
+	client, _ := initializeNewTestClient()
+	col := client.GetCollection("tests").(*testDBCollection)
+	for _, doc := range docs {
+		if _, err := col.InsertOne(context.Background(), doc); err != nil {
+			t.Fatalf("InsertOne() error = %v", err)
+		}
+	}
+	return col
+}
+
+func TestTestDBCollection_Find(t *testing.T) {
+	now := time.Now().UTC()
+	col := newTestCollection(t,
+		bson.D{{Key: "name", Value: "ann"}, {Key: "age", Value: 31}, {Key: "tags", Value: bson.A{"a", "b"}}, {Key: "created_at", Value: now}},
+		bson.D{{Key: "name", Value: "bob"}, {Key: "age", Value: int64(25)}, {Key: "deleted_at", Value: now}},
+		bson.D{{Key: "name", Value: "cid"}, {Key: "age", Value: 40}, {Key: "address", Value: bson.D{{Key: "city", Value: "oslo"}}}},
+	)
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name   string               // The name of the test
+		filter interface{}          // The filter of the query
+		opts   *options.FindOptions // The options of the query
+		want   []string             // The names of the documents we want in order
+	}{
+		{"empty filter", bson.M{}, nil, []string{"ann", "bob", "cid"}},
+		{"equality", bson.D{{Key: "name", Value: "bob"}}, nil, []string{"bob"}},
+		{"mixed number types", bson.D{{Key: "age", Value: 25}}, nil, []string{"bob"}},
+		{"null matches missing", bson.D{{Key: "deleted_at", Value: nil}}, nil, []string{"ann", "cid"}},
+		{"array contains", bson.D{{Key: "tags", Value: "b"}}, nil, []string{"ann"}},
+		{"dotted path", bson.D{{Key: "address.city", Value: "oslo"}}, nil, []string{"cid"}},
+		{"$in", bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{"ann", "cid"}}}}}, nil, []string{"ann", "cid"}},
+		{"$gt and $lte", bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: 25}, {Key: "$lte", Value: 40}}}}, nil, []string{"ann", "cid"}},
+		{"$lt on times", bson.D{{Key: "created_at", Value: bson.D{{Key: "$lt", Value: now.Add(time.Second)}}}}, nil, []string{"ann"}},
+		{"$exists", bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: true}}}}, nil, []string{"bob"}},
+		{"$ne null", bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}}}, nil, []string{"bob"}},
+		{"$or", bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "name", Value: "ann"}}, bson.D{{Key: "age", Value: 40}}}}}, nil, []string{"ann", "cid"}},
+		{"$and", bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "age", Value: bson.D{{Key: "$gte", Value: 25}}}}, bson.D{{Key: "name", Value: "bob"}}}}}, nil, []string{"bob"}},
+		{"sort descending", bson.M{}, options.Find().SetSort(bson.D{{Key: "age", Value: -1}}), []string{"cid", "ann", "bob"}},
+		{"skip and limit", bson.M{}, options.Find().SetSort(bson.D{{Key: "age", Value: 1}}).SetSkip(1).SetLimit(1), []string{"ann"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []*options.FindOptions{}
+			if tt.opts != nil {
+				opts = append(opts, tt.opts)
+			}
+			cur, err := col.Find(context.Background(), tt.filter, opts...)
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			var got []bson.M
+			if err = cur.All(context.Background(), &got); err != nil {
+				t.Fatalf("All() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Find() returned %d documents, want %d", len(got), len(tt.want))
+			}
+			for i, doc := range got {
+				if doc["name"] != tt.want[i] {
+					t.Errorf("Find()[%d] = %v, want %v", i, doc["name"], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTestDBCollection_Update(t *testing.T) {
+	ctx := context.Background()
+	col := newTestCollection(t, bson.D{{Key: "key", Value: "a"}, {Key: "failures", Value: 1}, {Key: "codes", Value: bson.A{"x", "y"}}})
+	res, err := col.UpdateOne(ctx, bson.D{{Key: "key", Value: "a"}}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "failures", Value: 1}}},
+		{Key: "$pull", Value: bson.D{{Key: "codes", Value: "x"}}},
+		{Key: "$unset", Value: bson.D{{Key: "missing", Value: ""}}},
+	})
+	if err != nil || res.MatchedCount != 1 || res.ModifiedCount != 1 {
+		t.Fatalf("UpdateOne() = %+v, %v", res, err)
+	}
+	var doc bson.M
+	if err = col.FindOne(ctx, bson.D{{Key: "key", Value: "a"}}).Decode(&doc); err != nil {
+		t.Fatalf("FindOne() error = %v", err)
+	}
+	if doc["failures"] != int32(2) || len(doc["codes"].(bson.A)) != 1 {
+		t.Errorf("updated document = %v", doc)
+	}
+	res, err = col.UpdateOne(ctx, bson.D{{Key: "key", Value: "b"}}, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "failures", Value: 1}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "created", Value: true}}},
+	}, options.Update().SetUpsert(true))
+	if err != nil || res.UpsertedCount != 1 {
+		t.Fatalf("UpdateOne() upsert = %+v, %v", res, err)
+	}
+	if err = col.FindOneAndUpdate(ctx, bson.D{{Key: "key", Value: "b"}}, bson.D{{Key: "$set", Value: bson.D{{Key: "failures", Value: 5}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&doc); err != nil || doc["failures"] != int32(5) || doc["created"] != true {
+		t.Errorf("FindOneAndUpdate() = %v, %v", doc, err)
+	}
+	if err = col.FindOneAndDelete(ctx, bson.D{{Key: "key", Value: "b"}}).Decode(&doc); err != nil || doc["key"] != "b" {
+		t.Errorf("FindOneAndDelete() = %v, %v", doc, err)
+	}
+	if err = col.FindOne(ctx, bson.D{{Key: "key", Value: "b"}}).Decode(&doc); err != mongo.ErrNoDocuments {
+		t.Errorf("FindOne() after delete error = %v, want %v", err, mongo.ErrNoDocuments)
+	}
+}
+
+func TestTestDBCollection_UniqueIndex(t *testing.T) {
+	col := newTestCollection(t, bson.D{{Key: "key", Value: "a"}})
+	repo := &DBRepo[DBRecord]{Collection: col}
+	if err := repo.EnsureIndex(mongo.IndexModel{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)}); err != nil {
+		t.Fatalf("EnsureIndex() error = %v", err)
+	}
+	if _, err := col.InsertOne(context.Background(), bson.D{{Key: "key", Value: "a"}}); !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("InsertOne() error = %v, want a duplicate key error", err)
+	}
+	if _, err := col.InsertOne(context.Background(), bson.D{{Key: "key", Value: "b"}}); err != nil {
+		t.Errorf("InsertOne() error = %v", err)
+	}
+}
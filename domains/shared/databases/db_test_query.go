@@ -0,0 +1,461 @@
+package databases
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// cloneValue deep copies the documents and arrays of a normalized value
+func cloneValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case bson.D:
+		return cloneDoc(t)
+	case bson.A:
+		a := make(bson.A, len(t))
+		for i, e := range t {
+			a[i] = cloneValue(e)
+		}
+		return a
+	}
+	return v
+}
+
+// cloneDoc deep copies a normalized document
+func cloneDoc(doc bson.D) bson.D {
+	c := make(bson.D, len(doc))
+	for i, e := range doc {
+		c[i] = bson.E{Key: e.Key, Value: cloneValue(e.Value)}
+	}
+	return c
+}
+
+// isOperatorDoc returns whether a value is a document of query or update operators such as $gt or $set
+func isOperatorDoc(v interface{}) bool {
+	d, ok := v.(bson.D)
+	return ok && len(d) > 0 && strings.HasPrefix(d[0].Key, "$")
+}
+
+// lookup returns the value of a field of a document by its dotted path
+func lookup(doc bson.D, path string) (interface{}, bool) {
+	key, rest, nested := strings.Cut(path, ".")
+	for _, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return e.Value, true
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			return lookup(sub, rest)
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// setPath sets the value of a field of a document by its dotted path, creating the missing nested documents
+func setPath(doc bson.D, path string, value interface{}) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if nested {
+			sub, _ := e.Value.(bson.D)
+			doc[i].Value = setPath(sub, rest, value)
+		} else {
+			doc[i].Value = value
+		}
+		return doc
+	}
+	if nested {
+		return append(doc, bson.E{Key: key, Value: setPath(bson.D{}, rest, value)})
+	}
+	return append(doc, bson.E{Key: key, Value: value})
+}
+
+// unsetPath removes a field of a document by its dotted path
+func unsetPath(doc bson.D, path string) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return append(doc[:i], doc[i+1:]...)
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			doc[i].Value = unsetPath(sub, rest)
+		}
+		return doc
+	}
+	return doc
+}
+
+// toFloat returns the value of a bson number
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// compareValues orders two values of the same bson type, returning false when they cannot be compared
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, a == nil && b == nil
+	}
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case primitive.DateTime:
+		if y, ok := b.(primitive.DateTime); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(x[:], y[:]), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case y:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// valuesEqual returns whether two normalized values are equal, numbers of different types included
+func valuesEqual(a interface{}, b interface{}) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// equals returns whether a field value matches an equality condition, a null condition matches missing fields
+// and a scalar condition matches arrays containing it
+func equals(value interface{}, exists bool, target interface{}) bool {
+	if target == nil {
+		return !exists || value == nil
+	}
+	if !exists {
+		return false
+	}
+	if arr, ok := value.(bson.A); ok {
+		if _, isArr := target.(bson.A); !isArr {
+			for _, v := range arr {
+				if valuesEqual(v, target) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return valuesEqual(value, target)
+}
+
+// compares returns whether a field value satisfies a comparison, arrays satisfy it when one of their elements does
+func compares(value interface{}, exists bool, target interface{}, accept func(int) bool) bool {
+	if !exists {
+		return false
+	}
+	if arr, ok := value.(bson.A); ok {
+		for _, v := range arr {
+			if c, ok := compareValues(v, target); ok && accept(c) {
+				return true
+			}
+		}
+		return false
+	}
+	c, ok := compareValues(value, target)
+	return ok && accept(c)
+}
+
+// matchOperator evaluates a query operator such as $in or $gt against a field value
+func matchOperator(value interface{}, exists bool, op string, arg interface{}) (bool, error) {
+	switch op {
+	case "$eq":
+		return equals(value, exists, arg), nil
+	case "$ne":
+		return !equals(value, exists, arg), nil
+	case "$gt":
+		return compares(value, exists, arg, func(c int) bool { return c > 0 }), nil
+	case "$gte":
+		return compares(value, exists, arg, func(c int) bool { return c >= 0 }), nil
+	case "$lt":
+		return compares(value, exists, arg, func(c int) bool { return c < 0 }), nil
+	case "$lte":
+		return compares(value, exists, arg, func(c int) bool { return c <= 0 }), nil
+	case "$in", "$nin":
+		arr, ok := arg.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("%s needs an array", op)
+		}
+		found := false
+		for _, a := range arr {
+			if equals(value, exists, a) {
+				found = true
+				break
+			}
+		}
+		return found == (op == "$in"), nil
+	case "$regex":
+		pattern, ok := arg.(string)
+		if !ok {
+			return false, errors.New("$regex needs a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		s, isString := value.(string)
+		return exists && isString && re.MatchString(s), nil
+	case "$exists":
+		want, ok := arg.(bool)
+		if !ok {
+			n, _ := toFloat(arg)
+			want = n != 0
+		}
+		return exists == want, nil
+	}
+	return false, fmt.Errorf("unsupported query operator %s", op)
+}
+
+// matchField evaluates the condition on a field of a filter against a document
+func matchField(doc bson.D, path string, cond interface{}) (bool, error) {
+	value, exists := lookup(doc, path)
+	if !isOperatorDoc(cond) {
+		return equals(value, exists, cond), nil
+	}
+	ops := cond.(bson.D)
+	for _, op := range ops {
+		arg := op.Value
+		switch op.Key {
+		case "$options":
+			// applied along with $regex
+			continue
+		case "$regex":
+			if options, ok := lookup(ops, "$options"); ok && strings.Contains(fmt.Sprint(options), "i") {
+				arg = "(?i)" + fmt.Sprint(arg)
+			}
+		}
+		ok, err := matchOperator(value, exists, op.Key, arg)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matches returns whether a document satisfies a normalized filter, supporting field equality, the comparison
+// operators along with $in, $nin, $regex and $exists, and the $and, $or and $nor logical operators
+func matches(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			clauses, ok := e.Value.(bson.A)
+			if !ok {
+				return false, fmt.Errorf("%s needs an array", e.Key)
+			}
+			matched := 0
+			for _, clause := range clauses {
+				sub, ok := clause.(bson.D)
+				if !ok {
+					return false, fmt.Errorf("%s needs an array of documents", e.Key)
+				}
+				ok, err := matches(doc, sub)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					matched++
+				}
+			}
+			if (e.Key == "$and" && matched != len(clauses)) || (e.Key == "$or" && matched == 0) || (e.Key == "$nor" && matched > 0) {
+				return false, nil
+			}
+		default:
+			if strings.HasPrefix(e.Key, "$") {
+				return false, fmt.Errorf("unsupported query operator %s", e.Key)
+			}
+			ok, err := matchField(doc, e.Key, e.Value)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// addNumbers adds the bson numbers of an $inc update, keeping int32 sums of int32s and int64 sums of integers
+func addNumbers(a interface{}, b interface{}) (interface{}, error) {
+	x, okA := toFloat(a)
+	y, okB := toFloat(b)
+	if !okA || !okB {
+		return nil, errors.New("cannot $inc a non numeric field")
+	}
+	_, aFloat := a.(float64)
+	_, bFloat := b.(float64)
+	if aFloat || bFloat {
+		return x + y, nil
+	}
+	a32, aInt32 := a.(int32)
+	b32, bInt32 := b.(int32)
+	if aInt32 && bInt32 {
+		return a32 + b32, nil
+	}
+	return toInt64(a) + toInt64(b), nil
+}
+
+// toInt64 returns the value of a bson integer
+func toInt64(v interface{}) int64 {
+	if n, ok := v.(int32); ok {
+		return int64(n)
+	}
+	n, _ := v.(int64)
+	return n
+}
+
+// applyUpdate applies the $set, $unset, $inc, $push, $pull and $setOnInsert operators of a normalized update to
+// a document, $setOnInsert is only applied to the documents inserted by an upsert
+func applyUpdate(doc bson.D, update bson.D, insert bool) (bson.D, error) {
+	if !isOperatorDoc(update) {
+		return doc, errors.New("update document must only contain update operators")
+	}
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return doc, fmt.Errorf("%s needs a document", op.Key)
+		}
+		for _, f := range fields {
+			switch op.Key {
+			case "$set":
+				doc = setPath(doc, f.Key, f.Value)
+			case "$setOnInsert":
+				if insert {
+					doc = setPath(doc, f.Key, f.Value)
+				}
+			case "$unset":
+				doc = unsetPath(doc, f.Key)
+			case "$inc":
+				current, exists := lookup(doc, f.Key)
+				if !exists {
+					doc = setPath(doc, f.Key, f.Value)
+					continue
+				}
+				sum, err := addNumbers(current, f.Value)
+				if err != nil {
+					return doc, err
+				}
+				doc = setPath(doc, f.Key, sum)
+			case "$push":
+				current, _ := lookup(doc, f.Key)
+				arr, _ := current.(bson.A)
+				doc = setPath(doc, f.Key, append(arr, f.Value))
+			case "$pull":
+				current, exists := lookup(doc, f.Key)
+				arr, ok := current.(bson.A)
+				if !exists || !ok {
+					continue
+				}
+				kept := make(bson.A, 0, len(arr))
+				for _, v := range arr {
+					pulled, err := matchField(bson.D{{Key: "v", Value: v}}, "v", f.Value)
+					if err != nil {
+						return doc, err
+					}
+					if !pulled {
+						kept = append(kept, v)
+					}
+				}
+				doc = setPath(doc, f.Key, kept)
+			default:
+				return doc, fmt.Errorf("unsupported update operator %s", op.Key)
+			}
+		}
+	}
+	return doc, nil
+}
+
+// typeRank orders values of different bson types the way mongo sorts them
+func typeRank(v interface{}, exists bool) int {
+	if !exists || v == nil {
+		return 1
+	}
+	if _, ok := toFloat(v); ok {
+		return 2
+	}
+	switch v.(type) {
+	case string:
+		return 3
+	case bson.D:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	}
+	return 10
+}
+
+// compareDocs orders two documents by a normalized sort specification of fields mapped to 1 or -1
+func compareDocs(a bson.D, b bson.D, spec bson.D) int {
+	for _, s := range spec {
+		x, xOk := lookup(a, s.Key)
+		y, yOk := lookup(b, s.Key)
+		c := typeRank(x, xOk) - typeRank(y, yOk)
+		if c == 0 {
+			c, _ = compareValues(x, y)
+		}
+		if c == 0 {
+			continue
+		}
+		if direction, _ := toFloat(s.Value); direction < 0 {
+			return -c
+		}
+		return c
+	}
+	return 0
+}
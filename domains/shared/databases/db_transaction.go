@@ -0,0 +1,24 @@
+package databases
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sync"
+)
+
+// InTransaction returns whether a context carries a transaction started by DBClient.WithTransaction
+func InTransaction(ctx context.Context) bool {
+	if mongo.SessionFromContext(ctx) != nil {
+		return true
+	}
+	inTx, _ := ctx.Value(ctxTransaction).(bool)
+	return inTx
+}
+
+// serializedTransaction runs fn while holding mu, so that the transactions of a client without multi-document
+// transaction support run one at a time. The writes of fn are not rolled back when it fails
+func serializedTransaction(ctx context.Context, mu *sync.Mutex, fn func(ctx context.Context) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return fn(context.WithValue(ctx, ctxTransaction, true))
+}
@@ -0,0 +1,114 @@
+package databases
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestTestDBClient_WithTransaction(t *testing.T) {
+	errFailed := errors.New("failed")
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name    string // The name of the test
+		fail    bool   // whether the transaction fails after its writes
+		nested  bool   // whether the second write runs in a nested WithTransaction
+		want    int64  // The number of records we want once the transaction returns
+		wantErr error  // The error we want WithTransaction to return
+	}{
+		{"commit", false, false, 3, nil},
+		{"rollback", true, false, 1, errFailed},
+		{"nested commit", false, true, 3, nil},
+		{"nested rollback", true, true, 1, errFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := initializeNewTestClient()
+			repo := &DBRepo[*cursorTestRecord]{DB: client, Collection: client.GetCollection("orders")}
+			if _, err := repo.InsertOne(&cursorTestRecord{Name: "existing"}); err != nil {
+				t.Fatalf("InsertOne() error = %v", err)
+			}
+			err := client.WithTransaction(context.Background(), func(ctx context.Context) error {
+				if !InTransaction(ctx) {
+					t.Errorf("InTransaction() = false, want true")
+				}
+				tx := repo.WithContext(ctx)
+				if _, err := tx.InsertOne(&cursorTestRecord{Name: "order"}); err != nil {
+					return err
+				}
+				insert := func(ctx context.Context) error {
+					_, err := repo.WithContext(ctx).InsertOne(&cursorTestRecord{Name: "payment"})
+					return err
+				}
+				if tt.nested {
+					if err := client.WithTransaction(ctx, insert); err != nil {
+						return err
+					}
+				} else if err := insert(ctx); err != nil {
+					return err
+				}
+				if tt.fail {
+					return errFailed
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithTransaction() error = %v, want %v", err, tt.wantErr)
+			}
+			if got, _ := repo.Count(&cursorTestRecord{}); got != tt.want {
+				t.Errorf("Count() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	if InTransaction(context.Background()) {
+		t.Errorf("InTransaction() = true, want false")
+	}
+}
+
+func TestTestDBClient_WithTransactionRollback(t *testing.T) {
+	errFailed := errors.New("failed")
+	client, _ := initializeNewTestClient()
+	col := client.GetCollection("orders")
+	insert := func(ctx context.Context, name string) interface{} {
+		res, err := col.InsertOne(ctx, bson.D{{Key: "name", Value: name}})
+		if err != nil {
+			t.Fatalf("InsertOne() error = %v", err)
+		}
+		return res.InsertedID
+	}
+	updated, deleted := insert(context.Background(), "updated"), insert(context.Background(), "deleted")
+	err := client.WithTransaction(context.Background(), func(ctx context.Context) error {
+		insert(ctx, "inserted")
+		if _, err := col.UpdateByID(ctx, updated, bson.D{{Key: "$set", Value: bson.D{{Key: "rank", Value: 1}}}}); err != nil {
+			return err
+		}
+		if _, err := col.DeleteOne(ctx, bson.D{{Key: "_id", Value: deleted}}); err != nil {
+			return err
+		}
+		// a write made outside of the transaction while it runs is not rolled back with it
+		insert(context.Background(), "outside")
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("WithTransaction() error = %v, want %v", err, errFailed)
+	}
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name   string // The name of the test
+		filter bson.D // The filter of the checked documents
+		want   int64  // The number of documents we want to match the filter
+	}{
+		{"insert rolled back", bson.D{{Key: "name", Value: "inserted"}}, 0},
+		{"update rolled back", bson.D{{Key: "_id", Value: updated}, {Key: "rank", Value: bson.D{{Key: "$exists", Value: false}}}}, 1},
+		{"delete rolled back", bson.D{{Key: "_id", Value: deleted}}, 1},
+		{"outside write kept", bson.D{{Key: "name", Value: "outside"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := col.CountDocuments(context.Background(), tt.filter); err != nil || got != tt.want {
+				t.Errorf("CountDocuments() = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
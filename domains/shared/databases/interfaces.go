@@ -20,6 +20,7 @@ type DBRecord interface {
 	GetID() (id interface{})
 	Update(doc interface{}) (err error)
 	Match(doc interface{}) bool
+	SortFields() []string
 }
 
 // DBCollection is an abstraction of the dbClient and testDBClient types
@@ -28,7 +29,9 @@ type DBCollection interface {
 	InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error)
 	DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
 	FindOneAndDelete(ctx context.Context, filter interface{}, opts ...*options.FindOneAndDeleteOptions) *mongo.SingleResult
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
 	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 	UpdateByID(ctx context.Context, id interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
 	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (cur *mongo.Cursor, err error)
 	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult
@@ -43,6 +46,7 @@ type DBClient interface {
 	GetBucket(bucketName string) (*gridfs.Bucket, error)
 	GetCollection(collectionName string) DBCollection
 	NewDBHandler(collectionName string) *DBRepo[DBRecord]
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	// NewUserHandler() *DBHandler[*userModel]
 	// NewGroupHandler() *DBHandler[*groupModel]
 	// NewBlacklistHandler() *DBHandler[*blacklistModel]
@@ -58,10 +62,8 @@ type DBCursor interface {
 	Err() error
 }
 
-// checkCursorENV returns a DBCursor based on the ENV
+// checkCursorENV returns a DBCursor based on the ENV, the in-memory test collections return mongo cursors
+// preloaded with their documents so both ENVs share the same DBCursor
 func checkCursorENV(cur *mongo.Cursor) DBCursor {
-	// if os.Getenv("ENV") == "test" {
-	//  	return newTestMongoCursor(cur)
-	// }
 	return cur
 }
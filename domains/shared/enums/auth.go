@@ -45,3 +45,17 @@ func (r Role) Stringify() string {
 func (r Role) EnumIndex() int {
 	return int(r)
 }
+
+// RoleFromString converts a string value into a Role enum, returning 0 for an unknown role
+func RoleFromString(inStr string) Role {
+	switch inStr {
+	case "MEMBER":
+		return MEMBER
+	case "ADMIN":
+		return ADMIN
+	case "ROOT":
+		return ROOT
+	default:
+		return 0
+	}
+}
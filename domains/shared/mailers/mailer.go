@@ -0,0 +1,68 @@
+package mailers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is an email to be dispatched by a Mailer
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is an abstraction of the services used to deliver emails
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewMailer returns the Mailer configured by the mailer setting
+func NewMailer() (Mailer, error) {
+	switch viper.GetString("mailer") {
+	case "", "log":
+		return NewLogMailer(viper.GetString("mailer_log_file")), nil
+	default:
+		return nil, fmt.Errorf("unsupported mailer: %s", viper.GetString("mailer"))
+	}
+}
+
+// LogMailer is a Mailer for local use that writes messages to a file, or to the log when no file is set
+type LogMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLogMailer initializes a new LogMailer appending messages to the file at path
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+// Send writes a Message to the LogMailer's file or log
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	if msg.To == "" {
+		return errors.New("message has no recipient")
+	}
+	entry := fmt.Sprintf("To: %s\nSubject: %s\nDate: %s\n\n%s\n", msg.To, msg.Subject, time.Now().UTC().Format(time.RFC1123Z), msg.Body)
+	if m.path == "" {
+		log.Printf("mailer:\n%s", entry)
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteString(entry + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,5 +1,7 @@
 package routers
 
+import "github.com/JECSand/eventit-server/domains/shared/utilities"
+
 // JsonErr structures a standard error to return
 type JsonErr struct {
 	Code int    `json:"code"`
@@ -10,3 +12,9 @@ type JsonErr struct {
 type JWTError struct {
 	Message string `json:"message"`
 }
+
+// ValidationError is a struct that is used to contain a json encoded error message along with the field errors causing it
+type ValidationError struct {
+	Message string                 `json:"message"`
+	Errors  []utilities.FieldError `json:"errors"`
+}
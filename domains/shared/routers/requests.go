@@ -0,0 +1,34 @@
+package routers
+
+import (
+	"github.com/spf13/viper"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the ip address of the client that sent a request. The X-Forwarded-For header is only trusted
+// when trust_proxy_headers is enabled, and then only the entries appended by the trust_proxy_hops proxies in front
+// of the server: clients can prepend anything to it, so the client ip is read that many entries from the right
+func ClientIP(r *http.Request) string {
+	if viper.GetBool("trust_proxy_headers") {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(strings.Join(forwarded, ","), ",")
+			hops := viper.GetInt("trust_proxy_hops")
+			if hops < 1 {
+				hops = 1
+			}
+			if hops > len(entries) {
+				hops = len(entries)
+			}
+			if ip := strings.TrimSpace(entries[len(entries)-hops]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,44 @@
+package routers
+
+import (
+	"github.com/spf13/viper"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set("trust_proxy_headers", false)
+		viper.Set("trust_proxy_hops", 0)
+	})
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name      string   // The name of the test
+		trusted   bool     // Whether trust_proxy_headers is enabled
+		hops      int      // The configured trust_proxy_hops
+		forwarded []string // The X-Forwarded-For headers of the request
+		want      string   // The client ip we want
+	}{
+		{"untrusted header", false, 1, []string{"198.51.100.1"}, "192.0.2.1"},
+		{"no header", true, 1, nil, "192.0.2.1"},
+		{"single proxy", true, 1, []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entry", true, 1, []string{"10.0.0.1, 198.51.100.1"}, "198.51.100.1"},
+		{"spoofed header line", true, 1, []string{"10.0.0.1", "198.51.100.1"}, "198.51.100.1"},
+		{"two proxies", true, 2, []string{"10.0.0.1, 198.51.100.1, 203.0.113.9"}, "198.51.100.1"},
+		{"fewer entries than hops", true, 3, []string{"198.51.100.1, 203.0.113.9"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("trust_proxy_headers", tt.trusted)
+			viper.Set("trust_proxy_hops", tt.hops)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,13 @@ package routers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
+// maxBodyBytes caps the size of a decoded json request body
+const maxBodyBytes = 1 << 20
+
 // HandleOptionsRequest handles incoming OPTIONS request
 func HandleOptionsRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -42,3 +46,19 @@ func RespondWithError(w http.ResponseWriter, status int, error JWTError) {
 		panic(err)
 	}
 }
+
+// RespondWithJSON writes the input status code and json encoded data to the response
+func RespondWithJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		panic(err)
+	}
+}
+
+// DecodeJSONBody decodes the json encoded request body into the input value
+func DecodeJSONBody(r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return errors.New("request body is empty")
+	}
+	return json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes)).Decode(v)
+}
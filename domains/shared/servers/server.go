@@ -0,0 +1,109 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"github.com/JECSand/eventit-server/domains/shared/databases"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Job is a background task run periodically while the Server is running
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Server provides an http.Server along with the DBClient it serves from
+type Server struct {
+	*http.Server
+	db   databases.DBClient
+	jobs []Job
+}
+
+// NewServer creates and configures a Server serving the input handler on the input port
+func NewServer(port string, handler http.Handler, db databases.DBClient) *Server {
+	var addr string
+	// allow port to be set as localhost:3000 during development
+	if strings.Contains(port, ":") {
+		addr = port
+	} else {
+		addr = ":" + port
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return &Server{srv, db, nil}
+}
+
+// AddJob schedules a Job to run every Job.Interval from the time the Server starts until it shuts down, a Job
+// without a positive interval is disabled
+func (srv *Server) AddJob(job Job) {
+	if job.Interval <= 0 {
+		log.Printf("job %s is disabled", job.Name)
+		return
+	}
+	srv.jobs = append(srv.jobs, job)
+}
+
+// runJob runs a Job on every tick of its interval until the context is cancelled, logging its failures
+func runJob(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job.Run(ctx); err != nil {
+				log.Printf("job %s failed: %v", job.Name, err)
+			}
+		}
+	}
+}
+
+// Start runs ListenAndServe on the http.Server with graceful shutdown on SIGINT/SIGTERM
+func (srv *Server) Start() error {
+	log.Println("starting server...")
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	for _, job := range srv.jobs {
+		go runJob(jobsCtx, job)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+	log.Printf("Listening on %s\n", srv.Addr)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	select {
+	case err := <-errCh:
+		srv.db.Close()
+		return err
+	case sig := <-quit:
+		log.Println("Shutting down server... Reason:", sig)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.db.Close()
+		return err
+	}
+	if err := srv.db.Close(); err != nil {
+		return err
+	}
+	log.Println("Server gracefully stopped")
+	return nil
+}
@@ -0,0 +1,72 @@
+package utilities
+
+import (
+	"sync"
+	"time"
+)
+
+// cacheEntry stores a cached value along with the time it expires
+type cacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTLCache is a concurrency safe in-process cache whose entries expire after a per entry TTL
+type TTLCache[V any] struct {
+	mu         sync.RWMutex
+	entries    map[string]cacheEntry[V]
+	maxEntries int
+}
+
+// NewTTLCache initializes a new TTLCache holding at most maxEntries entries
+func NewTTLCache[V any](maxEntries int) *TTLCache[V] {
+	return &TTLCache[V]{
+		entries:    make(map[string]cacheEntry[V]),
+		maxEntries: maxEntries,
+	}
+}
+
+// Get returns the cached value of a key and whether it was found and not yet expired
+func (c *TTLCache[V]) Get(key string) (V, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		var v V
+		return v, false
+	}
+	return entry.value, true
+}
+
+// Set caches the value of a key until the input TTL has passed
+func (c *TTLCache[V]) Set(key string, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		c.sweep()
+	}
+	c.entries[key] = cacheEntry[V]{value, time.Now().Add(ttl)}
+}
+
+// Delete removes a key from the cache
+func (c *TTLCache[V]) Delete(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// sweep drops expired entries, falling back to clearing the cache when it is still full
+func (c *TTLCache[V]) sweep() {
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		c.entries = make(map[string]cacheEntry[V])
+	}
+}
@@ -1,26 +1,95 @@
 package utilities
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 const (
 	defaultSize = 10
+	defaultPage = 1
+	maxSize     = 100
 )
 
-// Pagination query params
+var (
+	// ErrInvalidOrderBy is returned when an orderBy param is malformed or orders by a field that cannot be sorted on
+	ErrInvalidOrderBy = errors.New("invalid orderBy")
+	// ErrInvalidCursor is returned when a pagination cursor is malformed, forged or was issued for another orderBy
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+)
+
+// orderByFieldPattern matches the field names of an orderBy param
+var orderByFieldPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// SortField is a field of an orderBy param along with its direction
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ParseOrderBy parses an orderBy param of comma separated field names into SortFields, a field prefixed by - is
+// sorted in descending order, e.g. -created_at,email
+func ParseOrderBy(orderBy string) ([]SortField, error) {
+	fields := make([]SortField, 0)
+	if orderBy == "" {
+		return fields, nil
+	}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(orderBy, ",") {
+		part = strings.TrimSpace(part)
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Descending: strings.HasPrefix(part, "-")}
+		if !orderByFieldPattern.MatchString(field.Field) {
+			return nil, fmt.Errorf("%w: malformed field %q", ErrInvalidOrderBy, part)
+		}
+		if seen[field.Field] {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrInvalidOrderBy, field.Field)
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Pagination query params, pages are selected by number unless a cursor param is sent. Cursor pagination starts
+// with an empty cursor param and continues with the next_cursor or prev_cursor of the previous page
 type Pagination struct {
-	Size    int    `json:"size,omitempty"`
-	Page    int    `json:"page,omitempty"`
-	OrderBy string `json:"orderBy,omitempty"`
+	Size       int    `json:"size,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	OrderBy    string `json:"orderBy,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+	CursorMode bool   `json:"-"`
 }
 
 func NewPaginationQuery(size int, page int) *Pagination {
 	return &Pagination{Size: size, Page: page}
 }
 
+// PaginationFromQuery parses the page, size, orderBy and cursor params of a url query into a new Pagination
+func PaginationFromQuery(query url.Values) (*Pagination, error) {
+	q := &Pagination{}
+	if err := q.SetSize(query.Get("size")); err != nil {
+		return q, err
+	}
+	if err := q.SetPage(query.Get("page")); err != nil {
+		return q, err
+	}
+	if query.Has("cursor") {
+		if query.Has("page") {
+			return q, errors.New("page and cursor cannot be combined")
+		}
+		q.SetCursor(query.Get("cursor"))
+	}
+	if err := q.SetOrderBy(query.Get("orderBy")); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
 // SetSize Set page size
 func (q *Pagination) SetSize(sizeQuery string) error {
 	if sizeQuery == "" {
@@ -31,6 +100,9 @@ func (q *Pagination) SetSize(sizeQuery string) error {
 	if err != nil {
 		return err
 	}
+	if n < 1 || n > maxSize {
+		return fmt.Errorf("size must be between 1 and %d", maxSize)
+	}
 	q.Size = n
 	return nil
 }
@@ -38,20 +110,33 @@ func (q *Pagination) SetSize(sizeQuery string) error {
 // SetPage Set page number
 func (q *Pagination) SetPage(pageQuery string) error {
 	if pageQuery == "" {
-		q.Size = 0
+		q.Page = defaultPage
 		return nil
 	}
 	n, err := strconv.Atoi(pageQuery)
 	if err != nil {
 		return err
 	}
+	if n < 1 {
+		return errors.New("page must be greater than 0")
+	}
 	q.Page = n
 	return nil
 }
 
 // SetOrderBy Set order by
-func (q *Pagination) SetOrderBy(orderByQuery string) {
+func (q *Pagination) SetOrderBy(orderByQuery string) error {
+	if _, err := ParseOrderBy(orderByQuery); err != nil {
+		return err
+	}
 	q.OrderBy = orderByQuery
+	return nil
+}
+
+// SetCursor Set cursor, switching to cursor pagination
+func (q *Pagination) SetCursor(cursorQuery string) {
+	q.Cursor = cursorQuery
+	q.CursorMode = true
 }
 
 // GetOffset Get offset
@@ -72,6 +157,21 @@ func (q *Pagination) GetOrderBy() string {
 	return q.OrderBy
 }
 
+// GetCursor Get cursor
+func (q *Pagination) GetCursor() string {
+	return q.Cursor
+}
+
+// UsesCursor returns whether pages are selected by cursor instead of by number
+func (q *Pagination) UsesCursor() bool {
+	return q.CursorMode
+}
+
+// GetSortFields Get the parsed SortFields of OrderBy
+func (q *Pagination) GetSortFields() ([]SortField, error) {
+	return ParseOrderBy(q.OrderBy)
+}
+
 // GetPage Get OrderBy
 func (q *Pagination) GetPage() int {
 	return q.Page
@@ -84,6 +184,9 @@ func (q *Pagination) GetSize() int {
 
 // GetQueryString get query string
 func (q *Pagination) GetQueryString() string {
+	if q.UsesCursor() {
+		return fmt.Sprintf("cursor=%s&size=%v&orderBy=%s", url.QueryEscape(q.GetCursor()), q.GetSize(), q.GetOrderBy())
+	}
 	return fmt.Sprintf("page=%v&size=%v&orderBy=%s", q.GetPage(), q.GetSize(), q.GetOrderBy())
 }
 
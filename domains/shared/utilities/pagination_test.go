@@ -0,0 +1,39 @@
+package utilities
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name    string      // The name of the test
+		input   string      // The orderBy param being parsed
+		want    []SortField // What out instance we want our function to return.
+		wantErr bool        // whether we want an error
+	}{
+		{"empty", "", []SortField{}, false},
+		{"ascending", "email", []SortField{{Field: "email"}}, false},
+		{"descending and ascending", "-created_at, email", []SortField{{Field: "created_at", Descending: true}, {Field: "email"}}, false},
+		{"empty field", "email,", nil, true},
+		{"malformed field", "email;drop", nil, true},
+		{"operator field", "$where", nil, true},
+		{"duplicate field", "email,-email", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOrderBy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOrderBy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidOrderBy) {
+				t.Errorf("ParseOrderBy() error = %v, want %v", err, ErrInvalidOrderBy)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOrderBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
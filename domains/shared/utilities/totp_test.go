@@ -0,0 +1,52 @@
+package utilities
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B SHA1 test vectors, truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	// Defining our test slice. Each unit test should have the following properties:
+	tests := []struct {
+		name  string // The name of the test
+		want  string // What out instance we want our function to return.
+		input int64  // The unix time of the test
+	}{
+		{"59", "287082", 59},
+		{"1111111109", "081804", 1111111109},
+		{"1234567890", "005924", 1234567890},
+		{"2000000000", "279037", 2000000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOTPCode(secret, TOTPStep(time.Unix(tt.input, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("TOTPCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, err := TOTPCode(secret, TOTPStep(now.Add(-30*time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step, ok := ValidateTOTP(secret, code, now); !ok || step != TOTPStep(now)-1 {
+		t.Errorf("ValidateTOTP() rejected the code of the previous step")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(2*time.Minute)); ok {
+		t.Errorf("ValidateTOTP() accepted a code outside of the allowed skew")
+	}
+}
@@ -2,6 +2,13 @@ package utilities
 
 import "net/mail"
 
+// FieldError describes why the value of a single input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 func IsValidEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil